@@ -8,8 +8,10 @@ import (
 	"syscall"
 
 	"github.com/xochilpili/subtitler-api/internal/config"
+	"github.com/xochilpili/subtitler-api/internal/grpcserver"
 	"github.com/xochilpili/subtitler-api/internal/logger"
 	"github.com/xochilpili/subtitler-api/internal/metrics"
+	"github.com/xochilpili/subtitler-api/internal/providers"
 	"github.com/xochilpili/subtitler-api/internal/tracer"
 	"github.com/xochilpili/subtitler-api/internal/webserver"
 )
@@ -21,7 +23,8 @@ func main() {
 	tracerShutdown := tracer.InitTracer(context.Background(), config, logger)
 	metricsShutdown := metrics.InitMetrics(context.Background(), config, logger)
 
-	srv := webserver.New(config, logger)
+	manager := providers.New(config, logger)
+	srv := webserver.New(config, logger, manager)
 	go func() {
 		logger.Info().Msgf("starting server at %s:%s", config.HOST, config.PORT)
 		if err := srv.Web.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -29,6 +32,17 @@ func main() {
 		}
 	}()
 
+	var grpcSrv *grpcserver.GrpcServer
+	if config.GrpcEnabled {
+		grpcSrv = grpcserver.New(config, logger, manager, srv.Auth())
+		go func() {
+			logger.Info().Msgf("starting grpc server at %s:%s", config.HOST, config.GrpcPort)
+			if err := grpcSrv.ListenAndServe(); err != nil {
+				logger.Fatal().Err(err).Msg("error while loading grpc server")
+			}
+		}()
+	}
+
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
@@ -39,6 +53,10 @@ func main() {
 	if err := srv.Web.Shutdown(context.Background()); err != nil {
 		logger.Fatal().Err(err).Msg("error while shutting down server.")
 	}
+	if grpcSrv != nil {
+		grpcSrv.Server.GracefulStop()
+	}
+	manager.Close()
 	metricsShutdown()
 	tracerShutdown()
 	logger.Info().Msg("clean shutdown")
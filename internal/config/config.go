@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -12,18 +13,79 @@ const (
 )
 
 type Config struct {
-	HOST                     string `default:"0.0.0.0" required:"true"`
-	PORT                     string `default:"4002" required:"true"`
-	ENV                      string `default:"development" required:"true"`
-	ServiceName              string `default:"subtitler-api" required:"true" splits_words:"true"`
-	Debug                    bool   `default:"false"`
-	OpenSubtitlesApiKey      string `required:"true" split_words:"true"`
-	OpenSubtitlesApiUsername string `required:"true" split_words:"true"`
-	OpenSubtitlesApiPassword string `required:"true" split_words:"true"`
-	SubxApiKey               string `required:"true" split_words:"true"`
-	OtelEnabled              bool   `required:"true" split_words:"true"`
-	OtelEndpoint             string `split_words:"true"`
-	LokiEndpoint             string `split_words:"true"`
+	HOST                     string                   `default:"0.0.0.0" required:"true"`
+	PORT                     string                   `default:"4002" required:"true"`
+	GrpcEnabled              bool                     `default:"false" split_words:"true"`
+	GrpcPort                 string                   `default:"4003" split_words:"true"`
+	GrpcReflection           bool                     `default:"false" split_words:"true"`
+	ENV                      string                   `default:"development" required:"true"`
+	ServiceName              string                   `default:"subtitler-api" required:"true" splits_words:"true"`
+	Debug                    bool                     `default:"false"`
+	OpenSubtitlesApiKey      string                   `required:"true" split_words:"true"`
+	OpenSubtitlesApiUsername string                   `required:"true" split_words:"true"`
+	OpenSubtitlesApiPassword string                   `required:"true" split_words:"true"`
+	OpenSubtitlesMaxPages    int                      `default:"3" split_words:"true"`
+	SubxApiKey               string                   `required:"true" split_words:"true"`
+	SubdivxSessionTtl        time.Duration            `default:"30m" split_words:"true"`
+	SubdivxCommentWorkers    int                      `default:"4" split_words:"true"`
+	SubdivxCommentBudget     int                      `default:"25" split_words:"true"`
+	SubdivxCommentTimeout    time.Duration            `default:"10s" split_words:"true"`
+	PodnapisiEnabled         bool                     `default:"false" split_words:"true"`
+	PodnapisiUrl             string                   `default:"https://www.podnapisi.net/" split_words:"true"`
+	Addic7edEnabled          bool                     `default:"false" split_words:"true"`
+	Addic7edUrl              string                   `default:"https://www.addic7ed.com/" split_words:"true"`
+	Addic7edSessionCookie    string                   `split_words:"true"`
+	Addic7edRequestInterval  time.Duration            `default:"2s" split_words:"true"`
+	Addic7edDailyDownloads   int                      `default:"40" split_words:"true"`
+	LocalLibraryPath         string                   `split_words:"true"`
+	LocalLibraryLanguage     string                   `default:"es" split_words:"true"`
+	LocalLibraryRescan       time.Duration            `default:"10m" split_words:"true"`
+	ScrapersPath             string                   `split_words:"true"`
+	OtelEnabled              bool                     `required:"true" split_words:"true"`
+	OtelEndpoint             string                   `split_words:"true"`
+	LokiEndpoint             string                   `split_words:"true"`
+	CircuitFailureThreshold  int                      `default:"5" split_words:"true"`
+	CircuitCooldown          time.Duration            `default:"30s" split_words:"true"`
+	ProviderTimeout          time.Duration            `default:"15s" split_words:"true"`
+	ProviderRetries          int                      `default:"2" split_words:"true"`
+	ProviderRetryWait        time.Duration            `default:"500ms" split_words:"true"`
+	ProviderRetryMaxWait     time.Duration            `default:"5s" split_words:"true"`
+	ProviderMaxConns         int                      `default:"8" split_words:"true"`
+	ProviderTimeouts         map[string]time.Duration `split_words:"true"`
+	ProviderRetryCounts      map[string]int           `split_words:"true"`
+	ProviderRequestRate      float64                  `default:"5" split_words:"true"`
+	ProviderRequestBurst     int                      `default:"10" split_words:"true"`
+	ProviderRequestRates     map[string]float64       `split_words:"true"`
+	ProviderRequestBursts    map[string]int           `split_words:"true"`
+	AuthRequired             bool                     `split_words:"true"`
+	ApiKeys                  map[string]string        `split_words:"true"`
+	ApiKeysFile              string                   `split_words:"true"`
+	ApiKeyRate               float64                  `default:"10" split_words:"true"`
+	ApiKeyBurst              int                      `default:"20" split_words:"true"`
+	ApiKeyDailyDownloads     int                      `default:"200" split_words:"true"`
+	JwtJwks                  string                   `split_words:"true"`
+	JwtJwksRefresh           time.Duration            `default:"1h" split_words:"true"`
+	JwtIssuer                string                   `split_words:"true"`
+	JwtAudience              string                   `split_words:"true"`
+	JwtScopeClaim            string                   `default:"scope" split_words:"true"`
+	JwtClientClaim           string                   `default:"azp" split_words:"true"`
+	JwtScopeMap              map[string]string        `split_words:"true"`
+	ProbeInterval            time.Duration            `default:"1m" split_words:"true"`
+	ProbeTimeout             time.Duration            `default:"10s" split_words:"true"`
+	ReadyMinHealthy          int                      `default:"1" split_words:"true"`
+	SearchCacheTtl           time.Duration            `default:"30m" split_words:"true"`
+	SearchCacheSize          int                      `default:"10000" split_words:"true"`
+	SearchBatchMaxItems      int                      `default:"200" split_words:"true"`
+	SearchBatchConcurrency   int                      `default:"4" split_words:"true"`
+	DownloadBatchMaxItems    int                      `default:"50" split_words:"true"`
+	DownloadBatchConcurrency int                      `default:"4" split_words:"true"`
+	BestMaxAttempts          int                      `default:"3" split_words:"true"`
+	GraphqlMaxDepth          int                      `default:"8" split_words:"true"`
+	GraphqlMaxQueryLength    int                      `default:"4096" split_words:"true"`
+	GraphqlMaxResults        int                      `default:"100" split_words:"true"`
+	GraphqlMaxComplexity     int                      `default:"1000" split_words:"true"`
+	LegacyDeprecatedAt       string                   `default:"2026-10-19" split_words:"true"`
+	LegacySunsetAt           string                   `default:"2027-04-19" split_words:"true"`
 }
 
 func New() *Config {
@@ -0,0 +1,96 @@
+package grpcserver
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/xochilpili/subtitler-api/internal/webserver"
+	subtitlerv1 "github.com/xochilpili/subtitler-api/pkg/pb/subtitler/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// methodScopes are the token scopes each method needs, as on the HTTP
+// routes. Listing providers only needs a valid caller.
+var methodScopes = map[string]string{
+	subtitlerv1.SubtitlerService_Search_FullMethodName:       webserver.ScopeSearch,
+	subtitlerv1.SubtitlerService_SearchStream_FullMethodName: webserver.ScopeSearch,
+	subtitlerv1.SubtitlerService_Download_FullMethodName:     webserver.ScopeDownload,
+}
+
+// unaryAuth authenticates unary calls with the x-api-key or authorization
+// metadata, like the HTTP API does with its headers.
+func (s *GrpcServer) unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if _, err := s.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamAuth authenticates streaming calls and counts downloads against
+// the caller's daily quota, giving back the ones that fail.
+func (s *GrpcServer) streamAuth(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := stream.Context()
+	caller, err := s.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return err
+	}
+	if caller == nil || info.FullMethod != subtitlerv1.SubtitlerService_Download_FullMethodName {
+		return handler(srv, stream)
+	}
+	remaining, err := caller.TakeDownload()
+	if err != nil {
+		s.auth.Reject(ctx, caller.Id(), err)
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if remaining >= 0 {
+		stream.SetHeader(metadata.Pairs("x-quota-remaining", strconv.Itoa(remaining)))
+	}
+	if err := handler(srv, stream); err != nil {
+		caller.ReturnDownload()
+		return err
+	}
+	return nil
+}
+
+// authorize identifies the caller of method, checks its scope and takes a
+// token from its request bucket. It returns no caller when the API is
+// open. Reflection, like listing providers, needs no scope.
+func (s *GrpcServer) authorize(ctx context.Context, method string) (*webserver.Caller, error) {
+	if !s.auth.Enabled() {
+		return nil, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	token, _ := webserver.BearerToken(firstValue(md, "authorization"))
+	caller, err := s.auth.Authenticate(ctx, firstValue(md, strings.ToLower(webserver.ApiKeyHeader)), token)
+	if err != nil {
+		s.auth.Reject(ctx, "", err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("client.id", caller.Id()))
+	if scope, ok := methodScopes[method]; ok {
+		if err := caller.RequireScope(scope); err != nil {
+			s.auth.Reject(ctx, caller.Id(), err)
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+	if delay, err := caller.Allow(); err != nil {
+		s.auth.Reject(ctx, caller.Id(), err)
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(delay.Seconds())))))
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return caller, nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
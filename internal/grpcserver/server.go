@@ -0,0 +1,55 @@
+package grpcserver
+
+//go:generate protoc --proto_path=../../proto --go_out=../../pkg/pb --go_opt=paths=source_relative --go-grpc_out=../../pkg/pb --go-grpc_opt=paths=source_relative subtitler/v1/subtitler.proto
+
+import (
+	"net"
+
+	"github.com/rs/zerolog"
+	"github.com/xochilpili/subtitler-api/internal/config"
+	"github.com/xochilpili/subtitler-api/internal/webserver"
+	subtitlerv1 "github.com/xochilpili/subtitler-api/pkg/pb/subtitler/v1"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+type GrpcServer struct {
+	subtitlerv1.UnimplementedSubtitlerServiceServer
+	config  *config.Config
+	logger  *zerolog.Logger
+	manager webserver.Manager
+	auth    *webserver.Auth
+	Server  *grpc.Server
+}
+
+// New serves the manager over gRPC, authenticating callers with the same
+// keys, tokens, rate limits and quotas as the HTTP API. Reflection is only
+// served with SA_GRPC_REFLECTION, to authenticated callers.
+func New(config *config.Config, logger *zerolog.Logger, manager webserver.Manager, auth *webserver.Auth) *GrpcServer {
+	srv := &GrpcServer{
+		config:  config,
+		logger:  logger,
+		manager: manager,
+		auth:    auth,
+	}
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.UnaryInterceptor(srv.unaryAuth),
+		grpc.StreamInterceptor(srv.streamAuth),
+	)
+	srv.Server = server
+	subtitlerv1.RegisterSubtitlerServiceServer(server, srv)
+	if config.GrpcReflection {
+		reflection.Register(server)
+	}
+	return srv
+}
+
+func (s *GrpcServer) ListenAndServe() error {
+	lis, err := net.Listen("tcp", s.config.HOST+":"+s.config.GrpcPort)
+	if err != nil {
+		return err
+	}
+	return s.Server.Serve(lis)
+}
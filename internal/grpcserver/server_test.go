@@ -0,0 +1,264 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/xochilpili/subtitler-api/internal/config"
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"github.com/xochilpili/subtitler-api/internal/providers"
+	"github.com/xochilpili/subtitler-api/internal/webserver"
+	subtitlerv1 "github.com/xochilpili/subtitler-api/pkg/pb/subtitler/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testApiKey = "s3cret"
+
+const srtFile = "1\n00:00:01,000 --> 00:00:02,000\nHola\n"
+
+// fakeManager answers every enabled provider with one subtitle. The
+// download of "missing" is not found.
+type fakeManager struct {
+	mu        sync.Mutex
+	downloads int
+}
+
+func (m *fakeManager) Search(ctx context.Context, provider string, req *models.SearchRequest, filters *models.PostFilters) ([]models.Subtitle, []models.SkippedProvider) {
+	if provider == "subx" {
+		return nil, []models.SkippedProvider{{Provider: provider, Reason: "circuit_open"}}
+	}
+	return []models.Subtitle{{Provider: provider, ExternalId: "1", Title: req.Term, Language: "es"}}, nil
+}
+
+func (m *fakeManager) Download(ctx context.Context, provider string, subtitleId string) (*models.SubtitleFile, error) {
+	m.mu.Lock()
+	m.downloads++
+	m.mu.Unlock()
+	if subtitleId == "missing" {
+		return nil, providers.ErrSubtitleNotFound
+	}
+	return &models.SubtitleFile{
+		Body:        io.NopCloser(strings.NewReader(srtFile)),
+		Filename:    subtitleId + ".srt",
+		ContentType: "application/x-subrip",
+		Size:        int64(len(srtFile)),
+	}, nil
+}
+
+func (m *fakeManager) downloadCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.downloads
+}
+
+func (m *fakeManager) Providers() []models.ProviderInfo {
+	return []models.ProviderInfo{
+		{Name: "podnapisi", Enabled: true},
+		{Name: "subx", Enabled: true},
+		{Name: "addic7ed", Enabled: false},
+	}
+}
+
+func (m *fakeManager) Subtitle(uid string) (*models.Subtitle, []models.Subtitle, bool) {
+	return nil, nil, false
+}
+
+func (m *fakeManager) Details(ctx context.Context, provider string, subtitleId string) ([]models.SubComments, error) {
+	return nil, providers.ErrNoDetails
+}
+
+func (m *fakeManager) Readiness() models.Readiness {
+	return models.Readiness{Ready: true}
+}
+
+// dial serves manager over an in-memory listener with the configuration
+// defaults, an API key and env.
+func dial(t *testing.T, manager webserver.Manager, env map[string]string) *grpc.ClientConn {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	// the manager is faked, so the provider credentials are never used
+	defaults := map[string]string{
+		"SA_OPEN_SUBTITLES_API_KEY":      "unused",
+		"SA_OPEN_SUBTITLES_API_USERNAME": "unused",
+		"SA_OPEN_SUBTITLES_API_PASSWORD": "unused",
+		"SA_SUBX_API_KEY":                "unused",
+		"SA_OTEL_ENABLED":                "false",
+		"SA_API_KEYS":                    "tests:" + testApiKey,
+	}
+	for key, value := range defaults {
+		t.Setenv(key, value)
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := zerolog.Nop()
+	srv := New(cfg, &logger, manager, webserver.New(cfg, &logger, manager).Auth())
+
+	listener := bufconn.Listen(1 << 20)
+	go srv.Server.Serve(listener)
+	t.Cleanup(srv.Server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+}
+
+func TestAuth(t *testing.T) {
+	client := subtitlerv1.NewSubtitlerServiceClient(dial(t, &fakeManager{}, nil))
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"no key", context.Background(), codes.Unauthenticated},
+		{"wrong key", withKey("guess"), codes.Unauthenticated},
+		{"valid key", withKey(testApiKey), codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.Search(tt.ctx, &subtitlerv1.SearchRequest{Term: "the matrix"})
+			if code := status.Code(err); code != tt.want {
+				t.Fatalf("Search() code = %s, want %s: %v", code, tt.want, err)
+			}
+		})
+	}
+}
+
+// download reads a whole Download stream.
+func download(ctx context.Context, client subtitlerv1.SubtitlerServiceClient, id string) (string, metadata.MD, error) {
+	stream, err := client.Download(ctx, &subtitlerv1.DownloadRequest{Provider: "podnapisi", SubtitleId: id})
+	if err != nil {
+		return "", nil, err
+	}
+	var data []byte
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		data = append(data, chunk.GetData()...)
+	}
+	header, _ := stream.Header()
+	return string(data), header, nil
+}
+
+func TestDownloadQuota(t *testing.T) {
+	manager := &fakeManager{}
+	client := subtitlerv1.NewSubtitlerServiceClient(dial(t, manager, map[string]string{"SA_API_KEY_DAILY_DOWNLOADS": "1"}))
+
+	// a failed download is given back
+	if _, _, err := download(withKey(testApiKey), client, "missing"); status.Code(err) != codes.NotFound {
+		t.Fatalf("missing download code = %s, want NotFound: %v", status.Code(err), err)
+	}
+	data, header, err := download(withKey(testApiKey), client, "AbC1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != srtFile {
+		t.Errorf("downloaded %q, want %q", data, srtFile)
+	}
+	if remaining := header.Get("x-quota-remaining"); !slices.Equal(remaining, []string{"0"}) {
+		t.Errorf("x-quota-remaining = %v, want 0", remaining)
+	}
+	if _, _, err := download(withKey(testApiKey), client, "AbC1"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("download over the quota code = %s, want ResourceExhausted: %v", status.Code(err), err)
+	}
+	if n := manager.downloadCount(); n != 2 {
+		t.Errorf("downloads reaching the manager = %d, want 2", n)
+	}
+}
+
+func TestSearchStream(t *testing.T) {
+	client := subtitlerv1.NewSubtitlerServiceClient(dial(t, &fakeManager{}, nil))
+	stream, err := client.SearchStream(withKey(testApiKey), &subtitlerv1.SearchRequest{Term: "the matrix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := map[string]*subtitlerv1.ProviderResults{}
+	for {
+		result, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		results[result.GetProvider()] = result
+	}
+
+	// disabled providers are not searched
+	if len(results) != 2 {
+		t.Fatalf("got results of %d providers, want podnapisi and subx", len(results))
+	}
+	if subtitles := results["podnapisi"].GetSubtitles(); len(subtitles) != 1 || subtitles[0].GetUid() != "podnapisi:1" {
+		t.Errorf("podnapisi results = %v", subtitles)
+	}
+	if skipped := results["subx"].GetSkipped(); skipped.GetReason() != "circuit_open" {
+		t.Errorf("subx skipped = %v, want circuit_open", skipped)
+	}
+
+	empty, err := client.SearchStream(withKey(testApiKey), &subtitlerv1.SearchRequest{})
+	if err == nil {
+		_, err = empty.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("search without a term code = %s, want InvalidArgument", status.Code(err))
+	}
+}
+
+func TestReflection(t *testing.T) {
+	list := func(ctx context.Context, conn *grpc.ClientConn) error {
+		stream, err := reflectionv1.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&reflectionv1.ServerReflectionRequest{MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{}}); err != nil {
+			return err
+		}
+		_, err = stream.Recv()
+		return err
+	}
+
+	off := dial(t, &fakeManager{}, nil)
+	if err := list(withKey(testApiKey), off); status.Code(err) != codes.Unimplemented {
+		t.Errorf("reflection by default code = %s, want Unimplemented", status.Code(err))
+	}
+	on := dial(t, &fakeManager{}, map[string]string{"SA_GRPC_REFLECTION": "true"})
+	if err := list(context.Background(), on); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("reflection without a key code = %s, want Unauthenticated", status.Code(err))
+	}
+	if err := list(withKey(testApiKey), on); err != nil {
+		t.Errorf("reflection with a key: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"github.com/xochilpili/subtitler-api/internal/providers"
+	subtitlerv1 "github.com/xochilpili/subtitler-api/pkg/pb/subtitler/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const downloadChunkSize = 32 * 1024
+
+func (s *GrpcServer) Search(ctx context.Context, req *subtitlerv1.SearchRequest) (*subtitlerv1.SearchResponse, error) {
+	if req.GetTerm() == "" {
+		return nil, status.Error(codes.InvalidArgument, "term is required")
+	}
+	subtitles, skipped := s.manager.Search(ctx, req.GetProvider(), searchRequest(req), postFilters(req))
+	response := &subtitlerv1.SearchResponse{Subtitles: toProtoSubtitles(subtitles)}
+	for i := range skipped {
+		response.Skipped = append(response.Skipped, toProtoSkipped(&skipped[i]))
+	}
+	return response, nil
+}
+
+func (s *GrpcServer) SearchStream(req *subtitlerv1.SearchRequest, stream grpc.ServerStreamingServer[subtitlerv1.ProviderResults]) error {
+	if req.GetTerm() == "" {
+		return status.Error(codes.InvalidArgument, "term is required")
+	}
+	ctx := stream.Context()
+	wg := &sync.WaitGroup{}
+	results := make(chan *subtitlerv1.ProviderResults)
+	for _, p := range s.manager.Providers() {
+		if !p.Enabled || (req.GetProvider() != "" && req.GetProvider() != p.Name) {
+			continue
+		}
+		wg.Add(1)
+		go func(provider string) {
+			defer wg.Done()
+			subtitles, skipped := s.manager.Search(ctx, provider, searchRequest(req), postFilters(req))
+			result := &subtitlerv1.ProviderResults{Provider: provider, Subtitles: toProtoSubtitles(subtitles)}
+			if len(skipped) > 0 {
+				result.Skipped = toProtoSkipped(&skipped[0])
+			}
+			select {
+			case results <- result:
+			case <-ctx.Done():
+			}
+		}(p.Name)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for item := range results {
+		if err := stream.Send(item); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func (s *GrpcServer) Download(req *subtitlerv1.DownloadRequest, stream grpc.ServerStreamingServer[subtitlerv1.DownloadChunk]) error {
+	if req.GetProvider() == "" || req.GetSubtitleId() == "" {
+		return status.Error(codes.InvalidArgument, "provider and subtitle_id are required")
+	}
+	file, err := s.manager.Download(stream.Context(), req.GetProvider(), req.GetSubtitleId())
+	if errors.Is(err, providers.ErrUnknownProvider) || errors.Is(err, providers.ErrSubtitleNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, providers.ErrQuotaExceeded) || errors.Is(err, providers.ErrRateLimited) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	defer file.Body.Close()
+
+	chunk := &subtitlerv1.DownloadChunk{Filename: file.Filename, ContentType: file.ContentType}
+	buf := make([]byte, downloadChunkSize)
+	for {
+		n, err := file.Body.Read(buf)
+		if n > 0 {
+			chunk.Data = buf[:n]
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+			chunk = &subtitlerv1.DownloadChunk{}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+	}
+	// empty files still get their metadata
+	if chunk.Filename != "" {
+		return stream.Send(chunk)
+	}
+	return nil
+}
+
+func (s *GrpcServer) ListProviders(ctx context.Context, req *subtitlerv1.ListProvidersRequest) (*subtitlerv1.ListProvidersResponse, error) {
+	var items []*subtitlerv1.Provider
+	for _, p := range s.manager.Providers() {
+		items = append(items, &subtitlerv1.Provider{
+			Name:       p.Name,
+			Enabled:    p.Enabled,
+			Languages:  p.Languages,
+			SearchKeys: p.SearchKeys,
+			Formats:    p.Formats,
+		})
+	}
+	return &subtitlerv1.ListProvidersResponse{Providers: items}, nil
+}
+
+func searchRequest(req *subtitlerv1.SearchRequest) *models.SearchRequest {
+	return &models.SearchRequest{
+		Term:     req.GetTerm(),
+		Year:     int(req.GetYear()),
+		Season:   int(req.GetSeason()),
+		Episode:  int(req.GetEpisode()),
+		Language: req.GetLanguage(),
+
+		MachineTranslated: req.GetIncludeMachineTranslated() || req.GetMachineTranslated(),
+	}
+}
+
+func postFilters(req *subtitlerv1.SearchRequest) *models.PostFilters {
+	return &models.PostFilters{
+		Year:       int(req.GetYear()),
+		Group:      req.GetGroup(),
+		Quality:    req.GetQuality(),
+		Resolution: req.GetResolution(),
+
+		MinDownloads:      int(req.GetMinDownloads()),
+		MinRating:         req.GetMinRating(),
+		HearingImpaired:   req.HearingImpaired,
+		MachineTranslated: req.MachineTranslated,
+		Trusted:           req.Trusted,
+		Sort:              req.GetSort(),
+		Order:             req.GetOrder(),
+	}
+}
+
+func toProtoSubtitles(subtitles []models.Subtitle) []*subtitlerv1.Subtitle {
+	items := make([]*subtitlerv1.Subtitle, 0, len(subtitles))
+	for i := range subtitles {
+		s := &subtitles[i]
+		items = append(items, &subtitlerv1.Subtitle{
+			Uid:         s.Uid(),
+			Provider:    s.Provider,
+			Id:          s.DownloadId(),
+			Kind:        s.Kind(),
+			Title:       s.Title,
+			Description: s.Description,
+			Language:    s.Language,
+			Year:        int32(s.Year),
+			Season:      int32(s.Season),
+			Episode:     int32(s.Episode),
+			Groups:      s.Group,
+			Qualities:   s.Quality,
+			Resolutions: s.Resolution,
+			Durations:   s.Duration,
+
+			Downloads:         int32(s.Downloads),
+			Rating:            s.Rating,
+			Uploader:          s.Uploader,
+			UploadedAt:        timestamp(s.UploadedAt),
+			Cds:               int32(s.Cds),
+			Fps:               s.Fps,
+			HearingImpaired:   s.HearingImpaired,
+			MachineTranslated: s.MachineTranslated,
+			Trusted:           s.Trusted,
+		})
+	}
+	return items
+}
+
+func timestamp(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func toProtoSkipped(s *models.SkippedProvider) *subtitlerv1.SkippedProvider {
+	return &subtitlerv1.SkippedProvider{Provider: s.Provider, Reason: s.Reason, Error: s.Error}
+}
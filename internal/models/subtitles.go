@@ -1,12 +1,45 @@
 package models
 
+import (
+	"io"
+	"strconv"
+	"time"
+)
+
+type SearchRequest struct {
+	Term     string
+	Year     int
+	Season   int
+	Episode  int
+	Language string
+	// MachineTranslated includes machine and AI translated subtitles on
+	// providers that exclude them by default.
+	MachineTranslated bool
+}
+
 type PostFilters struct {
-	Year       int
-	Group      string
-	Quality    string
-	Resolution string
+	Year              int
+	Group             string
+	Quality           string
+	Resolution        string
+	MinDownloads      int
+	MinRating         float64
+	HearingImpaired   *bool
+	MachineTranslated *bool
+	Trusted           *bool
+	Sort              string
+	Order             string
 }
 
+// Sort keys accepted by PostFilters.Sort.
+const (
+	SortDownloads  = "downloads"
+	SortRating     = "rating"
+	SortUploadedAt = "uploaded_at"
+	SortFps        = "fps"
+	SortCds        = "cds"
+)
+
 /*type Subtitle struct {
 	Title string `json:"title"`
 }*/
@@ -19,20 +52,165 @@ type SubComments struct {
 	Date    string `json:"fecha_creacion"`
 }
 type Subtitle struct {
-	Provider    string `json:"provider"`
-	Type string `json:"type"`
-	Id          int    `json:"id"`
-	ExternalId string `json:"external_id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Language    string `json:"language"`
-	//Cds         int           `json:"cds"`
-	//Comments    []SubComments `json:"comments,omitempty"`
-	Group      []string `json:"group"`
-	Quality    []string `json:"quality"`
-	Resolution []string `json:"resolution"`
-	Duration   []string `json:"duration"`
-	Year       int      `json:"year"`
-	Season int `json:"season"`
-	Episode int `json:"episode"`
+	Provider    string        `json:"provider"`
+	Type        string        `json:"type"`
+	Id          int           `json:"id"`
+	ExternalId  string        `json:"external_id"`
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Language    string        `json:"language"`
+	Cds         int           `json:"cds"`
+	Comments    []SubComments `json:"comments,omitempty"`
+	Group       []string      `json:"group"`
+	Quality     []string      `json:"quality"`
+	Resolution  []string      `json:"resolution"`
+	Duration    []string      `json:"duration"`
+	Year        int           `json:"year"`
+	Season      int           `json:"season"`
+	Episode     int           `json:"episode"`
+
+	Downloads         int        `json:"downloads"`
+	Rating            float64    `json:"rating"`
+	Uploader          string     `json:"uploader"`
+	UploadedAt        *time.Time `json:"uploaded_at,omitempty"`
+	Fps               float64    `json:"fps"`
+	HearingImpaired   bool       `json:"hearing_impaired"`
+	MachineTranslated bool       `json:"machine_translated"`
+	Trusted           bool       `json:"trusted"`
+}
+
+// DownloadId returns the identifier the provider expects on download.
+func (s *Subtitle) DownloadId() string {
+	if s.ExternalId != "" {
+		return s.ExternalId
+	}
+	return strconv.Itoa(s.Id)
+}
+
+// Uid identifies a subtitle across providers as provider:id.
+func (s *Subtitle) Uid() string {
+	return s.Provider + ":" + s.DownloadId()
+}
+
+// Kind is the public name of Type: movie or episode.
+func (s *Subtitle) Kind() string {
+	if s.Type == "serie" {
+		return "episode"
+	}
+	return s.Type
+}
+
+// SubtitleFile is a subtitle streamed from a provider. The caller closes
+// Body. Size is -1 when the provider did not announce it; local files are
+// seekable, so ranges can be served from them.
+type SubtitleFile struct {
+	Body        io.ReadCloser
+	Filename    string
+	ContentType string
+	Size        int64
+	ETag        string
+	ModTime     time.Time
+}
+
+// Search keys a provider can honour, listed in ProviderInfo.SearchKeys.
+const (
+	SearchKeyText     = "text"
+	SearchKeyYear     = "year"
+	SearchKeyEpisode  = "season_episode"
+	SearchKeyLanguage = "language"
+)
+
+type ProviderInfo struct {
+	Name       string             `json:"name"`
+	Enabled    bool               `json:"enabled"`
+	Languages  []string           `json:"languages"`
+	SearchKeys []string           `json:"search_keys"`
+	Formats    []string           `json:"formats"`
+	RateLimit  *ProviderRateLimit `json:"rate_limit,omitempty"`
+	Quota      *ProviderQuota     `json:"quota,omitempty"`
+	Health     *ProviderHealth    `json:"health,omitempty"`
+	Circuit    *ProviderCircuit   `json:"circuit,omitempty"`
+}
+
+// ProviderCircuit is the state of a provider's circuit breaker.
+type ProviderCircuit struct {
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	RetryAt  *time.Time `json:"retry_at,omitempty"`
+}
+
+// Reasons a provider was left out of a search.
+const (
+	SkipCircuitOpen = "circuit_open"
+	SkipRateLimited = "rate_limited"
+	SkipError       = "error"
+	// SkipPartial marks a provider whose results stop at a failed page.
+	SkipPartial = "partial"
+)
+
+// SkippedProvider is a provider that did not contribute to a search, or
+// only in part.
+type SkippedProvider struct {
+	Provider string `json:"provider"`
+	Reason   string `json:"reason"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ProviderRateLimit is the request pacing a provider is held to: a
+// minimum interval between requests and/or a token bucket.
+type ProviderRateLimit struct {
+	IntervalMs        int64      `json:"interval_ms"`
+	NextSlot          *time.Time `json:"next_slot,omitempty"`
+	RequestsPerSecond float64    `json:"requests_per_second,omitempty"`
+	Burst             int        `json:"burst,omitempty"`
+	Available         float64    `json:"available,omitempty"`
+}
+
+// ProviderHealth is the outcome of the last upstream check.
+type ProviderHealth struct {
+	Healthy   bool      `json:"healthy"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// ProviderQuota is the download allowance a provider has left.
+type ProviderQuota struct {
+	Remaining int        `json:"remaining"`
+	ResetAt   *time.Time `json:"reset_at,omitempty"`
+}
+
+// Readiness is the aggregated provider health served on /readyz.
+type Readiness struct {
+	Ready     bool             `json:"ready"`
+	Healthy   int              `json:"healthy"`
+	Required  int              `json:"required"`
+	Providers []ProviderStatus `json:"providers"`
+}
+
+type ProviderStatus struct {
+	Name      string     `json:"name"`
+	Healthy   bool       `json:"healthy"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// ParseTimestamp reads the upload dates providers report, returning nil
+// when the value is missing or in an unknown layout.
+func ParseTimestamp(value string) *time.Time {
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+// NonNil returns items, or an empty slice when it is nil, so lists are
+// encoded as [] rather than null.
+func NonNil(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
 }
@@ -0,0 +1,332 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const addic7edShowsTtl = 24 * time.Hour
+
+var (
+	addic7edEpisodeRe = regexp.MustCompile(`(?i)\bs(\d{1,2})\s*e(\d{1,3})\b`)
+	addic7edNameRe    = regexp.MustCompile(`[^a-z0-9]+`)
+	addic7edLanguages = map[string]string{
+		"english":                 "en",
+		"spanish":                 "es",
+		"spanish (spain)":         "es",
+		"spanish (latin america)": "es",
+		"french":                  "fr",
+		"portuguese":              "pt",
+		"portuguese (brazilian)":  "pt",
+		"italian":                 "it",
+		"german":                  "de",
+	}
+)
+
+// addic7edSession keeps the show index and the site session cookie; both
+// are reused across searches and downloads.
+type addic7edSession struct {
+	mu      sync.Mutex
+	cookie  string
+	shows   map[string]int
+	names   map[int]string
+	fetched time.Time
+}
+
+func newAddic7edSession(cookie string) *addic7edSession {
+	return &addic7edSession{cookie: cookie}
+}
+
+// sessionCookie returns the cookie, which the first shows listing may set.
+func (s *addic7edSession) sessionCookie() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cookie
+}
+
+func searchAddic7ed(provider *ProviderParams, req *models.SearchRequest) ([]models.Subtitle, error) {
+	tracer := otel.Tracer("addic7ed")
+	ctx, span := tracer.Start(provider.ctx, "Addic7ed.Search")
+	defer span.End()
+	provider.ctx = ctx
+
+	show, season, episode := parseEpisodeQuery(req)
+	span.SetAttributes(
+		attribute.String("query", req.Term),
+		attribute.String("show", show),
+		attribute.Int("season", season),
+	)
+	if season == 0 {
+		provider.logger.Debug().Msgf("addic7ed only serves tv episodes, skipping: %s", req.Term)
+		return nil, nil
+	}
+
+	showId, showName, err := resolveAddic7edShow(provider, show)
+	if errors.Is(err, ErrSubtitleNotFound) {
+		provider.logger.Debug().Msgf("addic7ed has no show named %s", show)
+		return nil, nil
+	}
+	if err != nil {
+		span.RecordError(err)
+		provider.logger.Err(err).Msgf("error while resolving addic7ed show: %s", show)
+		return nil, err
+	}
+
+	episodes, err := listAddic7edSeason(provider, showId, season)
+	if err != nil {
+		span.RecordError(err)
+		provider.logger.Err(err).Msgf("error while listing addic7ed season %d of %s", season, showName)
+		return nil, err
+	}
+
+	var subtitles []models.Subtitle
+	for _, ep := range episodes {
+		if episode > 0 && ep.Episode != episode {
+			continue
+		}
+		if !ep.Completed || ep.Download == "" {
+			continue
+		}
+		language := addic7edLanguage(ep.Language)
+		if req.Language != "" && !strings.Contains(req.Language, language) {
+			continue
+		}
+		desc := ep.Version
+		group, quality, resolution, duration := parseExtra(desc)
+		subtitles = append(subtitles, models.Subtitle{
+			Provider:    "addic7ed",
+			Type:        "serie",
+			Id:          len(subtitles),
+			ExternalId:  strconv.Itoa(showId) + "-" + strings.ReplaceAll(strings.Trim(ep.Download, "/"), "/", "-"),
+			Title:       fmt.Sprintf("%s - %02dx%02d - %s", showName, ep.Season, ep.Episode, ep.Title),
+			Description: desc,
+			Language:    language,
+			Group:       group,
+			Quality:     quality,
+			Resolution:  resolution,
+			Duration:    duration,
+			Season:      ep.Season,
+			Episode:     ep.Episode,
+
+			HearingImpaired: ep.HearingImpaired,
+		})
+	}
+	span.SetAttributes(attribute.Int("subtitle_count", len(subtitles)))
+	provider.logger.Info().Msgf("returned %d subtitles", len(subtitles))
+	return subtitles, nil
+}
+
+// parseEpisodeQuery splits "Show Name S01E02" into its parts; explicit
+// season/episode on the request win over the ones found in the term.
+func parseEpisodeQuery(req *models.SearchRequest) (string, int, int) {
+	show := req.Term
+	season, episode := req.Season, req.Episode
+	if match := addic7edEpisodeRe.FindStringSubmatch(req.Term); match != nil {
+		show = strings.TrimSpace(strings.Replace(req.Term, match[0], " ", 1))
+		if season == 0 {
+			season, _ = strconv.Atoi(match[1])
+		}
+		if episode == 0 {
+			episode, _ = strconv.Atoi(match[2])
+		}
+	}
+	return show, season, episode
+}
+
+func normalizeShowName(name string) string {
+	return addic7edNameRe.ReplaceAllString(strings.ToLower(name), "")
+}
+
+func resolveAddic7edShow(provider *ProviderParams, name string) (int, string, error) {
+	session := provider.config.addic7ed
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.shows == nil || time.Since(session.fetched) > addic7edShowsTtl {
+		if err := provider.config.throttle.wait(provider.ctx); err != nil {
+			return 0, "", err
+		}
+		res, err := provider.r.R().
+			SetContext(provider.ctx).
+			SetHeaders(addic7edHeaders(provider, session.cookie, "")).
+			SetDoNotParseResponse(true).
+			SetDebug(provider.config.debug).
+			Get(provider.config.url + "shows.php")
+		if err != nil {
+			return 0, "", err
+		}
+		defer res.RawBody().Close()
+		if res.StatusCode() != http.StatusOK {
+			return 0, "", fmt.Errorf("addic7ed shows listing failed with status %d", res.StatusCode())
+		}
+		if session.cookie == "" {
+			session.cookie = cookieHeader(res.Cookies())
+		}
+		doc, err := goquery.NewDocumentFromReader(res.RawBody())
+		if err != nil {
+			return 0, "", err
+		}
+		shows := map[string]int{}
+		names := map[int]string{}
+		doc.Find(`a[href^="/show/"]`).Each(func(_ int, a *goquery.Selection) {
+			href, _ := a.Attr("href")
+			id, err := strconv.Atoi(strings.TrimPrefix(href, "/show/"))
+			if err != nil {
+				return
+			}
+			shows[normalizeShowName(a.Text())] = id
+			names[id] = strings.TrimSpace(a.Text())
+		})
+		session.shows = shows
+		session.names = names
+		session.fetched = time.Now()
+	}
+
+	id, ok := session.shows[normalizeShowName(name)]
+	if !ok {
+		return 0, "", fmt.Errorf("%w: no addic7ed show named %s", ErrSubtitleNotFound, name)
+	}
+	return id, session.names[id], nil
+}
+
+func listAddic7edSeason(provider *ProviderParams, showId int, season int) ([]Addic7edEpisode, error) {
+	if err := provider.config.throttle.wait(provider.ctx); err != nil {
+		return nil, err
+	}
+	res, err := provider.r.R().
+		SetContext(provider.ctx).
+		SetHeaders(addic7edHeaders(provider, provider.config.addic7ed.sessionCookie(), provider.config.url+"show/"+strconv.Itoa(showId))).
+		SetQueryParams(map[string]string{
+			"show":   strconv.Itoa(showId),
+			"season": strconv.Itoa(season),
+			"langs":  "",
+			"hd":     "undefined",
+			"hi":     "undefined",
+		}).
+		SetDoNotParseResponse(true).
+		SetDebug(provider.config.debug).
+		Get(provider.config.url + provider.config.searchUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer res.RawBody().Close()
+	if res.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("addic7ed season listing failed with status %d", res.StatusCode())
+	}
+	doc, err := goquery.NewDocumentFromReader(res.RawBody())
+	if err != nil {
+		return nil, err
+	}
+	return parseAddic7edSeason(doc), nil
+}
+
+func parseAddic7edSeason(doc *goquery.Document) []Addic7edEpisode {
+	var episodes []Addic7edEpisode
+	doc.Find("tr.epeven").Each(func(_ int, row *goquery.Selection) {
+		cells := row.Find("td")
+		if cells.Length() < 10 {
+			return
+		}
+		text := func(i int) string { return strings.TrimSpace(cells.Eq(i).Text()) }
+		season, _ := strconv.Atoi(text(0))
+		episode, _ := strconv.Atoi(text(1))
+		download, _ := cells.Eq(9).Find("a").Attr("href")
+		episodes = append(episodes, Addic7edEpisode{
+			Season:          season,
+			Episode:         episode,
+			Title:           text(2),
+			Language:        text(3),
+			Version:         text(4),
+			Completed:       strings.EqualFold(text(5), "completed"),
+			HearingImpaired: text(6) != "",
+			Corrected:       text(7) != "",
+			HD:              text(8) != "",
+			Download:        download,
+		})
+	})
+	return episodes
+}
+
+func downloadAddic7ed(provider *ProviderParams, subtitleId string) (*models.SubtitleFile, error) {
+	tracer := otel.Tracer("addic7ed")
+	ctx, span := tracer.Start(provider.ctx, "Addic7ed.Download")
+	defer span.End()
+	span.SetAttributes(attribute.String("subtitle_id", subtitleId))
+
+	showId, path, ok := strings.Cut(subtitleId, "-")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("%w: invalid addic7ed subtitle id %q", ErrSubtitleNotFound, subtitleId)
+	}
+	if err := provider.config.throttle.takeDownload(); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	file, err := fetchAddic7edDownload(ctx, provider, showId, path, subtitleId)
+	if err != nil {
+		// only downloads the site served count against its allowance
+		provider.config.throttle.returnDownload()
+		span.RecordError(err)
+		return nil, err
+	}
+	provider.logger.Info().Msgf("downloading file: %s", file.Filename)
+	return file, nil
+}
+
+func fetchAddic7edDownload(ctx context.Context, provider *ProviderParams, showId string, path string, subtitleId string) (*models.SubtitleFile, error) {
+	if err := provider.config.throttle.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	res, err := provider.r.R().
+		SetContext(ctx).
+		SetHeaders(addic7edHeaders(provider, provider.config.addic7ed.sessionCookie(), provider.config.url+"show/"+showId)).
+		SetDoNotParseResponse(true).
+		SetDebug(provider.config.debug).
+		Get(provider.config.url + strings.ReplaceAll(path, "-", "/"))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode() == http.StatusOK && strings.HasPrefix(res.Header().Get("Content-Type"), "text/html") {
+		res.RawBody().Close()
+		return nil, errors.New("addic7ed refused the download, daily limit reached or session expired")
+	}
+	return httpDownload("addic7ed", res, subtitleId+".srt")
+}
+
+func addic7edHeaders(provider *ProviderParams, cookie string, referer string) map[string]string {
+	headers := map[string]string{"User-Agent": provider.config.userAgent}
+	if cookie != "" {
+		headers["Cookie"] = cookie
+	}
+	if referer != "" {
+		headers["Referer"] = referer
+	}
+	return headers
+}
+
+func addic7edLanguage(name string) string {
+	if code, ok := addic7edLanguages[strings.ToLower(name)]; ok {
+		return code
+	}
+	return strings.ToLower(name)
+}
+
+func cookieHeader(cookies []*http.Cookie) string {
+	var parts []string
+	for _, c := range cookies {
+		parts = append(parts, c.Name+"="+c.Value)
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,225 @@
+package providers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+// addic7edSite serves the HTML fixtures and the downloads the tests ask
+// for, counting the requests it gets.
+type addic7edSite struct {
+	shows    []byte
+	season   []byte
+	download func(w http.ResponseWriter, r *http.Request)
+	requests atomic.Int32
+
+	mu       sync.Mutex
+	lastSeen *http.Request
+}
+
+func newAddic7edSite(t *testing.T, download func(w http.ResponseWriter, r *http.Request)) *addic7edSite {
+	return &addic7edSite{
+		shows:    fixture(t, "addic7ed/shows.html"),
+		season:   fixture(t, "addic7ed/season.html"),
+		download: download,
+	}
+}
+
+func (s *addic7edSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+	switch {
+	case r.URL.Path == "/shows.php":
+		http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: "abc", Path: "/"})
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(s.shows)
+	case r.URL.Path == "/ajax_loadShow.php":
+		s.seen(r)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(s.season)
+	case strings.HasPrefix(r.URL.Path, "/updated/") && s.download != nil:
+		s.seen(r)
+		s.download(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *addic7edSite) seen(r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSeen = r
+}
+
+// last returns the latest season listing or download request.
+func (s *addic7edSite) last() *http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastSeen
+}
+
+func addic7edConfig(dailyDownloads int) *ProviderConfig {
+	return &ProviderConfig{
+		searchUrl: "ajax_loadShow.php",
+		userAgent: "subtitlerApi test",
+		throttle:  newThrottle(0, dailyDownloads),
+		addic7ed:  newAddic7edSession(""),
+	}
+}
+
+func TestSearchAddic7ed(t *testing.T) {
+	site := newAddic7edSite(t, nil)
+	provider := testProvider(t, site, addic7edConfig(0))
+
+	subtitles, err := searchAddic7ed(provider, &models.SearchRequest{Term: "breaking bad s02e02", Language: "es,en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listing := site.last()
+	query := listing.URL.Query()
+	if query.Get("show") != "1234" || query.Get("season") != "2" {
+		t.Errorf("season listing query = %s", listing.URL.RawQuery)
+	}
+	if cookie := listing.Header.Get("Cookie"); !strings.Contains(cookie, "PHPSESSID=abc") {
+		t.Errorf("season listing cookie = %q, want the session set by the shows listing", cookie)
+	}
+
+	var got []string
+	for _, s := range subtitles {
+		got = append(got, s.ExternalId+" "+s.Language)
+	}
+	// other episodes, incomplete translations and rows without a link are left out
+	want := []string{"1234-updated-1-221-0 en", "1234-updated-5-222-0 es"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("subtitles = %v, want %v", got, want)
+	}
+	latin := subtitles[1]
+	if latin.Title != "Breaking Bad - 02x02 - Grilled" || latin.Season != 2 || latin.Episode != 2 || !latin.HearingImpaired {
+		t.Errorf("subtitle = %+v", latin)
+	}
+	if subtitles[0].HearingImpaired {
+		t.Errorf("subtitle %s is not hearing impaired", subtitles[0].ExternalId)
+	}
+
+	// the show index is cached for the session
+	before := site.requests.Load()
+	if _, err := searchAddic7ed(provider, &models.SearchRequest{Term: "The Office US", Season: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if requests := site.requests.Load() - before; requests != 1 {
+		t.Errorf("second search made %d requests, want only the season listing", requests)
+	}
+}
+
+func TestSearchAddic7edSkips(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *models.SearchRequest
+	}{
+		{"movies", &models.SearchRequest{Term: "the matrix"}},
+		{"unknown shows", &models.SearchRequest{Term: "Better Call Saul S01E01"}},
+		{"other languages", &models.SearchRequest{Term: "Breaking Bad", Season: 2, Episode: 2, Language: "pt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := testProvider(t, newAddic7edSite(t, nil), addic7edConfig(0))
+			subtitles, err := searchAddic7ed(provider, tt.req)
+			if err != nil || len(subtitles) != 0 {
+				t.Fatalf("searchAddic7ed() = %v, %v, want nothing", subtitles, err)
+			}
+		})
+	}
+}
+
+func TestDownloadAddic7ed(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        string
+		serve     func(w http.ResponseWriter, r *http.Request)
+		wantErr   error
+		wantFail  bool
+		wantTaken int
+	}{
+		{
+			name: "subtitle",
+			id:   "1234-updated-5-222-0",
+			serve: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/srt")
+				io.WriteString(w, "1\n00:00:01,000 --> 00:00:02,000\nHola\n")
+			},
+			wantTaken: 1,
+		},
+		{
+			name: "download limit page",
+			id:   "1234-updated-5-222-0",
+			serve: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				io.WriteString(w, "<html>Daily Download count exceeded</html>")
+			},
+			wantFail: true,
+		},
+		{
+			name:     "missing",
+			id:       "1234-updated-5-999-0",
+			serve:    http.NotFound,
+			wantErr:  ErrSubtitleNotFound,
+			wantFail: true,
+		},
+		{
+			name:     "invalid id",
+			id:       "1234",
+			wantErr:  ErrSubtitleNotFound,
+			wantFail: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := newAddic7edSite(t, tt.serve)
+			config := addic7edConfig(5)
+			provider := testProvider(t, site, config)
+
+			file, err := downloadAddic7ed(provider, tt.id)
+			if tt.wantFail != (err != nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("downloadAddic7ed() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				defer file.Body.Close()
+				if file.Filename != tt.id+".srt" {
+					t.Errorf("filename = %q", file.Filename)
+				}
+				if req := site.last(); req.URL.Path != "/updated/5/222/0" || req.Header.Get("Referer") != config.url+"show/1234" {
+					t.Errorf("download request = %s, referer %q", req.URL.Path, req.Header.Get("Referer"))
+				}
+			}
+			// failed downloads give their slot back
+			if remaining := config.quota().Remaining; remaining != 5-tt.wantTaken {
+				t.Errorf("quota remaining = %d, want %d", remaining, 5-tt.wantTaken)
+			}
+		})
+	}
+}
+
+func TestDownloadAddic7edQuota(t *testing.T) {
+	site := newAddic7edSite(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "1\n00:00:01,000 --> 00:00:02,000\nHola\n")
+	})
+	provider := testProvider(t, site, addic7edConfig(1))
+	file, err := downloadAddic7ed(provider, "1234-updated-5-222-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Body.Close()
+	before := site.requests.Load()
+	if _, err := downloadAddic7ed(provider, "1234-updated-5-222-0"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("second download error = %v, want ErrQuotaExceeded", err)
+	}
+	if site.requests.Load() != before {
+		t.Error("a download over the quota reached the site")
+	}
+}
@@ -0,0 +1,149 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+var ErrCircuitOpen = errors.New("provider circuit is open")
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+// breaker stops calling a provider after threshold consecutive upstream
+// failures. Once cooldown has passed a single trial call is let through
+// (half open): a success closes the circuit and a failure opens it again,
+// while a neutral outcome such as not found lets the next call be the trial.
+type breaker struct {
+	mu        sync.Mutex
+	name      string
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	trial     bool
+	metrics   *breakerMetrics
+}
+
+func newBreaker(name string, threshold int, cooldown time.Duration, metrics *breakerMetrics) *breaker {
+	return &breaker{name: name, threshold: threshold, cooldown: cooldown, state: breakerClosed, metrics: metrics}
+}
+
+// allow reports whether a call may go through, returning ErrCircuitOpen
+// while the provider is being skipped.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.transition(breakerHalfOpen)
+		b.trial = true
+		return nil
+	case breakerHalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+		return nil
+	}
+	return nil
+}
+
+// record feeds the outcome of an allowed call back into the breaker.
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	failed := upstreamFailure(err)
+	if b.state == breakerHalfOpen {
+		b.trial = false
+		switch {
+		case failed:
+			b.openedAt = time.Now()
+			b.transition(breakerOpen)
+		case err == nil:
+			b.failures = 0
+			b.transition(breakerClosed)
+		}
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold && b.state == breakerClosed {
+		b.openedAt = time.Now()
+		b.transition(breakerOpen)
+	}
+}
+
+func (b *breaker) transition(state string) {
+	b.state = state
+	b.metrics.transitions.Add(context.Background(), 1, otelmetric.WithAttributes(
+		attribute.String("provider", b.name),
+		attribute.String("state", state),
+	))
+}
+
+func (b *breaker) snapshot() *models.ProviderCircuit {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	circuit := &models.ProviderCircuit{State: b.state, Failures: b.failures}
+	if b.state == breakerOpen {
+		retryAt := b.openedAt.Add(b.cooldown).UTC()
+		circuit.RetryAt = &retryAt
+	}
+	return circuit
+}
+
+// breakerMetrics exports the circuit state of every provider as a gauge
+// (0 closed, 1 half open, 2 open) plus a counter of state transitions.
+type breakerMetrics struct {
+	transitions otelmetric.Int64Counter
+}
+
+func newBreakerMetrics(serviceName string, breakers func() []*breaker) *breakerMetrics {
+	meter := otel.Meter(serviceName)
+	transitions, _ := meter.Int64Counter(
+		"provider.circuit.transitions",
+		otelmetric.WithDescription("Number of provider circuit breaker state changes"),
+	)
+	_, _ = meter.Int64ObservableGauge(
+		"provider.circuit.state",
+		otelmetric.WithDescription("Provider circuit breaker state: 0 closed, 1 half open, 2 open"),
+		otelmetric.WithInt64Callback(func(_ context.Context, o otelmetric.Int64Observer) error {
+			for _, b := range breakers() {
+				b.mu.Lock()
+				state := b.state
+				b.mu.Unlock()
+				o.Observe(breakerStateValue(state), otelmetric.WithAttributes(attribute.String("provider", b.name)))
+			}
+			return nil
+		}),
+	)
+	return &breakerMetrics{transitions: transitions}
+}
+
+func breakerStateValue(state string) int64 {
+	switch state {
+	case breakerHalfOpen:
+		return 1
+	case breakerOpen:
+		return 2
+	}
+	return 0
+}
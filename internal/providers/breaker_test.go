@@ -0,0 +1,161 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+func TestBreaker(t *testing.T) {
+	upstream := errors.New("upstream answered 500")
+	notFound := fmt.Errorf("%w: 404", ErrSubtitleNotFound)
+
+	// each step is a call (allow then record), or cool, which moves the
+	// open circuit past its cooldown
+	type step struct {
+		err     error
+		cool    bool
+		skipped bool
+		state   string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens after threshold consecutive failures",
+			steps: []step{
+				{err: upstream, state: breakerClosed},
+				{err: upstream, state: breakerClosed},
+				{err: upstream, state: breakerOpen},
+				{skipped: true, state: breakerOpen},
+			},
+		},
+		{
+			name: "a success resets the failure count",
+			steps: []step{
+				{err: upstream, state: breakerClosed},
+				{err: upstream, state: breakerClosed},
+				{state: breakerClosed},
+				{err: upstream, state: breakerClosed},
+				{err: upstream, state: breakerClosed},
+			},
+		},
+		{
+			name: "client errors are not upstream failures",
+			steps: []step{
+				{err: notFound, state: breakerClosed},
+				{err: ErrQuotaExceeded, state: breakerClosed},
+				{err: ErrRateLimited, state: breakerClosed},
+				{err: context.Canceled, state: breakerClosed},
+				{err: notFound, state: breakerClosed},
+			},
+		},
+		{
+			name: "a successful trial closes the circuit",
+			steps: []step{
+				{err: upstream}, {err: upstream}, {err: upstream, state: breakerOpen},
+				{cool: true},
+				{state: breakerClosed},
+				{err: upstream, state: breakerClosed},
+			},
+		},
+		{
+			name: "a neutral trial leaves the circuit half open",
+			steps: []step{
+				{err: upstream}, {err: upstream}, {err: upstream, state: breakerOpen},
+				{cool: true},
+				{err: notFound, state: breakerHalfOpen},
+				{err: ErrRateLimited, state: breakerHalfOpen},
+				{err: context.Canceled, state: breakerHalfOpen},
+				{state: breakerClosed},
+			},
+		},
+		{
+			name: "a failed trial opens the circuit again",
+			steps: []step{
+				{err: upstream}, {err: upstream}, {err: upstream, state: breakerOpen},
+				{cool: true},
+				{err: upstream, state: breakerOpen},
+				{skipped: true, state: breakerOpen},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker("test", 3, time.Minute, newBreakerMetrics("test", func() []*breaker { return nil }))
+			for i, s := range tt.steps {
+				if s.cool {
+					b.openedAt = time.Now().Add(-time.Minute)
+					continue
+				}
+				err := b.allow()
+				if s.skipped {
+					if !errors.Is(err, ErrCircuitOpen) {
+						t.Fatalf("step %d: allow() = %v, want ErrCircuitOpen", i, err)
+					}
+				} else {
+					if err != nil {
+						t.Fatalf("step %d: allow() = %v", i, err)
+					}
+					b.record(s.err)
+				}
+				if s.state != "" && b.state != s.state {
+					t.Fatalf("step %d: state = %s, want %s", i, b.state, s.state)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerSingleTrial(t *testing.T) {
+	b := newBreaker("test", 1, time.Minute, newBreakerMetrics("test", func() []*breaker { return nil }))
+	b.record(errors.New("upstream answered 502"))
+	if circuit := b.snapshot(); circuit.State != breakerOpen || circuit.RetryAt == nil {
+		t.Fatalf("snapshot = %+v, want open with a retry time", circuit)
+	}
+	b.openedAt = time.Now().Add(-time.Minute)
+	if err := b.allow(); err != nil {
+		t.Fatalf("first call after cooldown: %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second call while half open = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestDetailsBreaker(t *testing.T) {
+	calls := 0
+	logger := zerolog.Nop()
+	m := &Manager{logger: &logger, handlers: map[string]Handler{
+		"subdivx": {
+			enabled: true,
+			config:  &ProviderConfig{},
+			health:  &providerHealth{},
+			breaker: newBreaker("subdivx", 2, time.Minute, newBreakerMetrics("test", func() []*breaker { return nil })),
+			Details: func(params *ProviderParams, subtitleId string) ([]models.SubComments, error) {
+				calls++
+				return nil, errors.New("subdivx answered 500")
+			},
+		},
+	}}
+
+	for range 2 {
+		if _, err := m.Details(context.Background(), "subdivx", "1"); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Details() error = %v, want the upstream failure", err)
+		}
+	}
+	if _, err := m.Details(context.Background(), "subdivx", "1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Details() error = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls reaching the provider = %d, want 2", calls)
+	}
+	if health := m.handlers["subdivx"].health.state(); health == nil || health.Error == "" {
+		t.Errorf("health = %+v, want the failure recorded", health)
+	}
+}
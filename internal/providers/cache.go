@@ -0,0 +1,84 @@
+package providers
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+type cachedSubtitle struct {
+	uid      string
+	subtitle models.Subtitle
+	results  []models.Subtitle
+	expires  time.Time
+}
+
+// resultCache remembers recent search results by uid so they can be looked
+// up again (e.g. GraphQL subtitle(uid)) without hitting the providers. It
+// holds at most size entries, evicting the least recently used first.
+//
+// The cache is shared by every caller: a uid is the provider and its
+// public subtitle id, so anyone who knows one can look up what another
+// caller's search found. Only public provider listings are stored.
+type resultCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[string]*list.Element
+	// recent orders the entries from most to least recently used
+	recent *list.List
+}
+
+func newResultCache(ttl time.Duration, size int) *resultCache {
+	return &resultCache{ttl: ttl, size: size, entries: map[string]*list.Element{}, recent: list.New()}
+}
+
+func (c *resultCache) store(results []models.Subtitle) {
+	if c.ttl <= 0 || c.size <= 0 || len(results) == 0 {
+		return
+	}
+	now := time.Now()
+	expires := now.Add(c.ttl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range results {
+		uid := results[i].Uid()
+		entry := &cachedSubtitle{uid: uid, subtitle: results[i], results: results, expires: expires}
+		if element, ok := c.entries[uid]; ok {
+			element.Value = entry
+			c.recent.MoveToFront(element)
+			continue
+		}
+		c.entries[uid] = c.recent.PushFront(entry)
+	}
+	for c.recent.Len() > c.size {
+		c.remove(c.recent.Back())
+	}
+	// expired entries left unused gather at the back
+	for back := c.recent.Back(); back != nil && now.After(back.Value.(*cachedSubtitle).expires); back = c.recent.Back() {
+		c.remove(back)
+	}
+}
+
+func (c *resultCache) get(uid string) (*cachedSubtitle, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[uid]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cachedSubtitle)
+	if time.Now().After(entry.expires) {
+		c.remove(element)
+		return nil, false
+	}
+	c.recent.MoveToFront(element)
+	return entry, true
+}
+
+func (c *resultCache) remove(element *list.Element) {
+	c.recent.Remove(element)
+	delete(c.entries, element.Value.(*cachedSubtitle).uid)
+}
@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+func cachedResults(ids ...string) []models.Subtitle {
+	var results []models.Subtitle
+	for _, id := range ids {
+		results = append(results, models.Subtitle{Provider: "podnapisi", ExternalId: id})
+	}
+	return results
+}
+
+func TestResultCache(t *testing.T) {
+	cache := newResultCache(time.Hour, 3)
+	cache.store(cachedResults("a", "b"))
+	if entry, ok := cache.get("podnapisi:a"); !ok || entry.subtitle.ExternalId != "a" || len(entry.results) != 2 {
+		t.Fatalf("get(a) = %+v, %v, want a along with its search", entry, ok)
+	}
+
+	// a was just used, so b is the least recently used one
+	cache.store(cachedResults("c", "d"))
+	if _, ok := cache.get("podnapisi:b"); ok {
+		t.Error("b outlived the cache size")
+	}
+	for _, id := range []string{"a", "c", "d"} {
+		if _, ok := cache.get("podnapisi:" + id); !ok {
+			t.Errorf("%s was evicted", id)
+		}
+	}
+	if len(cache.entries) != 3 || cache.recent.Len() != 3 {
+		t.Errorf("cache holds %d entries, %d in use order, want 3", len(cache.entries), cache.recent.Len())
+	}
+
+	// storing a uid again refreshes it in place
+	cache.store(cachedResults("c"))
+	if entry, _ := cache.get("podnapisi:c"); len(entry.results) != 1 {
+		t.Errorf("c points at a search of %d results, want the latest one", len(entry.results))
+	}
+	if cache.recent.Len() != 3 {
+		t.Errorf("cache holds %d entries, want 3", cache.recent.Len())
+	}
+}
+
+func TestResultCacheExpiry(t *testing.T) {
+	cache := newResultCache(time.Millisecond, 10)
+	cache.store(cachedResults("a", "b"))
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.get("podnapisi:a"); ok {
+		t.Error("a outlived the ttl")
+	}
+	cache.store(cachedResults("c"))
+	if _, ok := cache.entries["podnapisi:b"]; ok {
+		t.Error("expired entries are kept after a store")
+	}
+
+	disabled := newResultCache(0, 10)
+	disabled.store(cachedResults("a"))
+	if _, ok := disabled.get("podnapisi:a"); ok {
+		t.Error("a cache without a ttl stored results")
+	}
+}
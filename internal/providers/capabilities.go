@@ -0,0 +1,9 @@
+package providers
+
+// Capabilities describes what a provider supports, as listed on /providers.
+// An empty Languages list means any language code is accepted.
+type Capabilities struct {
+	Languages  []string
+	SearchKeys []string
+	Formats    []string
+}
@@ -0,0 +1,133 @@
+package providers
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/xochilpili/subtitler-api/internal/config"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/net/publicsuffix"
+)
+
+// clientPolicy is the timeout, retry and connection budget of one
+// provider's HTTP client.
+type clientPolicy struct {
+	timeout      time.Duration
+	retries      int
+	retryWait    time.Duration
+	retryMaxWait time.Duration
+	maxConns     int
+}
+
+// policyFor starts from the global defaults and applies the per-provider
+// overrides from the configuration. Throttled providers are not retried
+// unless asked to, since a retry would bypass their request interval.
+func policyFor(name string, throttled bool, config *config.Config) clientPolicy {
+	policy := clientPolicy{
+		timeout:      config.ProviderTimeout,
+		retries:      config.ProviderRetries,
+		retryWait:    config.ProviderRetryWait,
+		retryMaxWait: config.ProviderRetryMaxWait,
+		maxConns:     config.ProviderMaxConns,
+	}
+	if throttled {
+		policy.retries = 0
+	}
+	if timeout, ok := config.ProviderTimeouts[name]; ok {
+		policy.timeout = timeout
+	}
+	if retries, ok := config.ProviderRetryCounts[name]; ok {
+		policy.retries = retries
+	}
+	return policy
+}
+
+// defaultRequestRates holds providers known to ban bursts of traffic.
+var defaultRequestRates = map[string]float64{
+	"subdivx": 2,
+}
+
+// requestRate is the token bucket rate of a provider, in requests per
+// second.
+func requestRate(name string, config *config.Config) float64 {
+	if perSecond, ok := config.ProviderRequestRates[name]; ok {
+		return perSecond
+	}
+	if perSecond, ok := defaultRequestRates[name]; ok {
+		return perSecond
+	}
+	return config.ProviderRequestRate
+}
+
+func requestBurst(name string, config *config.Config) int {
+	if burst, ok := config.ProviderRequestBursts[name]; ok {
+		return burst
+	}
+	return config.ProviderRequestBurst
+}
+
+// newProviderClient builds an isolated client whose transport emits an
+// otelhttp client span per upstream request. Every attempt, retries
+// included, takes a token from the provider limiter first. GET requests
+// are retried with backoff on network errors, 429 and 5xx responses.
+func newProviderClient(name string, policy clientPolicy, limiter *limiter) *resty.Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          policy.maxConns,
+		MaxIdleConnsPerHost:   policy.maxConns,
+		MaxConnsPerHost:       policy.maxConns,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	httpClient := &http.Client{
+		Jar:     jar,
+		Timeout: policy.timeout,
+		Transport: otelhttp.NewTransport(transport,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return name + " HTTP " + r.Method
+			}),
+		),
+	}
+	return resty.NewWithClient(httpClient).
+		SetRetryCount(policy.retries).
+		SetRetryWaitTime(policy.retryWait).
+		SetRetryMaxWaitTime(policy.retryMaxWait).
+		OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+			return limiter.wait(req.Context())
+		}).
+		AddRetryCondition(func(res *resty.Response, err error) bool {
+			// a POST may spend a download or a login on every attempt
+			if res == nil || res.Request == nil || !idempotent(res.Request.Method) {
+				return false
+			}
+			if err != nil {
+				return !errors.Is(err, ErrRateLimited)
+			}
+			return res.StatusCode() == http.StatusTooManyRequests || res.StatusCode() >= http.StatusInternalServerError
+		}).
+		AddRetryHook(func(res *resty.Response, _ error) {
+			// resty leaves the unparsed body of a retried attempt open, which
+			// would hold one of the provider's few connections; the last
+			// attempt is handed to the caller, which closes it
+			if res != nil && res.RawResponse != nil && res.Request.Attempt <= policy.retries {
+				io.Copy(io.Discard, io.LimitReader(res.RawResponse.Body, 64<<10))
+				res.RawResponse.Body.Close()
+			}
+		})
+}
+
+func idempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
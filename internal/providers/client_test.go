@@ -0,0 +1,104 @@
+package providers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xochilpili/subtitler-api/internal/config"
+)
+
+// flakyServer answers 503 to the first failures requests.
+func flakyServer(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, "try again later")
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func testClientPolicy() clientPolicy {
+	// a single connection hangs the retries if an attempt leaks its body
+	return clientPolicy{timeout: 2 * time.Second, retries: 3, retryWait: time.Millisecond, retryMaxWait: time.Millisecond, maxConns: 1}
+}
+
+func TestProviderClientRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		raw          bool
+		failures     int32
+		wantRequests int32
+		wantStatus   int
+	}{
+		{"GET is retried", http.MethodGet, false, 2, 3, http.StatusOK},
+		{"unparsed GET is retried without leaking connections", http.MethodGet, true, 3, 4, http.StatusOK},
+		{"GET gives up after the retries", http.MethodGet, true, 10, 4, http.StatusServiceUnavailable},
+		{"POST is never retried", http.MethodPost, true, 1, 1, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := flakyServer(t, tt.failures)
+			client := newProviderClient("test", testClientPolicy(), newLimiter("test", 0, 0, newLimiterMetrics("test", func() []*limiter { return nil })))
+
+			res, err := client.R().SetDoNotParseResponse(tt.raw).Execute(tt.method, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.raw {
+				defer res.RawBody().Close()
+			}
+			if res.StatusCode() != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode(), tt.wantStatus)
+			}
+			if n := requests.Load(); n != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", n, tt.wantRequests)
+			}
+			if tt.raw {
+				// the last attempt is left for the caller to read
+				if _, err := io.ReadAll(res.RawBody()); err != nil {
+					t.Errorf("reading the last attempt: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyFor(t *testing.T) {
+	cfg := &config.Config{
+		ProviderTimeout:      20 * time.Second,
+		ProviderRetries:      2,
+		ProviderRetryWait:    time.Second,
+		ProviderRetryMaxWait: 5 * time.Second,
+		ProviderMaxConns:     8,
+		ProviderTimeouts:     map[string]time.Duration{"addic7ed": 30 * time.Second},
+		ProviderRetryCounts:  map[string]int{"subx": 5},
+	}
+	tests := []struct {
+		name      string
+		throttled bool
+		want      clientPolicy
+	}{
+		{"podnapisi", false, clientPolicy{timeout: 20 * time.Second, retries: 2, retryWait: time.Second, retryMaxWait: 5 * time.Second, maxConns: 8}},
+		{"subx", false, clientPolicy{timeout: 20 * time.Second, retries: 5, retryWait: time.Second, retryMaxWait: 5 * time.Second, maxConns: 8}},
+		// throttled providers are not retried, unless asked to
+		{"addic7ed", true, clientPolicy{timeout: 30 * time.Second, retries: 0, retryWait: time.Second, retryMaxWait: 5 * time.Second, maxConns: 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policyFor(tt.name, tt.throttled, cfg); got != tt.want {
+				t.Errorf("policyFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
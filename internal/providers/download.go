@@ -0,0 +1,63 @@
+package providers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"github.com/xochilpili/subtitler-api/internal/subfile"
+)
+
+// sniffSize is how much of a download is peeked at to tell its type.
+const sniffSize = 4096
+
+// httpDownload turns a response made with SetDoNotParseResponse into a
+// subtitle file once the upstream status is known to be good. The filename
+// the provider sends wins over name, and the extension is taken from the
+// content, since providers often mislabel archives and subtitles.
+func httpDownload(provider string, res *resty.Response, name string) (*models.SubtitleFile, error) {
+	body := res.RawBody()
+	switch status := res.StatusCode(); {
+	case status == http.StatusNotFound || status == http.StatusGone:
+		body.Close()
+		return nil, fmt.Errorf("%w: %s answered %d", ErrSubtitleNotFound, provider, status)
+	case status != http.StatusOK:
+		body.Close()
+		return nil, fmt.Errorf("%s download failed with status %d", provider, status)
+	}
+
+	header := res.Header()
+	file := &models.SubtitleFile{
+		Filename:    name,
+		ContentType: header.Get("Content-Type"),
+		Size:        res.RawResponse.ContentLength,
+		ETag:        header.Get("ETag"),
+	}
+	if modTime, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		file.ModTime = modTime
+	}
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		if upstream := path.Base(strings.ReplaceAll(params["filename"], "\\", "/")); upstream != "." && upstream != "/" {
+			file.Filename = upstream
+		}
+	}
+
+	reader := bufio.NewReaderSize(body, sniffSize)
+	// a short or failed peek leaves the error to the first read
+	head, _ := reader.Peek(sniffSize)
+	if ext := subfile.Sniff(head); ext != "" {
+		file.Filename = strings.TrimSuffix(file.Filename, path.Ext(file.Filename)) + "." + ext
+		file.ContentType = subfile.ContentTypes[ext]
+	}
+	file.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, body}
+	return file, nil
+}
@@ -0,0 +1,62 @@
+package providers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// enrichment bounds the follow-up requests a provider makes to fill in
+// search results: at most workers run at once, at most budget items are
+// looked up per search, and the whole stage gives up after timeout.
+type enrichment struct {
+	workers int
+	budget  int
+	timeout time.Duration
+}
+
+func newEnrichment(workers int, budget int, timeout time.Duration) *enrichment {
+	if workers < 1 {
+		workers = 1
+	}
+	return &enrichment{workers: workers, budget: budget, timeout: timeout}
+}
+
+// run calls fn for the given item indexes through the worker pool. Items
+// beyond the budget, or still queued when the deadline expires, are skipped.
+func (e *enrichment) run(ctx context.Context, items []int, fn func(ctx context.Context, index int)) {
+	if e.budget >= 0 && len(items) > e.budget {
+		items = items[:e.budget]
+	}
+	if len(items) == 0 {
+		return
+	}
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	jobs := make(chan int)
+	wg := &sync.WaitGroup{}
+	for w := 0; w < e.workers && w < len(items); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				fn(ctx, index)
+			}
+		}()
+	}
+
+feed:
+	for _, index := range items {
+		select {
+		case jobs <- index:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
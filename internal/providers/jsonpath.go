@@ -0,0 +1,104 @@
+package providers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type jsonPathStep struct {
+	key   string
+	index int
+	all   bool
+}
+
+// compileJsonPath parses the JSONPath subset used by scraper definitions:
+// $, .key, ['key'], [n], [*] and .*
+func compileJsonPath(path string) ([]jsonPathStep, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	var steps []jsonPathStep
+	for len(path) > 0 {
+		switch {
+		case strings.HasPrefix(path, ".*"):
+			steps = append(steps, jsonPathStep{all: true})
+			path = path[2:]
+		case path[0] == '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid jsonpath near %q", path)
+			}
+			steps = append(steps, jsonPathStep{key: path[:end]})
+			path = path[end:]
+		case path[0] == '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated bracket in jsonpath %q", path)
+			}
+			inner := strings.TrimSpace(path[1:end])
+			path = path[end+1:]
+			switch {
+			case inner == "*":
+				steps = append(steps, jsonPathStep{all: true})
+			case strings.HasPrefix(inner, "'") || strings.HasPrefix(inner, `"`):
+				steps = append(steps, jsonPathStep{key: strings.Trim(inner, `'"`)})
+			default:
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid jsonpath index %q", inner)
+				}
+				steps = append(steps, jsonPathStep{key: "", index: i})
+			}
+		default:
+			// relative paths such as "title" or "movie.year"
+			path = "." + path
+		}
+	}
+	return steps, nil
+}
+
+func evalJsonPath(steps []jsonPathStep, root any) []any {
+	nodes := []any{root}
+	for _, step := range steps {
+		var next []any
+		for _, node := range nodes {
+			switch v := node.(type) {
+			case map[string]any:
+				if step.all {
+					for _, child := range v {
+						next = append(next, child)
+					}
+				} else if child, ok := v[step.key]; ok && step.key != "" {
+					next = append(next, child)
+				}
+			case []any:
+				if step.all {
+					next = append(next, v...)
+				} else if step.key == "" && step.index >= 0 && step.index < len(v) {
+					next = append(next, v[step.index])
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func jsonScalar(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		return fmt.Sprint(t)
+	}
+}
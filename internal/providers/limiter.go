@@ -0,0 +1,139 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"golang.org/x/time/rate"
+)
+
+var ErrRateLimited = errors.New("provider rate limit reached")
+
+type failFastKey struct{}
+
+// FailFast marks ctx so that upstream calls over the provider rate limit
+// fail with ErrRateLimited instead of waiting for a token.
+func FailFast(ctx context.Context) context.Context {
+	return context.WithValue(ctx, failFastKey{}, true)
+}
+
+func failFast(ctx context.Context) bool {
+	v, _ := ctx.Value(failFastKey{}).(bool)
+	return v
+}
+
+// limiter is the token bucket every upstream request of a provider goes
+// through, whether it is a search, a comment lookup or a download.
+type limiter struct {
+	name    string
+	bucket  *rate.Limiter
+	metrics *limiterMetrics
+}
+
+// newLimiter allows perSecond requests with bursts of burst; a rate of
+// zero or less disables the limit.
+func newLimiter(name string, perSecond float64, burst int, metrics *limiterMetrics) *limiter {
+	limit := rate.Inf
+	if perSecond > 0 {
+		limit = rate.Limit(perSecond)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{name: name, bucket: rate.NewLimiter(limit, burst), metrics: metrics}
+}
+
+// wait takes a token, blocking until one is available unless ctx asks to
+// fail fast. Waits that would outlive the ctx deadline are refused
+// straight away.
+func (l *limiter) wait(ctx context.Context) error {
+	reservation := l.bucket.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		l.observe(ctx, "allowed", 0)
+		return nil
+	}
+	deadline, hasDeadline := ctx.Deadline()
+	if failFast(ctx) || (hasDeadline && time.Until(deadline) < delay) {
+		reservation.Cancel()
+		l.observe(ctx, "rejected", 0)
+		return ErrRateLimited
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		l.observe(ctx, "waited", delay)
+		return nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		l.observe(ctx, "rejected", 0)
+		return ctx.Err()
+	}
+}
+
+func (l *limiter) observe(ctx context.Context, outcome string, waited time.Duration) {
+	attrs := otelmetric.WithAttributes(
+		attribute.String("provider", l.name),
+		attribute.String("outcome", outcome),
+	)
+	l.metrics.requests.Add(ctx, 1, attrs)
+	if outcome == "waited" {
+		l.metrics.waits.Record(ctx, waited.Seconds(), otelmetric.WithAttributes(attribute.String("provider", l.name)))
+	}
+}
+
+// describe adds the token bucket settings to the provider rate limit.
+func (l *limiter) describe(limit *models.ProviderRateLimit) *models.ProviderRateLimit {
+	if l.bucket.Limit() == rate.Inf {
+		return limit
+	}
+	if limit == nil {
+		limit = &models.ProviderRateLimit{}
+	}
+	limit.RequestsPerSecond = float64(l.bucket.Limit())
+	limit.Burst = l.bucket.Burst()
+	limit.Available = l.bucket.Tokens()
+	return limit
+}
+
+// limiterMetrics counts requests by outcome (allowed, waited, rejected),
+// records how long callers waited and exports the tokens left in each
+// bucket, which reaching zero means the provider is saturated.
+type limiterMetrics struct {
+	requests otelmetric.Int64Counter
+	waits    otelmetric.Float64Histogram
+}
+
+func newLimiterMetrics(serviceName string, limiters func() []*limiter) *limiterMetrics {
+	meter := otel.Meter(serviceName)
+	requests, _ := meter.Int64Counter(
+		"provider.ratelimit.requests",
+		otelmetric.WithDescription("Number of upstream requests by rate limiter outcome"),
+	)
+	waits, _ := meter.Float64Histogram(
+		"provider.ratelimit.wait",
+		otelmetric.WithDescription("Time upstream requests waited for a rate limiter token"),
+		otelmetric.WithUnit("s"),
+	)
+	_, _ = meter.Float64ObservableGauge(
+		"provider.ratelimit.tokens",
+		otelmetric.WithDescription("Tokens left in the provider rate limiter bucket"),
+		otelmetric.WithFloat64Callback(func(_ context.Context, o otelmetric.Float64Observer) error {
+			for _, l := range limiters() {
+				if l.bucket.Limit() == rate.Inf {
+					continue
+				}
+				o.Observe(l.bucket.Tokens(), otelmetric.WithAttributes(attribute.String("provider", l.name)))
+			}
+			return nil
+		}),
+	)
+	return &limiterMetrics{requests: requests, waits: waits}
+}
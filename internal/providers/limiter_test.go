@@ -0,0 +1,118 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/xochilpili/subtitler-api/internal/config"
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+func testLimiter(perSecond float64, burst int) *limiter {
+	return newLimiter("test", perSecond, burst, newLimiterMetrics("test", func() []*limiter { return nil }))
+}
+
+func TestLimiterWait(t *testing.T) {
+	deadline := func() context.Context {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		t.Cleanup(cancel)
+		return ctx
+	}
+	canceled := func() context.Context {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return ctx
+	}
+	tests := []struct {
+		name     string
+		ctx      func() context.Context
+		wantErr  error
+		wantWait time.Duration
+	}{
+		{"waits for a token", context.Background, nil, 50 * time.Millisecond},
+		{"fails fast when asked", func() context.Context { return FailFast(context.Background()) }, ErrRateLimited, 0},
+		{"refuses waits past the deadline", deadline, ErrRateLimited, 0},
+		{"stops waiting once canceled", canceled, context.Canceled, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// one token every 100ms, and the burst is spent
+			l := testLimiter(10, 1)
+			if err := l.wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			err := l.wait(tt.ctx())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("wait() = %v, want %v", err, tt.wantErr)
+			}
+			if waited := time.Since(start); waited < tt.wantWait {
+				t.Errorf("waited %s, want at least %s", waited, tt.wantWait)
+			}
+			// a refused call gives its reservation back
+			if err != nil && l.bucket.Tokens() < -0.5 {
+				t.Errorf("tokens = %.2f after a refused wait", l.bucket.Tokens())
+			}
+		})
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	l := testLimiter(0, 0)
+	for range 100 {
+		if err := l.wait(FailFast(context.Background())); err != nil {
+			t.Fatalf("wait() = %v without a limit", err)
+		}
+	}
+	if limit := l.describe(nil); limit != nil {
+		t.Errorf("describe() = %+v, want nothing to report", limit)
+	}
+
+	limit := testLimiter(2, 5).describe(&models.ProviderRateLimit{IntervalMs: 500})
+	if limit.IntervalMs != 500 || limit.RequestsPerSecond != 2 || limit.Burst != 5 || limit.Available != 5 {
+		t.Errorf("describe() = %+v", limit)
+	}
+}
+
+func TestProviderClientLimiter(t *testing.T) {
+	server, requests := flakyServer(t, 0)
+	client := newProviderClient("test", testClientPolicy(), testLimiter(0.1, 1))
+
+	if res, err := client.R().Get(server.URL); err != nil || res.StatusCode() != http.StatusOK {
+		t.Fatalf("first request = %v, %v", res, err)
+	}
+	// the spent bucket is not retried around
+	if _, err := client.R().SetContext(FailFast(context.Background())).Get(server.URL); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("second request error = %v, want ErrRateLimited", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
+
+func TestRequestRates(t *testing.T) {
+	cfg := &config.Config{
+		ProviderRequestRate:   5,
+		ProviderRequestBurst:  3,
+		ProviderRequestRates:  map[string]float64{"podnapisi": 1},
+		ProviderRequestBursts: map[string]int{"subdivx": 1},
+	}
+	tests := []struct {
+		name      string
+		wantRate  float64
+		wantBurst int
+	}{
+		{"opensubtitles", 5, 3},
+		{"podnapisi", 1, 3},
+		// subdivx bans bursts unless told otherwise
+		{"subdivx", 2, 1},
+	}
+	for _, tt := range tests {
+		if rate, burst := requestRate(tt.name, cfg), requestBurst(tt.name, cfg); rate != tt.wantRate || burst != tt.wantBurst {
+			t.Errorf("%s: rate %v burst %d, want %v and %d", tt.name, rate, burst, tt.wantRate, tt.wantBurst)
+		}
+	}
+}
@@ -0,0 +1,453 @@
+package providers
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	localContentTypes = map[string]string{
+		".srt": "application/x-subrip",
+		".ass": "text/x-ssa",
+		".vtt": "text/vtt",
+		".zip": "application/zip",
+	}
+	localLanguages = map[string]string{
+		"es": "es", "spa": "es", "esp": "es", "spanish": "es", "espanol": "es", "latino": "es",
+		"en": "en", "eng": "en", "english": "en",
+	}
+	localTokenRe = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+	localYearRe  = regexp.MustCompile(`\b(19\d{2}|20\d{2})\b`)
+)
+
+type localEntry struct {
+	id       string
+	path     string
+	tokens   []string
+	subtitle models.Subtitle
+}
+
+// localLibrary is an in-memory inverted index over a directory tree of
+// subtitle files, kept up to date with fsnotify and, since fsnotify misses
+// changes on network filesystems, a periodic rescan.
+type localLibrary struct {
+	root string
+	// realRoot is root with its symlinks resolved, which every indexed
+	// file must stay inside
+	realRoot string
+	language string
+	rescan   time.Duration
+	logger   *zerolog.Logger
+	mu       sync.RWMutex
+	entries  map[string]*localEntry
+	byPath   map[string]string
+	index    map[string]map[string]struct{}
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+}
+
+func newLocalLibrary(root string, language string, rescan time.Duration, logger *zerolog.Logger) *localLibrary {
+	return &localLibrary{
+		root:     filepath.Clean(root),
+		realRoot: filepath.Clean(root),
+		language: language,
+		rescan:   rescan,
+		logger:   logger,
+		entries:  map[string]*localEntry{},
+		byPath:   map[string]string{},
+		index:    map[string]map[string]struct{}{},
+		done:     make(chan struct{}),
+	}
+}
+
+// start indexes the whole tree and watches it for changes.
+func (l *localLibrary) start() error {
+	realRoot, err := filepath.EvalSymlinks(l.root)
+	if err != nil {
+		return err
+	}
+	l.realRoot = realRoot
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	l.watcher = watcher
+	if err := l.addTree(l.root); err != nil {
+		watcher.Close()
+		return err
+	}
+	go l.watch()
+	if l.rescan > 0 {
+		go l.rescanLoop()
+	}
+	l.logger.Info().Msgf("indexed %d local subtitles from %s", l.size(), l.root)
+	return nil
+}
+
+func (l *localLibrary) close() error {
+	if l.watcher == nil {
+		return nil
+	}
+	close(l.done)
+	return l.watcher.Close()
+}
+
+func (l *localLibrary) size() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.entries)
+}
+
+func (l *localLibrary) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			l.logger.Warn().Err(err).Msgf("skipping %s", path)
+			return nil
+		}
+		if d.IsDir() {
+			// the rescan still picks up changes below directories that
+			// cannot be watched
+			if err := l.watcher.Add(path); err != nil {
+				l.logger.Warn().Err(err).Msgf("not watching %s", path)
+			}
+			return nil
+		}
+		l.add(path)
+		return nil
+	})
+}
+
+// rescanLoop walks the tree every rescan interval until the library closes.
+func (l *localLibrary) rescanLoop() {
+	ticker := time.NewTicker(l.rescan)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			if err := l.resync(); err != nil {
+				l.logger.Err(err).Msgf("error while rescanning %s", l.root)
+			}
+		}
+	}
+}
+
+// resync indexes files added since the last scan and drops the ones that
+// are gone.
+func (l *localLibrary) resync() error {
+	if err := l.addTree(l.root); err != nil {
+		return err
+	}
+	l.mu.RLock()
+	paths := make([]string, 0, len(l.byPath))
+	for path := range l.byPath {
+		paths = append(paths, path)
+	}
+	l.mu.RUnlock()
+	for _, path := range paths {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			l.remove(path)
+		}
+	}
+	return nil
+}
+
+func (l *localLibrary) watch() {
+	for {
+		select {
+		case event, ok := <-l.watcher.Events:
+			if !ok {
+				return
+			}
+			switch {
+			case event.Has(fsnotify.Create):
+				info, err := os.Stat(event.Name)
+				if err != nil {
+					continue
+				}
+				if info.IsDir() {
+					// a linked directory would be walked wherever it leads
+					if _, err := l.resolve(event.Name); err != nil {
+						l.logger.Warn().Err(err).Msgf("not indexing %s", event.Name)
+						continue
+					}
+					if err := l.addTree(event.Name); err != nil {
+						l.logger.Err(err).Msgf("error while indexing %s", event.Name)
+					}
+					continue
+				}
+				l.add(event.Name)
+			case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+				l.remove(event.Name)
+			}
+		case err, ok := <-l.watcher.Errors:
+			if !ok {
+				return
+			}
+			l.logger.Err(err).Msg("local library watcher error")
+		}
+	}
+}
+
+func (l *localLibrary) add(path string) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if _, ok := localContentTypes[ext]; !ok {
+		return
+	}
+	rel, err := filepath.Rel(l.root, path)
+	if err != nil {
+		return
+	}
+	if _, err := l.resolve(path); err != nil {
+		l.logger.Warn().Err(err).Msgf("skipping %s", path)
+		return
+	}
+	sum := sha1.Sum([]byte(rel))
+	id := hex.EncodeToString(sum[:8])
+	entry := l.parse(id, path, rel)
+	// the numeric id comes from the same hash, so it stays put across
+	// searches and restarts
+	entry.subtitle.Id = int(binary.BigEndian.Uint32(sum[:4]) >> 1)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unindex(id)
+	l.entries[id] = entry
+	l.byPath[path] = id
+	for _, token := range entry.tokens {
+		if l.index[token] == nil {
+			l.index[token] = map[string]struct{}{}
+		}
+		l.index[token][id] = struct{}{}
+	}
+}
+
+// remove drops a file, or every file below a removed directory.
+func (l *localLibrary) remove(path string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prefix := path + string(filepath.Separator)
+	for p, id := range l.byPath {
+		if p == path || strings.HasPrefix(p, prefix) {
+			l.unindex(id)
+		}
+	}
+}
+
+func (l *localLibrary) unindex(id string) {
+	entry, ok := l.entries[id]
+	if !ok {
+		return
+	}
+	for _, token := range entry.tokens {
+		delete(l.index[token], id)
+		if len(l.index[token]) == 0 {
+			delete(l.index, token)
+		}
+	}
+	delete(l.byPath, entry.path)
+	delete(l.entries, id)
+}
+
+// resolve follows the symlinks of path and refuses targets outside the
+// library, which would serve any file the process can read.
+func (l *localLibrary) resolve(path string) (string, error) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(l.realRoot, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s leads outside %s", path, l.root)
+	}
+	return target, nil
+}
+
+func (l *localLibrary) parse(id string, path string, rel string) *localEntry {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	title := strings.TrimSpace(localTokenRe.ReplaceAllString(name, " "))
+	tokens := tokenize(filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel))))
+
+	language := l.language
+	for _, token := range tokenize(name) {
+		if code, ok := localLanguages[token]; ok {
+			language = code
+		}
+	}
+	itemType, season, episode := parseTitle(title)
+	group, quality, resolution, duration := parseExtra(name)
+	var year int
+	if y := Parse(name, "year"); y != nil {
+		year, _ = strconv.Atoi(y[0])
+	} else if y := localYearRe.FindString(name); y != "" {
+		year, _ = strconv.Atoi(y)
+	}
+
+	return &localEntry{
+		id:     id,
+		path:   path,
+		tokens: tokens,
+		subtitle: models.Subtitle{
+			Provider:    "local",
+			Type:        itemType,
+			ExternalId:  id,
+			Title:       title,
+			Description: filepath.ToSlash(rel),
+			Language:    language,
+			Group:       group,
+			Quality:     quality,
+			Resolution:  resolution,
+			Duration:    duration,
+			Year:        year,
+			Season:      season,
+			Episode:     episode,
+		},
+	}
+}
+
+// localLanguageCodes lists the languages a local library can tag files with.
+func localLanguageCodes(fallback string) []string {
+	var codes []string
+	for _, code := range localLanguages {
+		codes = append(codes, code)
+	}
+	if fallback != "" {
+		codes = append(codes, fallback)
+	}
+	slices.Sort(codes)
+	return slices.Compact(codes)
+}
+
+func tokenize(text string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	for _, token := range localTokenRe.Split(strings.ToLower(text), -1) {
+		if token == "" || seen[token] {
+			continue
+		}
+		seen[token] = true
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// search returns the entries containing every term token.
+func (l *localLibrary) search(req *models.SearchRequest) []models.Subtitle {
+	tokens := tokenize(req.Term)
+	if len(tokens) == 0 {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var matches []*localEntry
+	for id := range l.index[tokens[0]] {
+		found := true
+		for _, token := range tokens[1:] {
+			if _, ok := l.index[token][id]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			matches = append(matches, l.entries[id])
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].path < matches[j].path })
+
+	var subtitles []models.Subtitle
+	for _, entry := range matches {
+		s := entry.subtitle
+		if req.Year > 0 && s.Year != 0 && s.Year != req.Year {
+			continue
+		}
+		if req.Season > 0 && s.Season != req.Season {
+			continue
+		}
+		if req.Episode > 0 && s.Episode != req.Episode {
+			continue
+		}
+		if req.Language != "" && !strings.Contains(req.Language, s.Language) {
+			continue
+		}
+		subtitles = append(subtitles, s)
+	}
+	return subtitles
+}
+
+func (l *localLibrary) open(id string) (*os.File, *localEntry, error) {
+	l.mu.RLock()
+	entry, ok := l.entries[id]
+	l.mu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: no local subtitle %q", ErrSubtitleNotFound, id)
+	}
+	// a link may have been pointed elsewhere since it was indexed
+	target, err := l.resolve(entry.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%w: %s was removed", ErrSubtitleNotFound, entry.path)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrSubtitleNotFound, err)
+	}
+	f, err := os.Open(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%w: %s was removed", ErrSubtitleNotFound, entry.path)
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return f, entry, nil
+}
+
+func searchLocal(provider *ProviderParams, req *models.SearchRequest) ([]models.Subtitle, error) {
+	tracer := otel.Tracer("local")
+	_, span := tracer.Start(provider.ctx, "Local.Search")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("query", req.Term))
+	subtitles := provider.config.library.search(req)
+	span.SetAttributes(attribute.Int("subtitle_count", len(subtitles)))
+	provider.logger.Info().Msgf("returned %d subtitles", len(subtitles))
+	return subtitles, nil
+}
+
+func downloadLocal(provider *ProviderParams, subtitleId string) (*models.SubtitleFile, error) {
+	f, entry, err := provider.config.library.open(subtitleId)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(entry.path))
+	file := &models.SubtitleFile{
+		Body:        f,
+		Filename:    filepath.Base(entry.path),
+		ContentType: localContentTypes[ext],
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+	}
+	provider.logger.Info().Msgf("downloading file: %s", file.Filename)
+	return file, nil
+}
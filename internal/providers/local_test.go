@@ -0,0 +1,215 @@
+package providers
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+const localSrt = "1\n00:00:01,000 --> 00:00:02,000\nHola\n"
+
+// writeFiles creates the files under root, along with their directories.
+func writeFiles(t *testing.T, root string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(localSrt), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func startLibrary(t *testing.T, root string) *localLibrary {
+	t.Helper()
+	logger := zerolog.Nop()
+	library := newLocalLibrary(root, "es", 0, &logger)
+	if err := library.start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { library.close() })
+	return library
+}
+
+// eventually polls the library until found matches what term finds, as
+// fsnotify events arrive asynchronously.
+func eventually(t *testing.T, library *localLibrary, term string, found int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := len(library.search(&models.SearchRequest{Term: term}))
+		if got == found {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("search(%q) found %d subtitles, want %d", term, got, found)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLocalLibrarySearch(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root,
+		"Movies/The.Matrix.1999.1080p.BluRay.x264-GRP.eng.srt",
+		"Movies/The.Matrix.Reloaded.2003.srt",
+		"Shows/Breaking Bad/Breaking.Bad.S01E02.720p.srt",
+		"Shows/Breaking Bad/Breaking.Bad.S01E03.720p.srt",
+		"Shows/notes.txt",
+	)
+	library := startLibrary(t, root)
+	if size := library.size(); size != 4 {
+		t.Fatalf("indexed %d files, want the 4 subtitles", size)
+	}
+
+	tests := []struct {
+		name string
+		req  models.SearchRequest
+		want []string
+	}{
+		{"every term token", models.SearchRequest{Term: "matrix"}, []string{"Movies/The.Matrix.1999.1080p.BluRay.x264-GRP.eng.srt", "Movies/The.Matrix.Reloaded.2003.srt"}},
+		{"year", models.SearchRequest{Term: "the matrix", Year: 1999}, []string{"Movies/The.Matrix.1999.1080p.BluRay.x264-GRP.eng.srt"}},
+		{"directories are searched too", models.SearchRequest{Term: "shows bad", Season: 1, Episode: 3}, []string{"Shows/Breaking Bad/Breaking.Bad.S01E03.720p.srt"}},
+		{"language", models.SearchRequest{Term: "matrix", Language: "en"}, []string{"Movies/The.Matrix.1999.1080p.BluRay.x264-GRP.eng.srt"}},
+		{"no match", models.SearchRequest{Term: "matrix revolutions"}, nil},
+		{"other files are not indexed", models.SearchRequest{Term: "notes"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, s := range library.search(&tt.req) {
+				got = append(got, s.Description)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("search() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("search() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	matrix := library.search(&models.SearchRequest{Term: "matrix 1999"})[0]
+	if matrix.Year != 1999 || matrix.Language != "en" || matrix.Provider != "local" {
+		t.Errorf("subtitle = %+v", matrix)
+	}
+	if episode := library.search(&models.SearchRequest{Term: "breaking s01e02"})[0]; episode.Language != "es" || episode.Season != 1 || episode.Episode != 2 {
+		t.Errorf("subtitle = %+v, want S01E02 in the default language", episode)
+	}
+}
+
+func TestLocalLibraryStableIds(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "b/The.Matrix.1999.srt")
+	first := startLibrary(t, root).search(&models.SearchRequest{Term: "matrix"})[0]
+
+	// neither an earlier match nor a fresh index, as after a restart, moves
+	// the ids
+	writeFiles(t, root, "a/The.Matrix.Reloaded.2003.srt")
+	results := startLibrary(t, root).search(&models.SearchRequest{Term: "matrix"})
+	if len(results) != 2 || results[1].Description != first.Description {
+		t.Fatalf("search() = %+v", results)
+	}
+	if results[1].Id != first.Id || results[1].ExternalId != first.ExternalId {
+		t.Errorf("ids changed from %d %s to %d %s", first.Id, first.ExternalId, results[1].Id, results[1].ExternalId)
+	}
+	if results[0].Id == results[1].Id {
+		t.Errorf("two files share the id %d", results[0].Id)
+	}
+}
+
+func TestLocalLibraryWatch(t *testing.T) {
+	root := t.TempDir()
+	library := startLibrary(t, root)
+
+	writeFiles(t, root, "The.Matrix.1999.srt")
+	eventually(t, library, "matrix", 1)
+
+	// new directories are indexed and watched
+	writeFiles(t, root, "Shows/Breaking.Bad.S01E02.srt")
+	eventually(t, library, "breaking", 1)
+	writeFiles(t, root, "Shows/Breaking.Bad.S01E03.srt")
+	eventually(t, library, "breaking", 2)
+
+	if err := os.Remove(filepath.Join(root, "The.Matrix.1999.srt")); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, library, "matrix", 0)
+	if err := os.RemoveAll(filepath.Join(root, "Shows")); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, library, "breaking", 0)
+}
+
+func TestLocalLibrarySymlinks(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	writeFiles(t, root, "The.Matrix.1999.srt")
+	writeFiles(t, outside, "secret.srt", "leak/Leaked.Movie.srt")
+	links := map[string]string{
+		"Inside.Link.srt":    filepath.Join(root, "The.Matrix.1999.srt"),
+		"Outside.Secret.srt": filepath.Join(outside, "secret.srt"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("symlinks are not available: %v", err)
+		}
+	}
+	library := startLibrary(t, root)
+
+	if found := library.search(&models.SearchRequest{Term: "inside link"}); len(found) != 1 {
+		t.Errorf("a link inside the library was not indexed: %v", found)
+	}
+	if found := library.search(&models.SearchRequest{Term: "outside secret"}); len(found) != 0 {
+		t.Errorf("a link leading outside the library was indexed: %v", found)
+	}
+
+	// a linked directory created later is not walked either
+	if err := os.Symlink(filepath.Join(outside, "leak"), filepath.Join(root, "leak")); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, root, "Canary.srt")
+	eventually(t, library, "canary", 1)
+	if found := library.search(&models.SearchRequest{Term: "leaked"}); len(found) != 0 {
+		t.Errorf("a linked directory outside the library was indexed: %v", found)
+	}
+
+	// nor is a link pointed elsewhere once indexed; the library below is
+	// not watched, so its index keeps the old link
+	logger := zerolog.Nop()
+	unwatched := newLocalLibrary(root, "es", 0, &logger)
+	unwatched.realRoot, _ = filepath.EvalSymlinks(root)
+	path := filepath.Join(root, "Inside.Link.srt")
+	unwatched.add(path)
+	link := unwatched.search(&models.SearchRequest{Term: "inside link"})
+	if len(link) != 1 {
+		t.Fatalf("search() = %v, want the link", link)
+	}
+	f, _, err := unwatched.open(link[0].ExternalId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(f)
+	f.Close()
+	if string(body) != localSrt {
+		t.Errorf("opened %q, want the linked subtitle", body)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.srt"), path); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := unwatched.open(link[0].ExternalId); !errors.Is(err, ErrSubtitleNotFound) {
+		t.Errorf("open() of a link leading outside error = %v, want ErrSubtitleNotFound", err)
+	}
+}
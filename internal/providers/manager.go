@@ -2,8 +2,9 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
+	"sort"
 	"strings"
 	"sync"
 
@@ -15,14 +16,43 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 )
 
+var (
+	ErrUnknownProvider  = errors.New("unknown provider")
+	ErrQuotaExceeded    = errors.New("provider download quota exceeded")
+	ErrSubtitleNotFound = errors.New("subtitle not found")
+	ErrNoDetails        = errors.New("provider does not expose subtitle details")
+	// ErrPartialResults comes along with the results fetched before a
+	// later page failed.
+	ErrPartialResults = errors.New("provider results are partial")
+)
+
 type ProviderConfig struct {
-	url         string
-	searchUrl   string
-	userAgent   string
-	debug       bool
-	apiKey      string
-	apiUsername string
-	apiPassword string
+	url           string
+	searchUrl     string
+	userAgent     string
+	debug         bool
+	maxPages      int
+	apiKey        string
+	apiUsername   string
+	apiPassword   string
+	throttle      *throttle
+	addic7ed      *addic7edSession
+	subdivx       *subdivxSession
+	opensubtitles *openSubtitlesSession
+	enrich        *enrichment
+	library       *localLibrary
+	scraper       *scraper
+}
+
+// quota reports the download allowance left for providers that track one.
+func (c *ProviderConfig) quota() *models.ProviderQuota {
+	switch {
+	case c.opensubtitles != nil:
+		return c.opensubtitles.quota()
+	case c.throttle != nil:
+		return c.throttle.quota()
+	}
+	return nil
 }
 
 type ProviderParams struct {
@@ -32,24 +62,33 @@ type ProviderParams struct {
 	ctx    context.Context
 }
 
-type Search func(provider *ProviderParams, query string) []models.Subtitle
-type Download func(params *ProviderParams, subtitleId string) (io.ReadCloser, string, string, error)
+type Search func(provider *ProviderParams, req *models.SearchRequest) ([]models.Subtitle, error)
+type Download func(params *ProviderParams, subtitleId string) (*models.SubtitleFile, error)
+type Details func(params *ProviderParams, subtitleId string) ([]models.SubComments, error)
 type Handler struct {
-	enabled  bool
-	config   *ProviderConfig
-	Search   Search
-	Download Download
+	enabled      bool
+	config       *ProviderConfig
+	health       *providerHealth
+	breaker      *breaker
+	limiter      *limiter
+	client       *resty.Client
+	Capabilities Capabilities
+	Search       Search
+	Download     Download
+	Details      Details
+	Probe        Probe
 }
 
 type Manager struct {
 	config   *config.Config
 	logger   *zerolog.Logger
-	r        *resty.Client
 	handlers map[string]Handler
+	cache    *resultCache
+
+	stopProbes context.CancelFunc
 }
 
 func New(config *config.Config, logger *zerolog.Logger) *Manager {
-	r := resty.New()
 	handlers := map[string]Handler{
 		"subdivx": {
 			enabled: false,
@@ -59,9 +98,18 @@ func New(config *config.Config, logger *zerolog.Logger) *Manager {
 				userAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36",
 				debug:     config.Debug,
 				apiKey:    "",
+				subdivx:   newSubdivxSession(config.SubdivxSessionTtl),
+				enrich:    newEnrichment(config.SubdivxCommentWorkers, config.SubdivxCommentBudget, config.SubdivxCommentTimeout),
+			},
+			Capabilities: Capabilities{
+				Languages:  []string{"es"},
+				SearchKeys: []string{models.SearchKeyText},
+				Formats:    []string{"rar", "zip"},
 			},
 			Search:   searchDivx,
 			Download: downloadDivxSubtitle,
+			Probe:    probeSite,
+			Details:  detailsDivx,
 		},
 		"subx": {
 			enabled: true,
@@ -72,64 +120,259 @@ func New(config *config.Config, logger *zerolog.Logger) *Manager {
 				debug:     config.Debug,
 				apiKey:    strings.TrimSpace(config.SubxApiKey),
 			},
+			Capabilities: Capabilities{
+				Languages:  []string{"es"},
+				SearchKeys: []string{models.SearchKeyText},
+				Formats:    []string{"srt", "zip"},
+			},
 			Search:   searchSubX,
 			Download: downloadSubX,
+			Probe:    probeSubX,
 		},
 		"opensubtitles": {
 			enabled: false,
 			config: &ProviderConfig{
-				url:         "https://api.opensubtitles.com/",
-				searchUrl:   "api/v1/subtitles",
-				userAgent:   "subtitlerApi v1.0.0",
-				debug:       config.Debug,
-				apiKey:      strings.TrimSpace(config.OpenSubtitlesApiKey),
-				apiUsername: strings.TrimSpace(config.OpenSubtitlesApiUsername),
-				apiPassword: strings.TrimSpace(config.OpenSubtitlesApiPassword),
+				url:           "https://api.opensubtitles.com/",
+				searchUrl:     "api/v1/subtitles",
+				userAgent:     "subtitlerApi v1.0.0",
+				debug:         config.Debug,
+				apiKey:        strings.TrimSpace(config.OpenSubtitlesApiKey),
+				apiUsername:   strings.TrimSpace(config.OpenSubtitlesApiUsername),
+				apiPassword:   strings.TrimSpace(config.OpenSubtitlesApiPassword),
+				opensubtitles: newOpenSubtitlesSession(),
+				maxPages:      config.OpenSubtitlesMaxPages,
+			},
+			Capabilities: Capabilities{
+				SearchKeys: []string{models.SearchKeyText, models.SearchKeyYear, models.SearchKeyEpisode, models.SearchKeyLanguage},
+				Formats:    []string{"srt"},
 			},
 			Search:   searchOpenSubtitles,
 			Download: downloadOpenSubtitle,
+			Probe:    probeOpenSubtitles,
+		},
+		"podnapisi": {
+			enabled: config.PodnapisiEnabled,
+			config: &ProviderConfig{
+				url:       strings.TrimSuffix(config.PodnapisiUrl, "/") + "/",
+				searchUrl: "subtitles/search/advanced",
+				userAgent: "subtitlerApi v1.0.0",
+				debug:     config.Debug,
+			},
+			Capabilities: Capabilities{
+				SearchKeys: []string{models.SearchKeyText, models.SearchKeyYear, models.SearchKeyEpisode, models.SearchKeyLanguage},
+				Formats:    []string{"zip"},
+			},
+			Search:   searchPodnapisi,
+			Download: downloadPodnapisi,
+			Probe:    probeSite,
+		},
+		"addic7ed": {
+			enabled: config.Addic7edEnabled,
+			config: &ProviderConfig{
+				url:       strings.TrimSuffix(config.Addic7edUrl, "/") + "/",
+				searchUrl: "ajax_loadShow.php",
+				userAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36",
+				debug:     config.Debug,
+				throttle:  newThrottle(config.Addic7edRequestInterval, config.Addic7edDailyDownloads),
+				addic7ed:  newAddic7edSession(strings.TrimSpace(config.Addic7edSessionCookie)),
+			},
+			Capabilities: Capabilities{
+				Languages:  []string{"de", "en", "es", "fr", "it", "pt"},
+				SearchKeys: []string{models.SearchKeyEpisode, models.SearchKeyLanguage},
+				Formats:    []string{"srt"},
+			},
+			Search:   searchAddic7ed,
+			Download: downloadAddic7ed,
+			Probe:    probeAddic7ed,
 		},
 	}
-	return &Manager{
+	if config.LocalLibraryPath != "" {
+		library := newLocalLibrary(config.LocalLibraryPath, config.LocalLibraryLanguage, config.LocalLibraryRescan, logger)
+		if err := library.start(); err != nil {
+			logger.Err(err).Msgf("error while indexing local library %s", config.LocalLibraryPath)
+		} else {
+			handlers["local"] = Handler{
+				enabled: true,
+				config:  &ProviderConfig{debug: config.Debug, library: library},
+				Capabilities: Capabilities{
+					Languages:  localLanguageCodes(config.LocalLibraryLanguage),
+					SearchKeys: []string{models.SearchKeyText, models.SearchKeyYear, models.SearchKeyEpisode, models.SearchKeyLanguage},
+					Formats:    []string{"srt", "ass", "vtt", "zip"},
+				},
+				Search:   searchLocal,
+				Download: downloadLocal,
+				Probe:    probeLocal,
+			}
+		}
+	}
+	if config.ScrapersPath != "" {
+		scrapers, err := loadScrapers(config.ScrapersPath)
+		if err != nil {
+			logger.Err(err).Msgf("error while loading scraper definitions from %s", config.ScrapersPath)
+		}
+		for _, s := range scrapers {
+			if _, exists := handlers[s.def.Name]; exists {
+				logger.Error().Msgf("scraper %s clashes with an existing provider, skipping", s.def.Name)
+				continue
+			}
+			handlers[s.def.Name] = Handler{
+				enabled:      s.def.Enabled == nil || *s.def.Enabled,
+				config:       &ProviderConfig{url: s.base.String(), debug: config.Debug, scraper: s},
+				Capabilities: s.capabilities(),
+				Search:       searchScraper,
+				Download:     downloadScraper,
+				Probe:        probeSite,
+			}
+		}
+	}
+	var breakers []*breaker
+	var limiters []*limiter
+	metrics := newBreakerMetrics(config.ServiceName, func() []*breaker { return breakers })
+	limiterMetrics := newLimiterMetrics(config.ServiceName, func() []*limiter { return limiters })
+	for name, handler := range handlers {
+		handler.health = &providerHealth{}
+		handler.breaker = newBreaker(name, config.CircuitFailureThreshold, config.CircuitCooldown, metrics)
+		breakers = append(breakers, handler.breaker)
+		handler.limiter = newLimiter(name, requestRate(name, config), requestBurst(name, config), limiterMetrics)
+		limiters = append(limiters, handler.limiter)
+		handler.client = newProviderClient(name, policyFor(name, handler.config.throttle != nil, config), handler.limiter)
+		handlers[name] = handler
+	}
+	m := &Manager{
 		config:   config,
 		logger:   logger,
-		r:        r,
 		handlers: handlers,
+		cache:    newResultCache(config.SearchCacheTtl, config.SearchCacheSize),
 	}
+	m.startProbes()
+	return m
 }
 
-func (m *Manager) Search(ctx context.Context, provider string, query string, postFilter *models.PostFilters) []models.Subtitle {
+// Search queries the matching enabled providers; providers whose circuit
+// is open or whose search failed are reported as skipped.
+func (m *Manager) Search(ctx context.Context, provider string, req *models.SearchRequest, postFilter *models.PostFilters) ([]models.Subtitle, []models.SkippedProvider) {
 	tracer := otel.Tracer(m.config.ServiceName)
 	ctx, span := tracer.Start(ctx, "Manager.Search")
 	defer span.End()
 
 	span.SetAttributes(
 		attribute.String("provider", provider),
-		attribute.String("query", query),
+		attribute.String("query", req.Term),
 	)
 
-	items := m.search(ctx, provider, query)
+	items, skipped := m.search(ctx, provider, req)
 	_, spanFilter := tracer.Start(ctx, "Manager.PostFiltering")
 	filtered := m.postFiltering(postFilter, items)
+	sortSubtitles(filtered, postFilter.Sort, postFilter.Order)
 	spanFilter.SetAttributes(attribute.Int("result_count", len(filtered)))
 	spanFilter.End()
 
-	return filtered
+	m.cache.store(filtered)
+	return filtered, skipped
 }
 
-func (m *Manager) Download(ctx context.Context, provider string, subtitleId string) (io.ReadCloser, string, string, error) {
-	return m.handlers[provider].Download(&ProviderParams{
-		config: m.handlers[provider].config,
+// Close releases background resources held by the providers.
+func (m *Manager) Close() {
+	m.stopProbes()
+	for name, handler := range m.handlers {
+		if handler.config.library != nil {
+			if err := handler.config.library.close(); err != nil {
+				m.logger.Err(err).Msgf("error while closing %s provider", name)
+			}
+		}
+	}
+}
+
+// Subtitle returns a subtitle seen in a recent search, along with the
+// other results of that search.
+func (m *Manager) Subtitle(uid string) (*models.Subtitle, []models.Subtitle, bool) {
+	entry, ok := m.cache.get(uid)
+	if !ok {
+		return nil, nil, false
+	}
+	return &entry.subtitle, entry.results, true
+}
+
+// Download opens a subtitle file on a provider; the caller closes its body.
+func (m *Manager) Download(ctx context.Context, provider string, subtitleId string) (*models.SubtitleFile, error) {
+	handler, ok := m.handlers[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+	if err := handler.breaker.allow(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, provider)
+	}
+	file, err := handler.Download(&ProviderParams{
+		config: handler.config,
 		logger: m.logger,
-		r:      m.r,
+		r:      handler.client,
 		ctx:    ctx,
 	}, subtitleId)
+	handler.breaker.record(err)
+	if err == nil || upstreamFailure(err) {
+		handler.health.record(err)
+	}
+	return file, err
 }
 
-func (m *Manager) search(ctx context.Context, provider string, query string) []models.Subtitle {
+// Details fetches the comments a provider holds for a subtitle.
+func (m *Manager) Details(ctx context.Context, provider string, subtitleId string) ([]models.SubComments, error) {
+	handler, ok := m.handlers[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+	if handler.Details == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoDetails, provider)
+	}
+	if err := handler.breaker.allow(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, provider)
+	}
+	comments, err := handler.Details(&ProviderParams{
+		config: handler.config,
+		logger: m.logger,
+		r:      handler.client,
+		ctx:    ctx,
+	}, subtitleId)
+	handler.breaker.record(err)
+	if err == nil || upstreamFailure(err) {
+		handler.health.record(err)
+	}
+	return comments, err
+}
+
+func (m *Manager) Providers() []models.ProviderInfo {
+	var items []models.ProviderInfo
+	for name, handler := range m.handlers {
+		info := models.ProviderInfo{
+			Name:       name,
+			Enabled:    handler.enabled,
+			Languages:  models.NonNil(handler.Capabilities.Languages),
+			SearchKeys: models.NonNil(handler.Capabilities.SearchKeys),
+			Formats:    models.NonNil(handler.Capabilities.Formats),
+			Quota:      handler.config.quota(),
+			Health:     handler.health.state(),
+			Circuit:    handler.breaker.snapshot(),
+		}
+		if handler.config.throttle != nil {
+			info.RateLimit = handler.config.throttle.rateLimit()
+		}
+		info.RateLimit = handler.limiter.describe(info.RateLimit)
+		items = append(items, info)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items
+}
+
+func (m *Manager) search(ctx context.Context, provider string, req *models.SearchRequest) ([]models.Subtitle, []models.SkippedProvider) {
+	type outcome struct {
+		provider string
+		items    []models.Subtitle
+		err      error
+	}
 	wg := &sync.WaitGroup{}
 	var subtitles []models.Subtitle
-	subChan := make(chan []models.Subtitle)
+	var skipped []models.SkippedProvider
+	outcomes := make(chan outcome)
 
 	for p := range m.handlers {
 		if provider != "" && provider != p {
@@ -141,36 +384,53 @@ func (m *Manager) search(ctx context.Context, provider string, query string) []m
 			continue
 		}
 
+		if err := m.handlers[p].breaker.allow(); err != nil {
+			skipped = append(skipped, models.SkippedProvider{Provider: p, Reason: models.SkipCircuitOpen})
+			continue
+		}
+
 		wg.Add(1)
-		go func(ctx context.Context, provider string, query string, subChan chan<- []models.Subtitle, wg *sync.WaitGroup) {
+		go func(ctx context.Context, provider string, req *models.SearchRequest, outcomes chan<- outcome, wg *sync.WaitGroup) {
 			defer wg.Done()
 			tracer := otel.Tracer(m.config.ServiceName)
 			ctxProvider, span := tracer.Start(ctx, fmt.Sprintf("Search.%s", provider))
 			defer span.End()
 
 			m.logger.Info().Msgf("Searching subtitles for provider: %s", provider)
-			items := m.handlers[provider].
-				Search(&ProviderParams{
-					config: m.handlers[provider].config,
-					logger: m.logger,
-					r:      m.r,
-					ctx:    ctxProvider,
-				}, query)
+			handler := m.handlers[provider]
+			items, err := handler.Search(&ProviderParams{
+				config: handler.config,
+				logger: m.logger,
+				r:      handler.client,
+				ctx:    ctxProvider,
+			}, req)
+			handler.breaker.record(err)
+			if err != nil {
+				span.RecordError(err)
+			}
 
 			span.SetAttributes(attribute.Int("result_count", len(items)))
-			subChan <- items
-		}(ctx, p, query, subChan, wg)
+			outcomes <- outcome{provider: provider, items: items, err: err}
+		}(ctx, p, req, outcomes, wg)
 	}
 
 	go func() {
 		wg.Wait()
-		close(subChan)
+		close(outcomes)
 	}()
 
-	for item := range subChan {
-		subtitles = append(subtitles, item...)
+	for item := range outcomes {
+		if errors.Is(item.err, ErrRateLimited) {
+			skipped = append(skipped, models.SkippedProvider{Provider: item.provider, Reason: models.SkipRateLimited})
+		} else if errors.Is(item.err, ErrPartialResults) {
+			skipped = append(skipped, models.SkippedProvider{Provider: item.provider, Reason: models.SkipPartial, Error: item.err.Error()})
+		} else if item.err != nil {
+			skipped = append(skipped, models.SkippedProvider{Provider: item.provider, Reason: models.SkipError, Error: item.err.Error()})
+		}
+		subtitles = append(subtitles, item.items...)
 	}
-	return subtitles
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].Provider < skipped[j].Provider })
+	return subtitles, skipped
 }
 
 func (m *Manager) postFiltering(filters *models.PostFilters, subtitles []models.Subtitle) []models.Subtitle {
@@ -189,11 +449,54 @@ func (m *Manager) postFiltering(filters *models.PostFilters, subtitles []models.
 		if filters.Resolution != "" && !m.contains(filters.Resolution, item.Resolution) {
 			continue
 		}
+		if item.Downloads < filters.MinDownloads || item.Rating < filters.MinRating {
+			continue
+		}
+		if filters.HearingImpaired != nil && item.HearingImpaired != *filters.HearingImpaired {
+			continue
+		}
+		if filters.MachineTranslated != nil && item.MachineTranslated != *filters.MachineTranslated {
+			continue
+		}
+		if filters.Trusted != nil && item.Trusted != *filters.Trusted {
+			continue
+		}
 		filtered = append(filtered, item)
 	}
 	return filtered
 }
 
+// sortSubtitles orders results by one of the models.Sort* keys, descending
+// unless order is "asc". Results keep their provider order otherwise.
+func sortSubtitles(subtitles []models.Subtitle, key string, order string) {
+	var less func(a, b *models.Subtitle) bool
+	switch key {
+	case models.SortDownloads:
+		less = func(a, b *models.Subtitle) bool { return a.Downloads < b.Downloads }
+	case models.SortRating:
+		less = func(a, b *models.Subtitle) bool { return a.Rating < b.Rating }
+	case models.SortFps:
+		less = func(a, b *models.Subtitle) bool { return a.Fps < b.Fps }
+	case models.SortCds:
+		less = func(a, b *models.Subtitle) bool { return a.Cds < b.Cds }
+	case models.SortUploadedAt:
+		less = func(a, b *models.Subtitle) bool {
+			if a.UploadedAt == nil || b.UploadedAt == nil {
+				return a.UploadedAt == nil && b.UploadedAt != nil
+			}
+			return a.UploadedAt.Before(*b.UploadedAt)
+		}
+	default:
+		return
+	}
+	sort.SliceStable(subtitles, func(i, j int) bool {
+		if order == "asc" {
+			return less(&subtitles[i], &subtitles[j])
+		}
+		return less(&subtitles[j], &subtitles[i])
+	})
+}
+
 func (m *Manager) contains(term string, terms []string) bool {
 	for _, item := range terms {
 		if strings.Contains(term, item) {
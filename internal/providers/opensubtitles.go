@@ -1,64 +1,125 @@
 package providers
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/xochilpili/subtitler-api/internal/models"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func searchOpenSubtitles(provider *ProviderParams, query string) []models.Subtitle {
+func searchOpenSubtitles(provider *ProviderParams, req *models.SearchRequest) ([]models.Subtitle, error) {
+	query := req.Term
 	tracer := otel.Tracer("opensubtitles") // Changed to provider url as app
 	ctx, span := tracer.Start(provider.ctx, "OpenSubtitles.API.Search")
 	defer span.End()
 
 	span.SetAttributes(attribute.String("query", query))
 
-	var target OpenSubtitlesResponse[OpenSubtitlesItem]
+	params := openSubtitlesQuery(req)
+	var subtitles []models.Subtitle
+	var fetchErr error
+	var failedPage int
+	maxPages := max(provider.config.maxPages, 1)
+	for page := 1; page <= maxPages; page++ {
+		failedPage = page
+		params["page"] = strconv.Itoa(page)
+		var target OpenSubtitlesResponse[OpenSubtitlesItem]
+		res, err := provider.r.R().
+			SetHeaders(map[string]string{
+				"Content-Type": "application/json",
+				"Api-Key":      provider.config.apiKey,
+				"User-Agent":   provider.config.userAgent,
+			}).
+			SetDebug(provider.config.debug).
+			SetContext(ctx).
+			SetQueryParams(params).
+			Get(provider.config.url + provider.config.searchUrl)
 
-	res, err := provider.r.R().
-		SetHeaders(map[string]string{
-			"Content-Type": "application/json",
-			"Api-Key":      provider.config.apiKey,
-			"User-Agent":   provider.config.userAgent,
-		}).
-		SetDebug(provider.config.debug).
-		SetContext(ctx).
-		SetQueryParams(map[string]string{
-			"type":          "movie",
-			"query":         query,
-			"languages":     "es,en",
-			"ai_translated": "true",
-		}).
-		Get(provider.config.url + provider.config.searchUrl)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(499, "error while fetching opensubtitles subtitles")
+			provider.logger.Err(err).Msgf("error while fetching opensubtitles: %v", err)
+			fetchErr = err
+			break
+		}
 
-	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(499, "error while fetching opensubtitles subtitles")
-		provider.logger.Err(err).Msgf("error while fetching opensubtitles: %v", err)
-		return nil
-	}
+		if res.StatusCode() != 200 {
+			fetchErr = fmt.Errorf("opensubtitles non ok response, status %d", res.StatusCode())
+			provider.logger.Err(fetchErr).Msgf("status response %d", res.StatusCode())
+			break
+		}
 
-	if res.StatusCode() != 200 {
-		provider.logger.Err(errors.New("opensubtitles non ok response")).Msgf("status response %d", res.StatusCode())
-		return nil
+		err = json.Unmarshal(res.Body(), &target)
+		if err != nil {
+			provider.logger.Err(err).Msgf("error while unmarshal opensubtitles json response: %v", err)
+			fetchErr = err
+			break
+		}
+		subtitles = append(subtitles, translate2Model(target.Data)...)
+		if page >= target.TotalPages {
+			break
+		}
+	}
+	span.SetAttributes(attribute.Int("subtitle_count", len(subtitles)))
+	if fetchErr != nil {
+		if len(subtitles) == 0 {
+			return nil, fetchErr
+		}
+		// the earlier pages are kept, and the caller told they are all there is
+		span.AddEvent("partial results", trace.WithAttributes(attribute.Int("page", failedPage), attribute.String("error", fetchErr.Error())))
+		provider.logger.Warn().Err(fetchErr).Msgf("opensubtitles page %d failed, keeping the %d results before it", failedPage, len(subtitles))
+		return subtitles, fmt.Errorf("%w: page %d: %w", ErrPartialResults, failedPage, fetchErr)
 	}
+	return subtitles, nil
+}
 
-	err = json.Unmarshal(res.Body(), &target)
-	if err != nil {
-		fmt.Printf("%s", res.Body())
-		provider.logger.Err(err).Msgf("error while unmarshal opensubtitles json response: %v", err)
-		return nil
+// openSubtitlesQuery builds the search parameters, switching to an episode
+// search when the request or the term names a season or episode.
+func openSubtitlesQuery(req *models.SearchRequest) map[string]string {
+	languages := req.Language
+	if languages == "" {
+		languages = "es,en"
+	}
+	translated := "exclude"
+	if req.MachineTranslated {
+		translated = "include"
 	}
-	return translate2Model(target.Data)
+	params := map[string]string{
+		"type":               "all",
+		"query":              req.Term,
+		"languages":          languages,
+		"ai_translated":      translated,
+		"machine_translated": translated,
+	}
+	if req.Year > 0 {
+		params["year"] = strconv.Itoa(req.Year)
+	}
+	show, season, episode := parseEpisodeQuery(req)
+	if season > 0 || episode > 0 {
+		params["type"] = "episode"
+		params["query"] = show
+		if season > 0 {
+			params["season_number"] = strconv.Itoa(season)
+		}
+		if episode > 0 {
+			params["episode_number"] = strconv.Itoa(episode)
+		}
+	}
+	return params
 }
 
+// translate2Model returns one subtitle per file, so every CD of a multi-file
+// subtitle can be downloaded on its own.
 func translate2Model(items []OpenSubtitlesItem) []models.Subtitle {
 	var subtitles []models.Subtitle
 	for _, item := range items {
@@ -69,47 +130,165 @@ func translate2Model(items []OpenSubtitlesItem) []models.Subtitle {
 		var itemType string
 		var season int
 		var episode int
-		id := item.Attributes.Files[0].FileId
 		desc := item.Attributes.Release
-		itemType, season, episode = parseTitle(item.Attributes.FeatureDetails.Title)
+		feature := item.Attributes.FeatureDetails
+		title := feature.Title
+		itemType, season, episode = parseTitle(title)
+		if feature.ParentTitle != "" {
+			title = feature.ParentTitle
+		}
+		if strings.EqualFold(feature.FeatureType, "episode") {
+			itemType, season, episode = "serie", feature.SeasonNumber, feature.EpisodeNumber
+		}
 		group, quality, resolution, duration = parseExtra(desc)
-		subtitle := models.Subtitle{
-			Provider:    "opensubtitles",
-			Id:          id,
-			ExternalId:  strconv.Itoa(id),
-			Type:        itemType,
-			Title:       item.Attributes.FeatureDetails.Title,
-			Description: item.Attributes.Release,
-			Language:    item.Attributes.Language,
-			Group:       group,
-			Quality:     quality,
-			Resolution:  resolution,
-			Duration:    duration,
-			Year:        item.Attributes.FeatureDetails.Year,
-			Season:      season,
-			Episode:     episode,
+		for _, file := range item.Attributes.Files {
+			description := desc
+			if len(item.Attributes.Files) > 1 {
+				description = fmt.Sprintf("%s (CD %d/%d)", desc, file.CdNumber, len(item.Attributes.Files))
+			}
+			subtitle := models.Subtitle{
+				Provider:    "opensubtitles",
+				Id:          file.FileId,
+				ExternalId:  strconv.Itoa(file.FileId),
+				Type:        itemType,
+				Title:       title,
+				Description: description,
+				Language:    item.Attributes.Language,
+				Group:       group,
+				Quality:     quality,
+				Resolution:  resolution,
+				Duration:    duration,
+				Year:        feature.Year,
+				Season:      season,
+				Episode:     episode,
+				Cds:         item.Attributes.NbCd,
+				Downloads:   item.Attributes.DownloadCount,
+				Rating:      item.Attributes.Ratings,
+				Uploader:    item.Attributes.Uploader.Name,
+				UploadedAt:  models.ParseTimestamp(item.Attributes.UploadDate),
+				Fps:         item.Attributes.Fps,
+
+				HearingImpaired:   item.Attributes.HearingImpaired,
+				MachineTranslated: item.Attributes.MachineTranslated || item.Attributes.AiTranslated,
+				Trusted:           item.Attributes.FromTrusted,
+			}
+			subtitles = append(subtitles, subtitle)
 		}
-		subtitles = append(subtitles, subtitle)
 	}
 	return subtitles
 }
 
-func downloadOpenSubtitle(provider *ProviderParams, subtitleId string) (io.ReadCloser, string, string, error) {
+func downloadOpenSubtitle(provider *ProviderParams, subtitleId string) (*models.SubtitleFile, error) {
 	tracer := otel.Tracer(provider.config.url + provider.config.searchUrl)
 	ctx, rootSpan := tracer.Start(provider.ctx, "Download Subtitle Flow")
 	defer rootSpan.End()
 
-	ctxLogin, loginSpan := tracer.Start(ctx, "Login")
-	loginSpan.SetAttributes(attribute.String("endpoint", provider.config.url+"api/v1/login"))
+	session := provider.config.opensubtitles
+	if err := session.checkQuota(); err != nil {
+		rootSpan.RecordError(err)
+		return nil, err
+	}
+
+	var link string
+	for attempt := 0; attempt < 2; attempt++ {
+		ctxLogin, loginSpan := tracer.Start(ctx, "Login")
+		provider.ctx = ctxLogin
+		token, err := session.get(provider)
+		loginSpan.End()
+		if err != nil {
+			rootSpan.RecordError(err)
+			rootSpan.SetStatus(401, "login error")
+			return nil, err
+		}
+
+		ctxDownloadApi, spanDownload := tracer.Start(ctx, "Request Download Link")
+		spanDownload.SetAttributes(attribute.String("file_id", subtitleId))
+		provider.ctx = ctxDownloadApi
+		link, err = requestOpenSubtitlesLink(provider, token, subtitleId)
+		spanDownload.End()
+		if errors.Is(err, errOpenSubtitlesUnauthorized) {
+			rootSpan.AddEvent("token rejected, logging in again")
+			session.invalidate(token)
+			continue
+		}
+		if err != nil {
+			rootSpan.RecordError(err)
+			rootSpan.SetStatus(404, "failed to get download link")
+			return nil, err
+		}
+		break
+	}
+	if link == "" {
+		return nil, errors.New("opensubtitles rejected the refreshed token")
+	}
+	rootSpan.AddEvent("download link received")
+
+	ctxDownload, spanDownloaded := tracer.Start(ctx, "Download Subtitle File")
+	spanDownloaded.SetAttributes(attribute.String("download_url", link))
+
+	res, err := provider.r.R().
+		SetDoNotParseResponse(true).
+		SetDebug(provider.config.debug).
+		SetContext(ctxDownload).
+		Get(link)
+
+	if err != nil {
+		spanDownloaded.RecordError(err)
+		spanDownloaded.SetStatus(404, "file download error")
+		return nil, err
+	}
+
+	file, err := httpDownload("opensubtitles", res, subtitleId+".srt")
+	if err != nil {
+		spanDownloaded.RecordError(err)
+		spanDownloaded.End()
+		return nil, err
+	}
+	provider.logger.Info().Msgf("downloading file: %s", file.Filename)
+	rootSpan.AddEvent(fmt.Sprintf("downloaded file: %s, format: %s", file.Filename, file.ContentType))
+	spanDownloaded.End()
+
+	return file, nil
+}
 
-	var tokenResponse struct {
+var errOpenSubtitlesUnauthorized = errors.New("opensubtitles token rejected")
+
+// openSubtitlesSession keeps the login token until it expires and tracks
+// the account's download quota as reported by the api.
+type openSubtitlesSession struct {
+	mu        sync.Mutex
+	token     string
+	expires   time.Time
+	remaining int
+	resetAt   time.Time
+	known     bool
+}
+
+func newOpenSubtitlesSession() *openSubtitlesSession {
+	return &openSubtitlesSession{}
+}
+
+// get returns the cached token, logging in first when there is none or it
+// is about to expire.
+func (s *openSubtitlesSession) get(provider *ProviderParams) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	var login struct {
 		Token string `json:"token"`
+		User  struct {
+			RemainingDownloads *int `json:"remaining_downloads"`
+		} `json:"user"`
 	}
-	_, err := provider.r.R().SetHeaders(map[string]string{
-		"Content-Type": "application/json",
-		"Api-Key":      provider.config.apiKey,
-		"User-Agent":   provider.config.userAgent,
-	}).
+	res, err := provider.r.R().
+		SetHeaders(map[string]string{
+			"Content-Type": "application/json",
+			"Api-Key":      provider.config.apiKey,
+			"User-Agent":   provider.config.userAgent,
+		}).
 		SetBody(struct {
 			Username string `json:"username"`
 			Password string `json:"password"`
@@ -117,82 +296,142 @@ func downloadOpenSubtitle(provider *ProviderParams, subtitleId string) (io.ReadC
 			Username: provider.config.apiUsername,
 			Password: provider.config.apiPassword,
 		}).
-		SetContext(ctxLogin).
-		SetResult(&tokenResponse).
+		SetContext(provider.ctx).
+		SetResult(&login).
 		SetDebug(provider.config.debug).
 		Post(provider.config.url + "api/v1/login")
-
 	if err != nil {
-		loginSpan.RecordError(err)
-		loginSpan.SetStatus(401, "login error")
-		return nil, "", "", err
+		return "", err
+	}
+	if res.IsError() || login.Token == "" {
+		return "", fmt.Errorf("unable to get token, status %d", res.StatusCode())
 	}
 
-	if tokenResponse.Token == "" {
-		errs := errors.New("unable to get token")
-		loginSpan.RecordError(errs)
-		loginSpan.SetStatus(401, "unable to get token")
-		return nil, "", "", errs
+	s.token = login.Token
+	s.expires = jwtExpiry(login.Token).Add(-time.Minute)
+	if login.User.RemainingDownloads != nil && !s.known {
+		s.remaining = *login.User.RemainingDownloads
+		s.known = true
 	}
-	rootSpan.AddEvent("login request completed")
-	loginSpan.End()
+	return s.token, nil
+}
 
-	var downloadResponse struct {
-		Link string `json:"link"`
+// invalidate drops the token unless it was already replaced.
+func (s *openSubtitlesSession) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+// checkQuota refuses downloads while the last known quota is exhausted and
+// its reset time has not passed yet. Without a reset time, the quota is
+// taken to reset at the next UTC midnight, as the api's daily quotas do.
+func (s *openSubtitlesSession) checkQuota() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.known || s.remaining > 0 {
+		return nil
+	}
+	if s.resetAt.IsZero() {
+		s.resetAt = time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
 	}
+	if time.Now().After(s.resetAt) {
+		s.known = false
+		s.resetAt = time.Time{}
+		return nil
+	}
+	return fmt.Errorf("%w: resets at %s", ErrQuotaExceeded, s.resetAt.Format(time.RFC3339))
+}
 
-	ctxDownloadApi, spanDownload := tracer.Start(ctx, "Request Download Link")
-	spanDownload.SetAttributes(attribute.String("file_id", subtitleId))
+func (s *openSubtitlesSession) updateQuota(remaining int, resetAt string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remaining = remaining
+	s.known = true
+	s.resetAt = time.Time{}
+	if t := models.ParseTimestamp(resetAt); t != nil {
+		s.resetAt = *t
+	}
+}
+
+func (s *openSubtitlesSession) quota() *models.ProviderQuota {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.known {
+		return nil
+	}
+	quota := &models.ProviderQuota{Remaining: s.remaining}
+	if !s.resetAt.IsZero() {
+		resetAt := s.resetAt
+		quota.ResetAt = &resetAt
+	}
+	return quota
+}
 
-	_, err = provider.r.R().
+func requestOpenSubtitlesLink(provider *ProviderParams, token string, subtitleId string) (string, error) {
+	var downloadResponse struct {
+		Link         string `json:"link"`
+		Remaining    *int   `json:"remaining"`
+		ResetTimeUtc string `json:"reset_time_utc"`
+		Message      string `json:"message"`
+	}
+	res, err := provider.r.R().
 		SetHeaders(map[string]string{
 			"Content-Type":  "application/json",
 			"Api-Key":       provider.config.apiKey,
-			"Authorization": "Bearer " + tokenResponse.Token,
+			"Authorization": "Bearer " + token,
 			"User-Agent":    provider.config.userAgent,
 		}).
 		SetDebug(provider.config.debug).
-		SetContext(ctxDownloadApi).
+		SetContext(provider.ctx).
 		SetResult(&downloadResponse).
+		SetError(&downloadResponse).
 		SetBody(struct {
 			FileId string `json:"file_id"`
 		}{
 			FileId: subtitleId,
 		}).
 		Post(provider.config.url + "api/v1/download")
-
-	if err != nil || downloadResponse.Link == "" {
-		spanDownload.RecordError(err)
-		spanDownload.SetStatus(404, "failed to get download link")
-		return nil, "", "", err
+	if err != nil {
+		return "", err
 	}
-	rootSpan.AddEvent("download link received")
-	spanDownload.End()
-
-	ctxDownload, spanDownloaded := tracer.Start(ctx, "Download Subtitle File")
-	spanDownloaded.SetAttributes(attribute.String("download_url", downloadResponse.Link))
-
-	res, err := provider.r.R().
-		SetDoNotParseResponse(true).
-		SetDebug(provider.config.debug).
-		SetContext(ctxDownload).
-		Get(downloadResponse.Link)
+	if res.StatusCode() == http.StatusUnauthorized {
+		return "", errOpenSubtitlesUnauthorized
+	}
+	if downloadResponse.Remaining != nil {
+		provider.config.opensubtitles.updateQuota(*downloadResponse.Remaining, downloadResponse.ResetTimeUtc)
+	}
+	if res.StatusCode() == http.StatusNotAcceptable {
+		return "", fmt.Errorf("%w: %s", ErrQuotaExceeded, downloadResponse.Message)
+	}
+	if res.StatusCode() == http.StatusTooManyRequests {
+		return "", fmt.Errorf("%w: opensubtitles answered 429: %s", ErrRateLimited, downloadResponse.Message)
+	}
+	if res.IsError() || downloadResponse.Link == "" {
+		return "", fmt.Errorf("failed to get download link, status %d", res.StatusCode())
+	}
+	return downloadResponse.Link, nil
+}
 
+// jwtExpiry reads the exp claim of a token without verifying it; the api
+// documents a 24 hour lifetime, which is assumed when the claim is missing.
+func jwtExpiry(token string) time.Time {
+	fallback := time.Now().Add(24 * time.Hour)
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fallback
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
 	if err != nil {
-		spanDownloaded.RecordError(err)
-		spanDownloaded.SetStatus(404, "file download error")
-		return nil, "", "", err
+		return fallback
 	}
-
-	contentType := res.Header().Get("Content-Type")
-	ext := strings.Split(contentType, "/")[0]
-	if ext == "text" {
-		ext = "srt"
+	var claims struct {
+		Exp int64 `json:"exp"`
 	}
-	filename := fmt.Sprintf("%s.%s", subtitleId, ext)
-	provider.logger.Info().Msgf("downloading file: %s", filename)
-	rootSpan.AddEvent(fmt.Sprintf("downloaded file: %s, format: %s", filename, ext))
-	spanDownloaded.End()
-
-	return res.RawBody(), filename, contentType, nil
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return fallback
+	}
+	return time.Unix(claims.Exp, 0)
 }
@@ -0,0 +1,327 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog"
+	"github.com/xochilpili/subtitler-api/internal/config"
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+func openSubtitlesConfig(maxPages int) *ProviderConfig {
+	return &ProviderConfig{searchUrl: "api/v1/subtitles", apiKey: "key", userAgent: "subtitlerApi test", maxPages: maxPages, opensubtitles: newOpenSubtitlesSession()}
+}
+
+// openSubtitlesPage answers page with one item per file id given, out of
+// total pages.
+func openSubtitlesPage(w http.ResponseWriter, page int, total int, fileIds ...int) {
+	item := OpenSubtitlesItem{Id: strconv.Itoa(page), Type: "subtitle"}
+	item.Attributes.Language = "en"
+	item.Attributes.Release = fmt.Sprintf("The.Matrix.1999.Page%d", page)
+	item.Attributes.FeatureDetails.Title = "The Matrix"
+	item.Attributes.FeatureDetails.Year = 1999
+	for _, id := range fileIds {
+		item.Attributes.Files = append(item.Attributes.Files, OpenSubtitlesItemFile{FileId: id, CdNumber: len(item.Attributes.Files) + 1})
+	}
+	json.NewEncoder(w).Encode(OpenSubtitlesResponse[OpenSubtitlesItem]{Page: page, TotalPages: total, Data: []OpenSubtitlesItem{item}})
+}
+
+func TestSearchOpenSubtitlesPartial(t *testing.T) {
+	var pages []string
+	site := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		pages = append(pages, strconv.Itoa(page))
+		if page == 2 {
+			http.Error(w, "upstream failure", http.StatusInternalServerError)
+			return
+		}
+		openSubtitlesPage(w, page, 3, page)
+	})
+
+	provider := testProvider(t, site, openSubtitlesConfig(3))
+	subtitles, err := searchOpenSubtitles(provider, &models.SearchRequest{Term: "the matrix"})
+	if !errors.Is(err, ErrPartialResults) {
+		t.Fatalf("searchOpenSubtitles() error = %v, want ErrPartialResults", err)
+	}
+	if len(subtitles) != 1 || subtitles[0].ExternalId != "1" {
+		t.Errorf("subtitles = %+v, want the first page", subtitles)
+	}
+	if fmt.Sprint(pages) != "[1 2]" {
+		t.Errorf("pages = %v, want the search to stop at the failed page", pages)
+	}
+
+	// a failed first page is no partial result
+	failing := testProvider(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream failure", http.StatusInternalServerError)
+	}), openSubtitlesConfig(3))
+	if _, err := searchOpenSubtitles(failing, &models.SearchRequest{Term: "the matrix"}); err == nil || errors.Is(err, ErrPartialResults) {
+		t.Errorf("searchOpenSubtitles() error = %v, want the upstream failure", err)
+	}
+}
+
+func TestManagerReportsPartialResults(t *testing.T) {
+	logger := zerolog.Nop()
+	provider := testProvider(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "1" {
+			http.Error(w, "upstream failure", http.StatusBadGateway)
+			return
+		}
+		openSubtitlesPage(w, 1, 2, 1, 2)
+	}), openSubtitlesConfig(2))
+	m := &Manager{config: &config.Config{}, logger: &logger, handlers: map[string]Handler{
+		"opensubtitles": {
+			enabled: true,
+			config:  provider.config,
+			health:  &providerHealth{},
+			breaker: newBreaker("opensubtitles", 1, time.Minute, newBreakerMetrics("test", func() []*breaker { return nil })),
+			client:  resty.New(),
+			Search:  searchOpenSubtitles,
+		},
+	}}
+
+	subtitles, skipped := m.search(context.Background(), "", &models.SearchRequest{Term: "the matrix"})
+	if len(subtitles) != 2 {
+		t.Errorf("subtitles = %+v, want both files of the first page", subtitles)
+	}
+	if len(skipped) != 1 || skipped[0].Reason != models.SkipPartial || skipped[0].Error == "" {
+		t.Errorf("skipped = %+v, want opensubtitles as partial", skipped)
+	}
+	// the provider answered, so its circuit stays closed
+	if state := m.handlers["opensubtitles"].breaker.snapshot().State; state != breakerClosed {
+		t.Errorf("circuit = %s, want closed", state)
+	}
+}
+
+// openSubtitlesApi stands in for the login and download endpoints. Tokens
+// are numbered JWTs; revoked ones, or every one with revokeAll, are
+// answered with 401. remaining is the download quota reported back, and
+// status overrides the link answer.
+type openSubtitlesApi struct {
+	remaining int
+	resetAt   string
+	status    int
+	revokeAll bool
+
+	mu      sync.Mutex
+	logins  int
+	links   int
+	revoked map[string]bool
+}
+
+func (a *openSubtitlesApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/api/v1/login":
+		a.logins++
+		claims := base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, `{"exp":%d}`, time.Now().Add(time.Hour).Unix()))
+		fmt.Fprintf(w, `{"token":"h.%s.%d","user":{"remaining_downloads":%d}}`, claims, a.logins, a.remaining)
+	case "/api/v1/download":
+		if a.revokeAll || a.revoked[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")] {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		a.links++
+		if a.status != 0 {
+			w.WriteHeader(a.status)
+			fmt.Fprintf(w, `{"message":"answered %d","remaining":%d,"reset_time_utc":%q}`, a.status, a.remaining, a.resetAt)
+			return
+		}
+		a.remaining--
+		fmt.Fprintf(w, `{"link":"http://%s/file.srt","remaining":%d,"reset_time_utc":%q}`, r.Host, a.remaining, a.resetAt)
+	case "/file.srt":
+		w.Header().Set("Content-Type", "application/x-subrip")
+		fmt.Fprint(w, "1\n00:00:01,000 --> 00:00:02,000\nHi\n")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// sessionToken returns the token the provider session holds.
+func sessionToken(t *testing.T, provider *ProviderParams) string {
+	t.Helper()
+	token, err := provider.config.opensubtitles.get(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestDownloadOpenSubtitlesToken(t *testing.T) {
+	api := &openSubtitlesApi{remaining: 10, revoked: map[string]bool{}}
+	provider := testProvider(t, api, openSubtitlesConfig(1))
+
+	for range 2 {
+		file, err := downloadOpenSubtitle(provider, "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Body.Close()
+	}
+	if api.logins != 1 {
+		t.Errorf("logins = %d, want the token reused", api.logins)
+	}
+
+	// a rejected token is replaced once
+	api.revoked[sessionToken(t, provider)] = true
+	file, err := downloadOpenSubtitle(provider, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Body.Close()
+	if api.logins != 2 {
+		t.Errorf("logins = %d, want one more after the 401", api.logins)
+	}
+
+	// but not in a loop
+	api.revokeAll = true
+	if _, err := downloadOpenSubtitle(provider, "1"); err == nil {
+		t.Error("downloadOpenSubtitle() succeeded with every token rejected")
+	}
+	if api.logins != 3 {
+		t.Errorf("logins = %d, want 3", api.logins)
+	}
+}
+
+func TestDownloadOpenSubtitlesQuota(t *testing.T) {
+	resetAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	api := &openSubtitlesApi{remaining: 1, resetAt: resetAt.Format(time.RFC3339)}
+	provider := testProvider(t, api, openSubtitlesConfig(1))
+
+	file, err := downloadOpenSubtitle(provider, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Body.Close()
+	quota := provider.config.quota()
+	if quota == nil || quota.Remaining != 0 || quota.ResetAt == nil || !quota.ResetAt.Equal(resetAt) {
+		t.Fatalf("quota = %+v, want none left until %s", quota, resetAt)
+	}
+
+	// the exhausted quota is held until the reset without asking again
+	if _, err := downloadOpenSubtitle(provider, "1"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("download error = %v, want ErrQuotaExceeded", err)
+	}
+	if api.links != 1 {
+		t.Errorf("links asked for = %d, want 1", api.links)
+	}
+	provider.config.opensubtitles.resetAt = time.Now().Add(-time.Second)
+	if err := provider.config.opensubtitles.checkQuota(); err != nil {
+		t.Errorf("checkQuota() after the reset = %v", err)
+	}
+}
+
+func TestDownloadOpenSubtitlesRejected(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotAcceptable, ErrQuotaExceeded},
+		{http.StatusTooManyRequests, ErrRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			api := &openSubtitlesApi{remaining: 5, status: tt.status}
+			provider := testProvider(t, api, openSubtitlesConfig(1))
+			if _, err := downloadOpenSubtitle(provider, "1"); !errors.Is(err, tt.want) {
+				t.Errorf("download error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenSubtitlesQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		req  models.SearchRequest
+		want map[string]string
+	}{
+		{
+			name: "movie",
+			req:  models.SearchRequest{Term: "the matrix", Year: 1999},
+			want: map[string]string{"type": "all", "query": "the matrix", "languages": "es,en", "year": "1999", "ai_translated": "exclude", "machine_translated": "exclude"},
+		},
+		{
+			name: "episode from the request",
+			req:  models.SearchRequest{Term: "breaking bad", Season: 1, Episode: 2, Language: "en"},
+			want: map[string]string{"type": "episode", "query": "breaking bad", "languages": "en", "season_number": "1", "episode_number": "2", "ai_translated": "exclude", "machine_translated": "exclude"},
+		},
+		{
+			name: "episode from the term",
+			req:  models.SearchRequest{Term: "breaking bad S02E03"},
+			want: map[string]string{"type": "episode", "query": "breaking bad", "languages": "es,en", "season_number": "2", "episode_number": "3", "ai_translated": "exclude", "machine_translated": "exclude"},
+		},
+		{
+			name: "machine translated",
+			req:  models.SearchRequest{Term: "the matrix", MachineTranslated: true},
+			want: map[string]string{"type": "all", "query": "the matrix", "languages": "es,en", "ai_translated": "include", "machine_translated": "include"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := openSubtitlesQuery(&tt.req); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("openSubtitlesQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchOpenSubtitlesPages(t *testing.T) {
+	var pages []string
+	provider := testProvider(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		pages = append(pages, strconv.Itoa(page))
+		openSubtitlesPage(w, page, 5, page*10, page*10+1)
+	}), openSubtitlesConfig(2))
+
+	subtitles, err := searchOpenSubtitles(provider, &models.SearchRequest{Term: "the matrix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(pages) != "[1 2]" {
+		t.Errorf("pages = %v, want the first 2 of 5", pages)
+	}
+	var got []string
+	for _, s := range subtitles {
+		got = append(got, s.ExternalId+" "+s.Description)
+	}
+	want := []string{
+		"10 The.Matrix.1999.Page1 (CD 1/2)",
+		"11 The.Matrix.1999.Page1 (CD 2/2)",
+		"20 The.Matrix.1999.Page2 (CD 1/2)",
+		"21 The.Matrix.1999.Page2 (CD 2/2)",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("subtitles = %q, want one per file %q", got, want)
+	}
+}
+
+func TestTranslate2Model(t *testing.T) {
+	var episode, empty OpenSubtitlesItem
+	episode.Attributes.Language = "en"
+	episode.Attributes.Release = "Breaking.Bad.S01E02.720p"
+	episode.Attributes.FeatureDetails = OpenSubtitlesItemFeature{FeatureType: "Episode", Title: "Cat's in the Bag...", ParentTitle: "Breaking Bad", SeasonNumber: 1, EpisodeNumber: 2}
+	episode.Attributes.Files = []OpenSubtitlesItemFile{{FileId: 7}}
+
+	// items without files are left out rather than read past
+	subtitles := translate2Model([]OpenSubtitlesItem{empty, episode})
+	if len(subtitles) != 1 {
+		t.Fatalf("subtitles = %+v, want the episode only", subtitles)
+	}
+	s := subtitles[0]
+	if s.Title != "Breaking Bad" || s.Type != "serie" || s.Season != 1 || s.Episode != 2 || s.ExternalId != "7" || s.Description != "Breaking.Bad.S01E02.720p" {
+		t.Errorf("subtitle = %+v", s)
+	}
+}
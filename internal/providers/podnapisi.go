@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func searchPodnapisi(provider *ProviderParams, req *models.SearchRequest) ([]models.Subtitle, error) {
+	tracer := otel.Tracer("podnapisi")
+	ctx, span := tracer.Start(provider.ctx, "Podnapisi.Search")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("query", req.Term))
+	provider.logger.Info().Msgf("searching subtitles for: %s", req.Term)
+
+	params := map[string]string{"keywords": req.Term}
+	if req.Year > 0 {
+		params["year"] = strconv.Itoa(req.Year)
+	}
+	if req.Season > 0 {
+		params["seasons"] = strconv.Itoa(req.Season)
+		params["movie_type"] = "tv-series"
+	}
+	if req.Episode > 0 {
+		params["episodes"] = strconv.Itoa(req.Episode)
+	}
+	if req.Language != "" {
+		params["language"] = req.Language
+	}
+
+	var result PodnapisiResponse
+	res, err := provider.r.R().
+		SetContext(ctx).
+		SetQueryParams(params).
+		SetHeaders(map[string]string{
+			"Accept":     "application/json",
+			"User-Agent": provider.config.userAgent,
+		}).
+		SetDebug(provider.config.debug).
+		Get(provider.config.url + provider.config.searchUrl)
+
+	if err != nil {
+		span.RecordError(err)
+		provider.logger.Err(err).Msgf("error while getting subtitles")
+		return nil, err
+	}
+
+	if res.StatusCode() != http.StatusOK {
+		provider.logger.Error().Msgf("podnapisi non ok response, status response %d", res.StatusCode())
+		return nil, fmt.Errorf("podnapisi non ok response, status %d", res.StatusCode())
+	}
+
+	err = json.Unmarshal(res.Body(), &result)
+	if err != nil {
+		provider.logger.Err(err).Msgf("error while unmarshal podnapisi json response: %v", err)
+		return nil, err
+	}
+
+	subtitles := translate2ModelPodnapisi(result.Data)
+	span.SetAttributes(attribute.Int("subtitle_count", len(subtitles)))
+	provider.logger.Info().Msgf("returned %d subtitles", len(subtitles))
+	return subtitles, nil
+}
+
+func translate2ModelPodnapisi(items []PodnapisiItem) []models.Subtitle {
+	var subtitles []models.Subtitle
+	for i, item := range items {
+		title := item.Movie.Title
+		if title == "" {
+			title = item.Title
+		}
+		desc := strings.Join(item.Releases, " ")
+		itemType, season, episode := parseTitle(title)
+		if item.Movie.EpisodeInfo != nil {
+			itemType = "serie"
+			season = item.Movie.EpisodeInfo.Season
+			episode = item.Movie.EpisodeInfo.Episode
+		} else if item.Movie.Type == "movie" {
+			itemType, season, episode = "movie", 0, 0
+		}
+		group, quality, resolution, duration := parseExtra(desc)
+
+		subtitles = append(subtitles, models.Subtitle{
+			Provider:    "podnapisi",
+			Type:        itemType,
+			Id:          i,
+			ExternalId:  item.Id,
+			Title:       title,
+			Description: desc,
+			Language:    item.Language,
+			Group:       group,
+			Quality:     quality,
+			Resolution:  resolution,
+			Duration:    duration,
+			Year:        item.Movie.Year,
+			Season:      season,
+			Episode:     episode,
+			Downloads:   item.Stats.Downloads,
+
+			HearingImpaired:   slices.Contains(item.Flags, "hearing_impaired"),
+			MachineTranslated: slices.Contains(item.Flags, "machine_translated"),
+		})
+	}
+	return subtitles
+}
+
+func downloadPodnapisi(provider *ProviderParams, subtitleId string) (*models.SubtitleFile, error) {
+	tracer := otel.Tracer("podnapisi")
+	ctx, span := tracer.Start(provider.ctx, "Podnapisi.Download")
+	defer span.End()
+	span.SetAttributes(attribute.String("subtitle_id", subtitleId))
+
+	res, err := provider.r.R().
+		SetContext(ctx).
+		SetHeaders(map[string]string{
+			"User-Agent": provider.config.userAgent,
+		}).
+		SetDoNotParseResponse(true).
+		SetDebug(provider.config.debug).
+		Get(provider.config.url + "subtitles/" + url.PathEscape(subtitleId) + "/download")
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	file, err := httpDownload("podnapisi", res, subtitleId+".zip")
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	provider.logger.Info().Msgf("downloading file: %s", file.Filename)
+	return file, nil
+}
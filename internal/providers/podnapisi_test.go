@@ -0,0 +1,172 @@
+package providers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog"
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+// testProvider points a provider at a local stand-in for its site.
+func testProvider(t *testing.T, handler http.Handler, config *ProviderConfig) *ProviderParams {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	logger := zerolog.Nop()
+	config.url = server.URL + "/"
+	return &ProviderParams{config: config, logger: &logger, r: resty.New(), ctx: context.Background()}
+}
+
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func podnapisiConfig() *ProviderConfig {
+	return &ProviderConfig{searchUrl: "subtitles/search/advanced", userAgent: "subtitlerApi test"}
+}
+
+func TestSearchPodnapisi(t *testing.T) {
+	var query url.Values
+	var accept string
+	provider := testProvider(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subtitles/search/advanced" {
+			http.NotFound(w, r)
+			return
+		}
+		query, accept = r.URL.Query(), r.Header.Get("Accept")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fixture(t, "podnapisi/search.json"))
+	}), podnapisiConfig())
+
+	subtitles, err := searchPodnapisi(provider, &models.SearchRequest{Term: "breaking bad", Year: 2008, Season: 1, Episode: 2, Language: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantQuery := url.Values{
+		"keywords":   {"breaking bad"},
+		"year":       {"2008"},
+		"seasons":    {"1"},
+		"episodes":   {"2"},
+		"movie_type": {"tv-series"},
+		"language":   {"en"},
+	}
+	if query.Encode() != wantQuery.Encode() {
+		t.Errorf("query = %s, want %s", query.Encode(), wantQuery.Encode())
+	}
+	if accept != "application/json" {
+		t.Errorf("Accept = %q, want application/json", accept)
+	}
+
+	if len(subtitles) != 2 {
+		t.Fatalf("got %d subtitles, want 2", len(subtitles))
+	}
+	episode, movie := subtitles[0], subtitles[1]
+	if episode.Uid() != "podnapisi:AbC1" || episode.Type != "serie" || episode.Season != 1 || episode.Episode != 2 {
+		t.Errorf("episode = %s %s S%02dE%02d", episode.Uid(), episode.Type, episode.Season, episode.Episode)
+	}
+	if episode.Description != "Breaking.Bad.S01E02.720p.BluRay.x264-DEMAND Breaking.Bad.S01E02.HDTV.XviD-LOL" {
+		t.Errorf("episode description = %q", episode.Description)
+	}
+	if episode.Downloads != 1520 || !episode.HearingImpaired || episode.MachineTranslated || episode.Year != 2008 {
+		t.Errorf("episode metadata = %+v", episode)
+	}
+	if movie.Uid() != "podnapisi:Xy2" || movie.Type != "movie" || movie.Season != 0 || movie.Language != "es" {
+		t.Errorf("movie = %s %s season %d %s", movie.Uid(), movie.Type, movie.Season, movie.Language)
+	}
+	if !movie.MachineTranslated || movie.Year != 1999 {
+		t.Errorf("movie metadata = %+v", movie)
+	}
+}
+
+func TestSearchPodnapisiErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"server error", http.StatusBadGateway, "bad gateway"},
+		{"invalid json", http.StatusOK, "<html>maintenance</html>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := testProvider(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}), podnapisiConfig())
+			if _, err := searchPodnapisi(provider, &models.SearchRequest{Term: "the matrix"}); err == nil {
+				t.Fatal("searchPodnapisi() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestDownloadPodnapisi(t *testing.T) {
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	f, _ := w.Create("Breaking.Bad.S01E02.srt")
+	io.WriteString(f, "1\n00:00:01,000 --> 00:00:02,000\nHi\n")
+	w.Close()
+
+	provider := testProvider(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/subtitles/AbC1/download":
+			// podnapisi labels its zips as plain text
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Disposition", `attachment; filename="Breaking.Bad.S01E02.txt"`)
+			w.Write(archive.Bytes())
+		case "/subtitles/broken/download":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}), podnapisiConfig())
+
+	file, err := downloadPodnapisi(provider, "AbC1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Body.Close()
+	if file.Filename != "Breaking.Bad.S01E02.zip" || file.ContentType != "application/zip" || file.ETag != `"v1"` {
+		t.Errorf("file = %s %s %s", file.Filename, file.ContentType, file.ETag)
+	}
+	if body, _ := io.ReadAll(file.Body); !bytes.Equal(body, archive.Bytes()) {
+		t.Error("downloaded body differs from the archive served")
+	}
+
+	if _, err := downloadPodnapisi(provider, "missing"); !errors.Is(err, ErrSubtitleNotFound) {
+		t.Errorf("missing subtitle error = %v, want ErrSubtitleNotFound", err)
+	}
+	if _, err := downloadPodnapisi(provider, "broken"); err == nil || errors.Is(err, ErrSubtitleNotFound) {
+		t.Errorf("server error = %v, want an upstream failure", err)
+	}
+}
+
+func TestDownloadPodnapisiEscapesIds(t *testing.T) {
+	var path, query string
+	provider := testProvider(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.EscapedPath(), r.URL.RawQuery
+		http.NotFound(w, r)
+	}), podnapisiConfig())
+
+	downloadPodnapisi(provider, "../../admin?delete=1#x")
+	if want := "/subtitles/..%2F..%2Fadmin%3Fdelete=1%23x/download"; path != want || query != "" {
+		t.Errorf("requested %s?%s, want %s", path, query, want)
+	}
+}
@@ -0,0 +1,189 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+type Probe func(params *ProviderParams) error
+
+// probeUrl checks that the provider answers a lightweight request; auth
+// rejections are reported separately since they need operator action.
+func probeUrl(provider *ProviderParams, url string, headers map[string]string) error {
+	res, err := provider.r.R().
+		SetContext(provider.ctx).
+		SetHeaders(headers).
+		SetDebug(provider.config.debug).
+		Get(url)
+	if err != nil {
+		return err
+	}
+	switch {
+	case res.StatusCode() == http.StatusUnauthorized || res.StatusCode() == http.StatusForbidden:
+		return fmt.Errorf("credentials rejected, status %d", res.StatusCode())
+	case res.StatusCode() >= http.StatusInternalServerError:
+		return fmt.Errorf("unexpected status %d", res.StatusCode())
+	}
+	return nil
+}
+
+func probeSite(provider *ProviderParams) error {
+	return probeUrl(provider, provider.config.url, map[string]string{"User-Agent": provider.config.userAgent})
+}
+
+func probeSubX(provider *ProviderParams) error {
+	return probeUrl(provider, provider.config.url+"/"+provider.config.searchUrl+"?title=probe", map[string]string{
+		"User-Agent":    provider.config.userAgent,
+		"Authorization": "Bearer " + provider.config.apiKey,
+	})
+}
+
+func probeOpenSubtitles(provider *ProviderParams) error {
+	return probeUrl(provider, provider.config.url+"api/v1/infos/formats", map[string]string{
+		"Api-Key":    provider.config.apiKey,
+		"User-Agent": provider.config.userAgent,
+	})
+}
+
+func probeAddic7ed(provider *ProviderParams) error {
+	if err := provider.config.throttle.wait(provider.ctx); err != nil {
+		return err
+	}
+	return probeSite(provider)
+}
+
+func probeLocal(provider *ProviderParams) error {
+	info, err := os.Stat(provider.config.library.root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("library path is not a directory")
+	}
+	return nil
+}
+
+// startProbes checks every enabled provider right away and then on each
+// interval, until Close is called.
+func (m *Manager) startProbes() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.stopProbes = cancel
+	go func() {
+		ticker := time.NewTicker(m.config.ProbeInterval)
+		defer ticker.Stop()
+		for {
+			m.probe(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (m *Manager) probe(ctx context.Context) {
+	wg := &sync.WaitGroup{}
+	for name, handler := range m.handlers {
+		if !handler.enabled || handler.Probe == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(name string, handler Handler) {
+			defer wg.Done()
+			ctxProbe, cancel := context.WithTimeout(ctx, m.config.ProbeTimeout)
+			defer cancel()
+			err := handler.Probe(&ProviderParams{
+				config: handler.config,
+				logger: m.logger,
+				r:      handler.client,
+				ctx:    ctxProbe,
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				m.logger.Warn().Err(err).Msgf("provider %s failed its health probe", name)
+			}
+			handler.health.record(err)
+		}(name, handler)
+	}
+	wg.Wait()
+}
+
+// Readiness aggregates the last probe of every enabled provider. The
+// service is ready once at least ReadyMinHealthy of them are healthy, or
+// all of them when fewer are enabled.
+func (m *Manager) Readiness() models.Readiness {
+	readiness := models.Readiness{Providers: []models.ProviderStatus{}}
+	enabled := 0
+	for name, handler := range m.handlers {
+		if !handler.enabled || handler.Probe == nil {
+			continue
+		}
+		enabled++
+		status := models.ProviderStatus{Name: name}
+		if state := handler.health.state(); state != nil {
+			status.Healthy = state.Healthy
+			status.CheckedAt = &state.CheckedAt
+			status.Error = state.Error
+		}
+		if status.Healthy {
+			readiness.Healthy++
+		}
+		readiness.Providers = append(readiness.Providers, status)
+	}
+	sort.Slice(readiness.Providers, func(i, j int) bool {
+		return readiness.Providers[i].Name < readiness.Providers[j].Name
+	})
+	readiness.Required = min(m.config.ReadyMinHealthy, enabled)
+	readiness.Ready = readiness.Healthy >= readiness.Required
+	return readiness
+}
+
+// providerHealth remembers the outcome of the last upstream check.
+type providerHealth struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+func (h *providerHealth) record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkedAt = time.Now().UTC()
+	h.err = err
+}
+
+func (h *providerHealth) state() *models.ProviderHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.checkedAt.IsZero() {
+		return nil
+	}
+	state := &models.ProviderHealth{Healthy: h.err == nil, CheckedAt: h.checkedAt}
+	if h.err != nil {
+		state.Error = h.err.Error()
+	}
+	return state
+}
+
+// upstreamFailure tells errors caused by the provider apart from the ones
+// caused by the request, so only the former mark a provider unhealthy.
+func upstreamFailure(err error) bool {
+	return err != nil &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, ErrQuotaExceeded) &&
+		!errors.Is(err, ErrRateLimited) &&
+		!errors.Is(err, ErrSubtitleNotFound) &&
+		!errors.Is(err, ErrNoDetails) &&
+		!errors.Is(err, ErrPartialResults)
+}
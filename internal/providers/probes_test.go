@@ -0,0 +1,125 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog"
+	"github.com/xochilpili/subtitler-api/internal/config"
+)
+
+func TestProbeUrl(t *testing.T) {
+	tests := []struct {
+		status  int
+		wantErr string
+	}{
+		{http.StatusOK, ""},
+		// the site answers, even if not with the page asked for
+		{http.StatusNotFound, ""},
+		{http.StatusUnauthorized, "credentials rejected"},
+		{http.StatusForbidden, "credentials rejected"},
+		{http.StatusBadGateway, "unexpected status 502"},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			provider := testProvider(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}), &ProviderConfig{})
+			err := probeSite(provider)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("probeSite() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProbeLocal(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "subtitle.srt")
+	if err := os.WriteFile(file, []byte(localSrt), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for path, ok := range map[string]bool{root: true, file: false, filepath.Join(root, "missing"): false} {
+		err := probeLocal(&ProviderParams{config: &ProviderConfig{library: &localLibrary{root: path}}})
+		if (err == nil) != ok {
+			t.Errorf("probeLocal(%s) = %v", path, err)
+		}
+	}
+}
+
+// probedManager has an enabled provider whose probe passes, one whose
+// probe fails, a disabled one and one without a probe.
+func probedManager(minHealthy int) *Manager {
+	logger := zerolog.Nop()
+	handler := func(enabled bool, probe Probe) Handler {
+		return Handler{enabled: enabled, config: &ProviderConfig{}, health: &providerHealth{}, client: resty.New(), Probe: probe}
+	}
+	pass := func(*ProviderParams) error { return nil }
+	return &Manager{
+		config: &config.Config{ProbeTimeout: time.Second, ReadyMinHealthy: minHealthy},
+		logger: &logger,
+		handlers: map[string]Handler{
+			"up":       handler(true, pass),
+			"down":     handler(true, func(*ProviderParams) error { return errors.New("credentials rejected, status 401") }),
+			"disabled": handler(false, pass),
+			"unprobed": handler(true, nil),
+		},
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		minHealthy   int
+		wantRequired int
+		wantReady    bool
+	}{
+		{0, 0, true},
+		{1, 1, true},
+		{2, 2, false},
+		// never more than the providers probed
+		{5, 2, false},
+	}
+	for _, tt := range tests {
+		m := probedManager(tt.minHealthy)
+		if before := m.Readiness(); before.Healthy != 0 || before.Ready != (tt.wantRequired == 0) {
+			t.Errorf("min %d: readiness before any probe = %+v", tt.minHealthy, before)
+		}
+		m.probe(context.Background())
+		readiness := m.Readiness()
+		if readiness.Ready != tt.wantReady || readiness.Required != tt.wantRequired || readiness.Healthy != 1 {
+			t.Errorf("min %d: readiness = %+v, want ready %v with %d required", tt.minHealthy, readiness, tt.wantReady, tt.wantRequired)
+		}
+	}
+
+	readiness := probedManager(1).Readiness()
+	if len(readiness.Providers) != 2 || readiness.Providers[0].Name != "down" || readiness.Providers[1].Name != "up" {
+		t.Fatalf("providers = %+v, want down and up only", readiness.Providers)
+	}
+	m := probedManager(1)
+	m.probe(context.Background())
+	down := m.Readiness().Providers[0]
+	if down.Healthy || down.CheckedAt == nil || down.Error != "credentials rejected, status 401" {
+		t.Errorf("down = %+v, want the probe error", down)
+	}
+}
+
+func TestStartProbes(t *testing.T) {
+	m := probedManager(1)
+	m.config.ProbeInterval = time.Hour
+	m.startProbes()
+	defer m.stopProbes()
+	deadline := time.Now().Add(5 * time.Second)
+	for !m.Readiness().Ready {
+		if time.Now().After(deadline) {
+			t.Fatal("the first probe did not run on start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
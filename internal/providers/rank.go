@@ -0,0 +1,198 @@
+package providers
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+var (
+	releaseEpisodeRe = regexp.MustCompile(`(?i)^s(\d{1,2})e(\d{1,3})$`)
+	releaseCrossRe   = regexp.MustCompile(`^(\d{1,2})x(\d{2,3})$`)
+	releaseYearRe    = regexp.MustCompile(`^(19|20)\d{2}$`)
+	releaseTokenRe   = regexp.MustCompile(`[\s._\-\[\]()]+`)
+)
+
+// Release is what a release name such as
+// "Breaking.Bad.S01E02.720p.BluRay.x264-DEMAND" says about the video a
+// subtitle has to match.
+type Release struct {
+	Name       string
+	Title      string
+	Year       int
+	Season     int
+	Episode    int
+	Group      []string
+	Quality    []string
+	Resolution []string
+}
+
+// ParseRelease splits a release name into its title, which is everything
+// before the first year, episode, resolution or quality token, and the
+// tags that follow.
+func ParseRelease(name string) *Release {
+	release := &Release{
+		Name:       name,
+		Group:      Parse(name, "group"),
+		Quality:    Parse(name, "quality"),
+		Resolution: Parse(name, "resolution"),
+	}
+	tokens := releaseTokenRe.Split(name, -1)
+	// of the years before the first tag, the last one dates the release and
+	// the others are part of the title, as in "Blade Runner 2049 (2017)"
+	yearAt := 0
+	for i, token := range tokens {
+		if isReleaseTag(release, token) {
+			break
+		}
+		if releaseYearRe.MatchString(token) {
+			yearAt = i
+		}
+	}
+	var title []string
+	inTitle := true
+	for i, token := range tokens {
+		if token == "" {
+			continue
+		}
+		lower := strings.ToLower(token)
+		switch {
+		case releaseEpisodeRe.MatchString(token):
+			match := releaseEpisodeRe.FindStringSubmatch(token)
+			release.Season, _ = strconv.Atoi(match[1])
+			release.Episode, _ = strconv.Atoi(match[2])
+		case releaseCrossRe.MatchString(token):
+			match := releaseCrossRe.FindStringSubmatch(token)
+			release.Season, _ = strconv.Atoi(match[1])
+			release.Episode, _ = strconv.Atoi(match[2])
+		case releaseYearRe.MatchString(token) && i >= yearAt && len(title) > 0:
+			// a leading year is part of the title, as in "1917"
+			if release.Year == 0 {
+				release.Year, _ = strconv.Atoi(token)
+			}
+		case contains(release.Resolution, lower) || contains(release.Quality, lower):
+		default:
+			if inTitle {
+				title = append(title, token)
+			}
+			continue
+		}
+		inTitle = false
+	}
+	release.Title = strings.Join(title, " ")
+	return release
+}
+
+// isReleaseTag tells whether token is an episode, resolution or quality
+// tag, which ends the title of a release name.
+func isReleaseTag(release *Release, token string) bool {
+	lower := strings.ToLower(token)
+	return releaseEpisodeRe.MatchString(token) || releaseCrossRe.MatchString(token) ||
+		contains(release.Resolution, lower) || contains(release.Quality, lower)
+}
+
+// Candidate is a search result along with the score it was ranked by.
+type Candidate struct {
+	models.Subtitle
+	Score int
+}
+
+// Rank scores subtitles against release and the preferred languages,
+// earliest first, and returns them best first. Results in other languages
+// or for another episode are left out; an empty languages accepts all.
+func Rank(subtitles []models.Subtitle, release *Release, languages []string, hearingImpaired *bool) []Candidate {
+	var candidates []Candidate
+	for _, subtitle := range subtitles {
+		score, ok := rankLanguage(subtitle.Language, languages)
+		if !ok {
+			continue
+		}
+		if release.Episode > 0 && subtitle.Episode > 0 {
+			if subtitle.Season != release.Season || subtitle.Episode != release.Episode {
+				continue
+			}
+			score += 40
+		}
+		if release.Year > 0 && subtitle.Year > 0 {
+			if subtitle.Year == release.Year {
+				score += 20
+			} else {
+				score -= 30
+			}
+		}
+		if overlaps(release.Group, subtitle.Group) {
+			score += 30
+		}
+		if overlaps(release.Quality, subtitle.Quality) {
+			score += 15
+		}
+		if overlaps(release.Resolution, subtitle.Resolution) {
+			score += 10
+		}
+		if release.Name != "" && strings.Contains(normalizeRelease(subtitle.Description), normalizeRelease(release.Name)) {
+			score += 50
+		}
+		if hearingImpaired != nil {
+			if subtitle.HearingImpaired == *hearingImpaired {
+				score += 5
+			} else {
+				score -= 20
+			}
+		}
+		if subtitle.Trusted {
+			score += 10
+		}
+		if subtitle.MachineTranslated {
+			score -= 40
+		}
+		// popularity only breaks ties between otherwise equal matches
+		score += min(int(math.Log10(float64(subtitle.Downloads)+1))*2, 10)
+		score += int(subtitle.Rating)
+		candidates = append(candidates, Candidate{Subtitle: subtitle, Score: score})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	return candidates
+}
+
+// rankLanguage scores the last preferred language 1000 and every earlier
+// one 1000 more, which no other bonus can make up for. "es" matches
+// regional variants such as "es-MX".
+func rankLanguage(language string, languages []string) (int, bool) {
+	if len(languages) == 0 {
+		return 0, true
+	}
+	language = strings.ToLower(language)
+	for i, preferred := range languages {
+		preferred = strings.ToLower(preferred)
+		if language == preferred || strings.HasPrefix(language, preferred+"-") {
+			return 1000 * (len(languages) - i), true
+		}
+	}
+	return 0, false
+}
+
+func normalizeRelease(name string) string {
+	return strings.ToLower(releaseTokenRe.ReplaceAllString(name, "."))
+}
+
+func overlaps(a []string, b []string) bool {
+	for _, item := range a {
+		if contains(b, item) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(items []string, value string) bool {
+	for _, item := range items {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
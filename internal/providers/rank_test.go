@@ -0,0 +1,143 @@
+package providers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+func TestParseRelease(t *testing.T) {
+	tests := []struct {
+		name string
+		want Release
+	}{
+		{
+			name: "Breaking.Bad.S01E02.720p.BluRay.x264-DEMAND",
+			want: Release{Title: "Breaking Bad", Season: 1, Episode: 2, Quality: []string{"bluray"}, Resolution: []string{"720p"}},
+		},
+		{
+			name: "The Office 2x05 HDTV XviD-LOL",
+			want: Release{Title: "The Office", Season: 2, Episode: 5, Group: []string{"lol"}, Quality: []string{"hdtv"}},
+		},
+		{
+			name: "1917.2019.1080p.WEB-DL.DDP5.1.H264-CMRG",
+			want: Release{Title: "1917", Year: 2019, Group: []string{"cmrg"}, Quality: []string{"web-dl"}, Resolution: []string{"1080p"}},
+		},
+		{
+			name: "Blade Runner 2049 (2017) 2160p",
+			want: Release{Title: "Blade Runner 2049", Year: 2017, Resolution: []string{"2160p"}},
+		},
+		{
+			name: "[Group] Dune",
+			want: Release{Title: "Group Dune"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseRelease(tt.name)
+			tt.want.Name = tt.name
+			if fmt.Sprint(*got) != fmt.Sprint(tt.want) {
+				t.Errorf("ParseRelease() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	yes, no := true, false
+	release := ParseRelease("Breaking.Bad.S01E02.720p.BluRay.x264-LOL")
+
+	tests := []struct {
+		name            string
+		subtitles       []models.Subtitle
+		languages       []string
+		hearingImpaired *bool
+		want            []string
+	}{
+		{
+			name: "preferred language wins over a better match",
+			subtitles: []models.Subtitle{
+				{ExternalId: "en", Language: "en", Season: 1, Episode: 2, Group: []string{"lol"}, Quality: []string{"bluray"}},
+				{ExternalId: "es", Language: "es-MX", Season: 1, Episode: 2},
+				{ExternalId: "fr", Language: "fr", Season: 1, Episode: 2},
+			},
+			languages: []string{"es", "en"},
+			want:      []string{"es", "en"},
+		},
+		{
+			name: "other episodes are left out",
+			subtitles: []models.Subtitle{
+				{ExternalId: "s01e03", Language: "en", Season: 1, Episode: 3},
+				{ExternalId: "s01e02", Language: "en", Season: 1, Episode: 2},
+				{ExternalId: "season", Language: "en"},
+			},
+			want: []string{"s01e02", "season"},
+		},
+		{
+			name: "group, quality and resolution add up",
+			subtitles: []models.Subtitle{
+				{ExternalId: "resolution", Resolution: []string{"720p"}},
+				{ExternalId: "group", Group: []string{"LOL"}},
+				{ExternalId: "none"},
+				{ExternalId: "quality", Quality: []string{"bluray"}},
+			},
+			want: []string{"group", "quality", "resolution", "none"},
+		},
+		{
+			name: "exact release name beats tags",
+			subtitles: []models.Subtitle{
+				{ExternalId: "tags", Group: []string{"lol"}, Quality: []string{"bluray"}},
+				{ExternalId: "exact", Description: "breaking bad s01e02 720p bluray x264 lol"},
+			},
+			want: []string{"exact", "tags"},
+		},
+		{
+			name: "machine translations and wrong hearing impaired sink",
+			subtitles: []models.Subtitle{
+				{ExternalId: "machine", MachineTranslated: true, HearingImpaired: true},
+				{ExternalId: "plain"},
+				{ExternalId: "hi", HearingImpaired: true},
+			},
+			hearingImpaired: &yes,
+			want:            []string{"hi", "plain", "machine"},
+		},
+		{
+			name: "popularity breaks ties",
+			subtitles: []models.Subtitle{
+				{ExternalId: "few", Downloads: 10},
+				{ExternalId: "many", Downloads: 100000},
+				{ExternalId: "rated", Downloads: 10, Rating: 3},
+			},
+			hearingImpaired: &no,
+			want:            []string{"many", "rated", "few"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, candidate := range Rank(tt.subtitles, release, tt.languages, tt.hearingImpaired) {
+				got = append(got, candidate.ExternalId)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Rank() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankYear(t *testing.T) {
+	release := ParseRelease("Dune.2021.1080p")
+	subtitles := []models.Subtitle{
+		{ExternalId: "1984", Year: 1984},
+		{ExternalId: "unknown"},
+		{ExternalId: "2021", Year: 2021},
+	}
+	var got []string
+	for _, candidate := range Rank(subtitles, release, nil, nil) {
+		got = append(got, candidate.ExternalId)
+	}
+	if want := "[2021 unknown 1984]"; fmt.Sprint(got) != want {
+		t.Errorf("Rank() = %v, want %v", got, want)
+	}
+}
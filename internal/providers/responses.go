@@ -57,6 +57,10 @@ type OpenSubtitlesItemFeature struct {
 	MovieName   string `json:"movie_name"`
 	ImdbId      int    `json:"imdb_id"`
 	TmdbId      int    `json:"tmdb_id"`
+
+	ParentTitle   string `json:"parent_title,omitempty"`
+	SeasonNumber  int    `json:"season_number,omitempty"`
+	EpisodeNumber int    `json:"episode_number,omitempty"`
 }
 
 type OpenSubtitlesItemAttr struct {
@@ -119,3 +123,46 @@ type SubXResponseItem struct {
 	PostedAt     string `json:"posted_at"`
 	Downloads    int    `json:"downloads"`
 }
+
+/* Podnapisi Api Response */
+type PodnapisiResponse struct {
+	Status   string          `json:"status"`
+	Page     int             `json:"page"`
+	AllPages int             `json:"all_pages"`
+	Data     []PodnapisiItem `json:"data"`
+}
+
+type PodnapisiItem struct {
+	Id       string   `json:"id"`
+	Title    string   `json:"title"`
+	Language string   `json:"language"`
+	Releases []string `json:"releases"`
+	Flags    []string `json:"flags"`
+	Download string   `json:"download"`
+	Movie    struct {
+		Title       string `json:"title"`
+		Year        int    `json:"year"`
+		Type        string `json:"type"`
+		EpisodeInfo *struct {
+			Season  int `json:"season"`
+			Episode int `json:"episode"`
+		} `json:"episode_info,omitempty"`
+	} `json:"movie"`
+	Stats struct {
+		Downloads int `json:"downloads"`
+	} `json:"stats"`
+}
+
+/* Addic7ed season listing row */
+type Addic7edEpisode struct {
+	Season          int
+	Episode         int
+	Title           string
+	Language        string
+	Version         string
+	Completed       bool
+	HearingImpaired bool
+	Corrected       bool
+	HD              bool
+	Download        string
+}
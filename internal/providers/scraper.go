@@ -0,0 +1,685 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v3"
+)
+
+var scraperExtensions = map[string]bool{".srt": true, ".ass": true, ".ssa": true, ".vtt": true, ".sub": true, ".zip": true, ".rar": true}
+
+/* Declarative scraper definitions, loaded from YAML */
+type scraperFile struct {
+	Providers []scraperDefinition `yaml:"providers"`
+}
+
+type scraperDefinition struct {
+	Name      string            `yaml:"name"`
+	Enabled   *bool             `yaml:"enabled"`
+	BaseUrl   string            `yaml:"base_url"`
+	Format    string            `yaml:"format"`
+	Language  string            `yaml:"language"`
+	UserAgent string            `yaml:"user_agent"`
+	Headers   map[string]string `yaml:"headers"`
+	Auth      struct {
+		Header string `yaml:"header"`
+		Value  string `yaml:"value"`
+	} `yaml:"auth"`
+	Search   scraperSearch   `yaml:"search"`
+	Download scraperDownload `yaml:"download"`
+}
+
+type scraperSearch struct {
+	Method     string            `yaml:"method"`
+	Path       string            `yaml:"path"`
+	Query      map[string]string `yaml:"query"`
+	Form       map[string]string `yaml:"form"`
+	Body       string            `yaml:"body"`
+	Results    string            `yaml:"results"`
+	Fields     scraperFields     `yaml:"fields"`
+	Pagination struct {
+		Param    string `yaml:"param"`
+		Start    int    `yaml:"start"`
+		MaxPages int    `yaml:"max_pages"`
+	} `yaml:"pagination"`
+}
+
+type scraperFields struct {
+	Id          scraperField `yaml:"id"`
+	Title       scraperField `yaml:"title"`
+	Description scraperField `yaml:"description"`
+	Language    scraperField `yaml:"language"`
+	Download    scraperField `yaml:"download"`
+}
+
+type scraperField struct {
+	Selector string `yaml:"selector"`
+	Attr     string `yaml:"attr"`
+	Regex    string `yaml:"regex"`
+}
+
+type scraperDownload struct {
+	Method  string            `yaml:"method"`
+	Path    string            `yaml:"path"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// UnmarshalYAML accepts a bare selector string as shorthand.
+func (f *scraperField) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		f.Selector = node.Value
+		return nil
+	}
+	type plain scraperField
+	return node.Decode((*plain)(f))
+}
+
+// compiledField is a field selector compiled when the definition loads,
+// so a bad selector is rejected up front instead of failing a search.
+type compiledField struct {
+	css      cascadia.Selector
+	self     bool
+	xpath    *xpath.Expr
+	jsonPath []jsonPathStep
+	attr     string
+	re       *regexp.Regexp
+}
+
+type scraperTemplateData struct {
+	Term     string
+	Year     int
+	Season   int
+	Episode  int
+	Language string
+	Page     int
+	Id       string
+	BaseUrl  string
+}
+
+// scraper is a compiled scraperDefinition ready to run requests.
+type scraper struct {
+	def         scraperDefinition
+	base        *url.URL
+	headers     map[string]string
+	searchPath  *template.Template
+	query       map[string]*template.Template
+	form        map[string]*template.Template
+	body        *template.Template
+	results     compiledField
+	fields      map[string]compiledField
+	downloadTpl *template.Template
+}
+
+// loadScrapers reads every definition from a YAML file or a directory of them.
+func loadScrapers(path string) ([]*scraper, error) {
+	files := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if info.IsDir() {
+		files = nil
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, _ := filepath.Glob(filepath.Join(path, pattern))
+			files = append(files, matches...)
+		}
+	}
+
+	var scrapers []*scraper
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var defs scraperFile
+		if err := yaml.Unmarshal(raw, &defs); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, def := range defs.Providers {
+			s, err := compileScraper(def)
+			if err != nil {
+				return nil, fmt.Errorf("%s: provider %q: %w", file, def.Name, err)
+			}
+			scrapers = append(scrapers, s)
+		}
+	}
+	return scrapers, nil
+}
+
+func compileScraper(def scraperDefinition) (*scraper, error) {
+	if def.Name == "" || def.BaseUrl == "" {
+		return nil, errors.New("name and base_url are required")
+	}
+	if def.Format == "" {
+		def.Format = "html"
+	}
+	if def.Format != "html" && def.Format != "json" {
+		return nil, fmt.Errorf("unsupported format %q", def.Format)
+	}
+	if def.Search.Method == "" {
+		def.Search.Method = http.MethodGet
+	}
+	if def.Download.Method == "" {
+		def.Download.Method = http.MethodGet
+	}
+	if def.Search.Pagination.MaxPages <= 0 {
+		def.Search.Pagination.MaxPages = 1
+	}
+	base, err := url.Parse(strings.TrimSuffix(def.BaseUrl, "/") + "/")
+	if err != nil {
+		return nil, err
+	}
+	s := &scraper{def: def, base: base, headers: map[string]string{}, fields: map[string]compiledField{}}
+
+	for k, v := range def.Headers {
+		s.headers[k] = os.ExpandEnv(v)
+	}
+	if def.Auth.Header != "" {
+		s.headers[def.Auth.Header] = os.ExpandEnv(def.Auth.Value)
+	}
+	if def.UserAgent != "" {
+		s.headers["User-Agent"] = def.UserAgent
+	}
+
+	if s.searchPath, err = parseScraperTemplate("path", def.Search.Path); err != nil {
+		return nil, err
+	}
+	if s.query, err = compileTemplates(def.Search.Query); err != nil {
+		return nil, err
+	}
+	if s.form, err = compileTemplates(def.Search.Form); err != nil {
+		return nil, err
+	}
+	if def.Search.Body != "" {
+		if s.body, err = parseScraperTemplate("body", def.Search.Body); err != nil {
+			return nil, err
+		}
+	}
+	if def.Download.Path != "" {
+		if s.downloadTpl, err = parseScraperTemplate("download", def.Download.Path); err != nil {
+			return nil, err
+		}
+	}
+	if def.Search.Results == "" {
+		return nil, errors.New("search.results selector is required")
+	}
+	if s.results, err = compileField(def.Format, scraperField{Selector: def.Search.Results}); err != nil {
+		return nil, err
+	}
+	fields := map[string]scraperField{
+		"id":          def.Search.Fields.Id,
+		"title":       def.Search.Fields.Title,
+		"description": def.Search.Fields.Description,
+		"language":    def.Search.Fields.Language,
+		"download":    def.Search.Fields.Download,
+	}
+	for name, field := range fields {
+		if field.Selector == "" {
+			continue
+		}
+		if s.fields[name], err = compileField(def.Format, field); err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+	}
+	if _, ok := s.fields["title"]; !ok {
+		return nil, errors.New("search.fields.title is required")
+	}
+	if s.downloadTpl == nil {
+		if _, ok := s.fields["download"]; !ok {
+			return nil, errors.New("either download.path or search.fields.download is required")
+		}
+	} else if _, ok := s.fields["id"]; !ok {
+		return nil, errors.New("download.path needs search.fields.id")
+	}
+	return s, nil
+}
+
+// capabilities derives the search keys from the fields the request
+// templates reference.
+func (s *scraper) capabilities() Capabilities {
+	templates := []string{s.def.Search.Path, s.def.Search.Body}
+	for _, v := range s.def.Search.Query {
+		templates = append(templates, v)
+	}
+	for _, v := range s.def.Search.Form {
+		templates = append(templates, v)
+	}
+	source := strings.Join(templates, " ")
+
+	caps := Capabilities{SearchKeys: []string{models.SearchKeyText}}
+	if strings.Contains(source, ".Year") {
+		caps.SearchKeys = append(caps.SearchKeys, models.SearchKeyYear)
+	}
+	if strings.Contains(source, ".Season") || strings.Contains(source, ".Episode") {
+		caps.SearchKeys = append(caps.SearchKeys, models.SearchKeyEpisode)
+	}
+	if strings.Contains(source, ".Language") {
+		caps.SearchKeys = append(caps.SearchKeys, models.SearchKeyLanguage)
+	}
+	if s.def.Language != "" && s.def.Search.Fields.Language.Selector == "" {
+		caps.Languages = []string{s.def.Language}
+	}
+	return caps
+}
+
+// scraperFuncs lets templates escape values themselves, e.g. in a body;
+// text/template already provides urlquery.
+var scraperFuncs = template.FuncMap{"pathescape": url.PathEscape}
+
+func parseScraperTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Funcs(scraperFuncs).Parse(text)
+}
+
+func compileTemplates(values map[string]string) (map[string]*template.Template, error) {
+	templates := map[string]*template.Template{}
+	for k, v := range values {
+		t, err := parseScraperTemplate(k, v)
+		if err != nil {
+			return nil, err
+		}
+		templates[k] = t
+	}
+	return templates, nil
+}
+
+func compileField(format string, field scraperField) (compiledField, error) {
+	c := compiledField{attr: field.Attr}
+	var err error
+	switch {
+	case format == "json":
+		c.jsonPath, err = compileJsonPath(field.Selector)
+	case strings.HasPrefix(field.Selector, "xpath:"):
+		if c.xpath, err = xpath.Compile(strings.TrimPrefix(field.Selector, "xpath:")); err != nil {
+			err = fmt.Errorf("invalid xpath %q: %w", field.Selector, err)
+		}
+	case strings.TrimPrefix(field.Selector, "css:") == ".":
+		c.self = true
+	default:
+		if c.css, err = cascadia.Compile(strings.TrimPrefix(field.Selector, "css:")); err != nil {
+			err = fmt.Errorf("invalid css selector %q: %w", field.Selector, err)
+		}
+	}
+	if err != nil {
+		return c, err
+	}
+	if field.Regex != "" {
+		if c.re, err = regexp.Compile(field.Regex); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+func render(t *template.Template, data *scraperTemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// scraperUrlMarkers stand in for the text fields while a search or
+// download path is rendered, so each value can be escaped for the part of
+// the url it lands in. urlquery and pathescape leave them as they are.
+var scraperUrlMarkers = struct{ term, language, id string }{"xScraperTermx", "xScraperLanguagex", "xScraperIdx"}
+
+// renderUrl renders a search or download path with Term, Language and Id
+// escaped by default, so that a search term cannot add path segments or
+// query parameters.
+func renderUrl(t *template.Template, data *scraperTemplateData) (string, error) {
+	markers := scraperUrlMarkers
+	marked := *data
+	marked.Term, marked.Language, marked.Id = markers.term, markers.language, markers.id
+	rendered, err := render(t, &marked)
+	if err != nil {
+		return "", err
+	}
+	path, query, hasQuery := strings.Cut(rendered, "?")
+	for marker, value := range map[string]string{markers.term: data.Term, markers.language: data.Language, markers.id: data.Id} {
+		path = strings.ReplaceAll(path, marker, escapeSegment(value))
+		query = strings.ReplaceAll(query, marker, url.QueryEscape(value))
+	}
+	if hasQuery {
+		path += "?" + query
+	}
+	return path, nil
+}
+
+// escapeSegment escapes a path segment, dots included when they would
+// climb up the path.
+func escapeSegment(value string) string {
+	if value == "." || value == ".." {
+		return strings.ReplaceAll(value, ".", "%2E")
+	}
+	return url.PathEscape(value)
+}
+
+func renderAll(templates map[string]*template.Template, data *scraperTemplateData) (map[string]string, error) {
+	values := map[string]string{}
+	for k, t := range templates {
+		v, err := render(t, data)
+		if err != nil {
+			return nil, err
+		}
+		if v != "" {
+			values[k] = v
+		}
+	}
+	return values, nil
+}
+
+func searchScraper(provider *ProviderParams, req *models.SearchRequest) ([]models.Subtitle, error) {
+	s := provider.config.scraper
+	tracer := otel.Tracer(s.def.Name)
+	ctx, span := tracer.Start(provider.ctx, "Scraper.Search")
+	defer span.End()
+	span.SetAttributes(attribute.String("provider", s.def.Name), attribute.String("query", req.Term))
+
+	var items []map[string]string
+	var fetchErr error
+	pagination := s.def.Search.Pagination
+	for page := pagination.Start; page < pagination.Start+pagination.MaxPages; page++ {
+		data := &scraperTemplateData{
+			Term:     req.Term,
+			Year:     req.Year,
+			Season:   req.Season,
+			Episode:  req.Episode,
+			Language: req.Language,
+			Page:     page,
+			BaseUrl:  s.base.String(),
+		}
+		found, err := s.fetchPage(ctx, provider, data)
+		if err != nil {
+			span.RecordError(err)
+			provider.logger.Err(err).Msgf("error while scraping %s page %d", s.def.Name, page)
+			fetchErr = err
+			break
+		}
+		items = append(items, found...)
+		if len(found) == 0 || pagination.Param == "" {
+			break
+		}
+	}
+
+	if len(items) == 0 && fetchErr != nil {
+		return nil, fetchErr
+	}
+	subtitles := s.translate(items)
+	span.SetAttributes(attribute.Int("subtitle_count", len(subtitles)))
+	provider.logger.Info().Msgf("returned %d subtitles", len(subtitles))
+	return subtitles, nil
+}
+
+func (s *scraper) fetchPage(ctx context.Context, provider *ProviderParams, data *scraperTemplateData) ([]map[string]string, error) {
+	path, err := renderUrl(s.searchPath, data)
+	if err != nil {
+		return nil, err
+	}
+	query, err := renderAll(s.query, data)
+	if err != nil {
+		return nil, err
+	}
+	if s.def.Search.Pagination.Param != "" {
+		query[s.def.Search.Pagination.Param] = strconv.Itoa(data.Page)
+	}
+	form, err := renderAll(s.form, data)
+	if err != nil {
+		return nil, err
+	}
+
+	r := provider.r.R().
+		SetContext(ctx).
+		SetHeaders(s.headers).
+		SetQueryParams(query).
+		SetDebug(provider.config.debug)
+	if len(form) > 0 {
+		r.SetFormData(form)
+	}
+	if s.body != nil {
+		body, err := render(s.body, data)
+		if err != nil {
+			return nil, err
+		}
+		r.SetBody(body)
+	}
+	res, err := r.Execute(s.def.Search.Method, s.resolve(path))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("%s search failed with status %d", s.def.Name, res.StatusCode())
+	}
+	if s.def.Format == "json" {
+		return s.extractJson(res.Body())
+	}
+	return s.extractHtml(res.Body())
+}
+
+func (s *scraper) resolve(path string) string {
+	ref, err := url.Parse(strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return s.base.String() + path
+	}
+	return s.base.ResolveReference(ref).String()
+}
+
+func (s *scraper) extractHtml(body []byte) ([]map[string]string, error) {
+	root, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*html.Node
+	switch {
+	case s.results.xpath != nil:
+		nodes = htmlquery.QuerySelectorAll(root, s.results.xpath)
+	case s.results.self:
+		nodes = []*html.Node{root}
+	default:
+		nodes = cascadia.QueryAll(root, s.results.css)
+	}
+
+	var items []map[string]string
+	for _, node := range nodes {
+		item := map[string]string{}
+		for name, field := range s.fields {
+			item[name] = field.apply(htmlFieldValue(node, field))
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func htmlFieldValue(node *html.Node, field compiledField) string {
+	var target *html.Node
+	switch {
+	case field.xpath != nil:
+		target = htmlquery.QuerySelector(node, field.xpath)
+	case field.self:
+		target = node
+	default:
+		target = cascadia.Query(node, field.css)
+	}
+	if target == nil {
+		return ""
+	}
+	if field.attr != "" {
+		return htmlquery.SelectAttr(target, field.attr)
+	}
+	return strings.TrimSpace(htmlquery.InnerText(target))
+}
+
+func (s *scraper) extractJson(body []byte) ([]map[string]string, error) {
+	var root any
+	if err := json.Unmarshal(body, &root); err != nil {
+		return nil, err
+	}
+	var items []map[string]string
+	for _, node := range evalJsonPath(s.results.jsonPath, root) {
+		item := map[string]string{}
+		for name, field := range s.fields {
+			var value string
+			if values := evalJsonPath(field.jsonPath, node); len(values) > 0 {
+				value = jsonScalar(values[0])
+			}
+			item[name] = field.apply(value)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (f compiledField) apply(value string) string {
+	if f.re == nil {
+		return value
+	}
+	match := f.re.FindStringSubmatch(value)
+	switch len(match) {
+	case 0:
+		return ""
+	case 1:
+		return match[0]
+	default:
+		return match[1]
+	}
+}
+
+func (s *scraper) translate(items []map[string]string) []models.Subtitle {
+	var subtitles []models.Subtitle
+	for _, item := range items {
+		title := strings.TrimSpace(item["title"])
+		if title == "" {
+			continue
+		}
+		externalId := item["id"]
+		if s.downloadTpl == nil {
+			// the download link is the id, so downloads need no extra state
+			externalId = base64.RawURLEncoding.EncodeToString([]byte(item["download"]))
+		}
+		if externalId == "" {
+			continue
+		}
+		desc := strings.TrimSpace(item["description"])
+		language := item["language"]
+		if language == "" {
+			language = s.def.Language
+		}
+		itemType, season, episode := parseTitle(title)
+		group, quality, resolution, duration := parseExtra(desc)
+		var year int
+		if y := Parse(title, "year"); y != nil {
+			year, _ = strconv.Atoi(y[0])
+		}
+		subtitles = append(subtitles, models.Subtitle{
+			Provider:    s.def.Name,
+			Type:        itemType,
+			Id:          len(subtitles),
+			ExternalId:  externalId,
+			Title:       title,
+			Description: desc,
+			Language:    language,
+			Group:       group,
+			Quality:     quality,
+			Resolution:  resolution,
+			Duration:    duration,
+			Year:        year,
+			Season:      season,
+			Episode:     episode,
+		})
+	}
+	return subtitles
+}
+
+// downloadUrl maps a subtitle id back to its link, refusing links that
+// leave the provider's host.
+func (s *scraper) downloadUrl(subtitleId string) (string, error) {
+	var link *url.URL
+	if s.downloadTpl != nil {
+		if subtitleId == "" || subtitleId == "." || subtitleId == ".." {
+			return "", fmt.Errorf("%w: invalid subtitle id %q", ErrSubtitleNotFound, subtitleId)
+		}
+		path, err := renderUrl(s.downloadTpl, &scraperTemplateData{Id: subtitleId, BaseUrl: s.base.String()})
+		if err != nil {
+			return "", err
+		}
+		if link, err = url.Parse(s.resolve(path)); err != nil {
+			return "", err
+		}
+	} else {
+		raw, err := base64.RawURLEncoding.DecodeString(subtitleId)
+		if err != nil {
+			return "", fmt.Errorf("%w: invalid subtitle id %q", ErrSubtitleNotFound, subtitleId)
+		}
+		if link, err = s.base.Parse(string(raw)); err != nil {
+			return "", fmt.Errorf("%w: invalid subtitle id %q", ErrSubtitleNotFound, subtitleId)
+		}
+	}
+	if link.Host != s.base.Host {
+		return "", fmt.Errorf("%w: download link outside of %s", ErrSubtitleNotFound, s.base.Host)
+	}
+	return link.String(), nil
+}
+
+func downloadScraper(provider *ProviderParams, subtitleId string) (*models.SubtitleFile, error) {
+	s := provider.config.scraper
+	tracer := otel.Tracer(s.def.Name)
+	ctx, span := tracer.Start(provider.ctx, "Scraper.Download")
+	defer span.End()
+	span.SetAttributes(attribute.String("provider", s.def.Name), attribute.String("subtitle_id", subtitleId))
+
+	link, err := s.downloadUrl(subtitleId)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{}
+	for k, v := range s.headers {
+		headers[k] = v
+	}
+	for k, v := range s.def.Download.Headers {
+		headers[k] = os.ExpandEnv(v)
+	}
+	res, err := provider.r.R().
+		SetContext(ctx).
+		SetHeaders(headers).
+		SetDoNotParseResponse(true).
+		SetDebug(provider.config.debug).
+		Execute(s.def.Download.Method, link)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	contentType := res.Header().Get("Content-Type")
+	name := filepath.Base(res.RawResponse.Request.URL.Path)
+	if !scraperExtensions[strings.ToLower(filepath.Ext(name))] {
+		ext := ".srt"
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			ext = exts[0]
+		}
+		name = s.def.Name + ext
+	}
+	file, err := httpDownload(s.def.Name, res, name)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	provider.logger.Info().Msgf("downloading file: %s", file.Filename)
+	return file, nil
+}
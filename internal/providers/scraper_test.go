@@ -0,0 +1,217 @@
+package providers
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+// scraperSite records the requests of a scraper and answers with handler.
+type scraperSite struct {
+	handler func(w http.ResponseWriter, r *http.Request)
+
+	mu       sync.Mutex
+	requests []*url.URL
+}
+
+func (s *scraperSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.URL)
+	s.mu.Unlock()
+	s.handler(w, r)
+}
+
+// seen returns the escaped path and query of every request.
+func (s *scraperSite) seen() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var seen []string
+	for _, u := range s.requests {
+		seen = append(seen, u.EscapedPath()+"?"+u.RawQuery)
+	}
+	return seen
+}
+
+// testScraper compiles the named definition of testdata/scraper and points
+// it at site.
+func testScraper(t *testing.T, name string, site *scraperSite) *ProviderParams {
+	t.Helper()
+	scrapers, err := loadScrapers(filepath.Join("testdata", "scraper", "definitions.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range scrapers {
+		if s.def.Name != name {
+			continue
+		}
+		provider := testProvider(t, site, &ProviderConfig{scraper: s})
+		if s.base, err = url.Parse(provider.config.url); err != nil {
+			t.Fatal(err)
+		}
+		return provider
+	}
+	t.Fatalf("no scraper %s in the definitions", name)
+	return nil
+}
+
+func TestSearchScraperCss(t *testing.T) {
+	site := &scraperSite{handler: func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/files/"):
+			io.WriteString(w, "1\n00:00:01,000 --> 00:00:02,000\nHola\n")
+		case r.URL.Query().Get("page") == "1":
+			w.Write(fixture(t, "scraper/results.html"))
+		default:
+			io.WriteString(w, "<html><body></body></html>")
+		}
+	}}
+	provider := testScraper(t, "csssubs", site)
+
+	// the term stays one path segment, whatever it holds
+	subtitles, err := searchScraper(provider, &models.SearchRequest{Term: "the matrix/../admin?x=1", Year: 1999})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/search/the%20matrix%2F..%2Fadmin%3Fx=1?page=1&year=1999",
+		"/search/the%20matrix%2F..%2Fadmin%3Fx=1?page=2&year=1999",
+	}
+	if seen := site.seen(); strings.Join(seen, " ") != strings.Join(want, " ") {
+		t.Errorf("requests = %v, want %v", seen, want)
+	}
+
+	// results without a title are left out
+	if len(subtitles) != 2 {
+		t.Fatalf("subtitles = %+v, want 2", subtitles)
+	}
+	first := subtitles[0]
+	if first.Provider != "csssubs" || first.Title != "The Matrix (1999)" || first.Year != 1999 || first.Language != "es" || first.Description != "The.Matrix.1999.1080p.BluRay.x264-GRP" {
+		t.Errorf("subtitle = %+v", first)
+	}
+	if link, _ := base64.RawURLEncoding.DecodeString(first.ExternalId); string(link) != "/files/matrix-1080p.srt" {
+		t.Errorf("subtitle id holds %q, want the download link", link)
+	}
+
+	file, err := downloadScraper(provider, first.ExternalId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Body.Close()
+	if file.Filename != "matrix-1080p.srt" {
+		t.Errorf("filename = %q", file.Filename)
+	}
+	// links to other hosts are not followed
+	if _, err := downloadScraper(provider, subtitles[1].ExternalId); !errors.Is(err, ErrSubtitleNotFound) {
+		t.Errorf("download of a link elsewhere error = %v, want ErrSubtitleNotFound", err)
+	}
+}
+
+func TestSearchScraperXpath(t *testing.T) {
+	site := &scraperSite{handler: func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/find" {
+			w.Write(fixture(t, "scraper/table.html"))
+			return
+		}
+		io.WriteString(w, "1\n00:00:01,000 --> 00:00:02,000\nHi\n")
+	}}
+	provider := testScraper(t, "xpathsubs", site)
+
+	// the term cannot add query parameters either
+	subtitles, err := searchScraper(provider, &models.SearchRequest{Term: "breaking bad&season=9", Season: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen := site.seen(); len(seen) != 1 || seen[0] != "/find?q=breaking+bad%26season%3D9&season=1" {
+		t.Errorf("requests = %v", seen)
+	}
+	var got []string
+	for _, s := range subtitles {
+		got = append(got, s.ExternalId+" "+s.Title+" "+s.Description+" "+s.Language)
+	}
+	want := []string{
+		"101 Breaking Bad - 01x02 Breaking.Bad.S01E02.720p.HDTV-CTU en",
+		"102 Breaking Bad - 01x03 Breaking.Bad.S01E03.720p.HDTV-CTU en",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("subtitles = %q, want %q", got, want)
+	}
+
+	file, err := downloadScraper(provider, "101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Body.Close()
+	if _, err := downloadScraper(provider, "../../admin"); err != nil {
+		t.Fatal(err)
+	}
+	seen := site.seen()
+	if downloads := seen[len(seen)-2:]; downloads[0] != "/sub/101/download?" || downloads[1] != "/sub/..%2F..%2Fadmin/download?" {
+		t.Errorf("downloads = %v", downloads)
+	}
+	for _, id := range []string{"", ".", ".."} {
+		if _, err := downloadScraper(provider, id); !errors.Is(err, ErrSubtitleNotFound) {
+			t.Errorf("download of %q error = %v, want ErrSubtitleNotFound", id, err)
+		}
+	}
+}
+
+func TestRenderUrl(t *testing.T) {
+	tests := []struct {
+		template string
+		data     scraperTemplateData
+		want     string
+	}{
+		{"search/{{.Term}}", scraperTemplateData{Term: "a b/c"}, "search/a%20b%2Fc"},
+		{"search/{{.Term}}", scraperTemplateData{Term: ".."}, "search/%2E%2E"},
+		{"search?q={{.Term}}&lang={{.Language}}", scraperTemplateData{Term: "a&b=c", Language: "es"}, "search?q=a%26b%3Dc&lang=es"},
+		// escaping by hand does not escape twice
+		{"search/{{pathescape .Term}}?q={{urlquery .Term}}", scraperTemplateData{Term: "a b"}, "search/a%20b?q=a+b"},
+		{"s{{.Season}}e{{.Episode}}/p{{.Page}}", scraperTemplateData{Season: 1, Episode: 2, Page: 3}, "s1e2/p3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tpl, err := parseScraperTemplate("path", tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := renderUrl(tpl, &tt.data); err != nil || got != tt.want {
+				t.Errorf("renderUrl() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadScrapersRejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		wantErr    string
+	}{
+		{"bad css", "search: {path: s, results: 'div[', fields: {title: h2, download: a}}", "invalid css selector"},
+		{"bad xpath", "search: {path: s, results: 'xpath://[', fields: {title: h2, download: a}}", "invalid xpath"},
+		{"no title", "search: {path: s, results: div, fields: {download: a}}", "search.fields.title is required"},
+		{"no download", "search: {path: s, results: div, fields: {title: h2}}", "download.path or search.fields.download"},
+		{"bad template", "search: {path: '{{.Term', results: div, fields: {title: h2, download: a}}", "unclosed action"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "scrapers.yaml")
+			definition := "providers:\n  - name: broken\n    base_url: https://subs.example.com/\n    " + tt.definition + "\n"
+			if err := os.WriteFile(path, []byte(definition), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadScrapers(path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadScrapers() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,17 +1,17 @@
 package providers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/xochilpili/subtitler-api/internal/models"
 	"go.opentelemetry.io/otel"
@@ -23,79 +23,138 @@ type Token struct {
 	Token  string `json:"token"`
 }
 
-func searchDivx(provider *ProviderParams, query string) []models.Subtitle {
-	tracer := otel.Tracer("subdivx")
-	ctx, span := tracer.Start(provider.ctx, "Subdivx.Search")
-	defer span.End()
+var (
+	errSubdivxSessionRejected = errors.New("subdivx rejected the session")
+	subdivxVersionRe          = regexp.MustCompile(`<div[^>]*id="vs"[^>]*>([^<]+)</div>`)
+)
 
-	span.SetAttributes(attribute.String("query", query))
-	/*ctx, cancel := context.WithTimeout(provider.ctx, 30*time.Second)
-	provider.ctx = ctx
-	defer cancel()*/
-	provider.logger.Info().Msgf("searching subtitles for: %s", query)
+// subdivxSession caches the site version, search token and cookie so they
+// are only fetched again once subdivx rejects them or they expire.
+type subdivxSession struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	version string
+	token   *Token
+	expires time.Time
+}
+
+func newSubdivxSession(ttl time.Duration) *subdivxSession {
+	return &subdivxSession{ttl: ttl}
+}
+
+// get returns the cached session, refreshing it first when needed.
+func (s *subdivxSession) get(provider *ProviderParams) (string, *Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != nil && time.Now().Before(s.expires) {
+		return s.version, s.token, nil
+	}
+
+	tracer := otel.Tracer("subdivx")
+	ctx := provider.ctx
+	defer func() { provider.ctx = ctx }()
 
 	ctxVersion, spanVersion := tracer.Start(ctx, "Get Version")
 	provider.ctx = ctxVersion
 	version, err := getVersion(provider)
 	spanVersion.End()
-
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(499, "error while getting version")
-		return nil
+		return "", nil, err
 	}
-	span.AddEvent("Version retrieved")
 
 	ctxToken, spanToken := tracer.Start(ctx, "Get token")
 	provider.ctx = ctxToken
 	token, err := getToken(provider)
 	spanToken.End()
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(499, "failed to get token")
-		return nil
+		return "", nil, err
 	}
-	span.AddEvent("Token received")
 
-	provider.logger.Debug().Msgf("token: %s, cookie: %s", token.Token, token.Cookie)
+	s.version = version
+	s.token = token
+	s.expires = time.Now().Add(s.ttl)
+	return version, token, nil
+}
 
-	params := &SubdivxSubPayload{
-		Tabla:   "resultados",
-		Filtros: "",
-		Buscar:  query,
-		Token:   token.Token,
-	}
-	buscaVersion := fmt.Sprintf("buscar%s", version)
-
-	ctxFetch, spanFetch := tracer.Start(ctx, "Fetch Subtitles")
-	queryParams := map[string]string{
-		"tabla":      params.Tabla,
-		"filtros":    params.Filtros,
-		buscaVersion: params.Buscar,
-		"token":      params.Token,
+// invalidate drops the session unless another search already replaced it.
+func (s *subdivxSession) invalidate(token *Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == token {
+		s.token = nil
 	}
+}
 
-	provider.ctx = ctxFetch
-	data, err := getSubtitles(provider, queryParams, token.Cookie)
-	spanFetch.End()
-	if err != nil {
-		provider.logger.Err(err).Msg("error while getting subtitles")
-		span.RecordError(err)
-		span.SetStatus(499, "failed to fetch subtitles")
-		return nil
+func searchDivx(provider *ProviderParams, req *models.SearchRequest) ([]models.Subtitle, error) {
+	query := req.Term
+	tracer := otel.Tracer("subdivx")
+	ctx, span := tracer.Start(provider.ctx, "Subdivx.Search")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("query", query))
+	provider.logger.Info().Msgf("searching subtitles for: %s", query)
+
+	var data []models.Subtitle
+	for attempt := 0; attempt < 2; attempt++ {
+		provider.ctx = ctx
+		version, token, err := provider.config.subdivx.get(provider)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(499, "error while getting session")
+			return nil, err
+		}
+		provider.logger.Debug().Msgf("token: %s, cookie: %s", token.Token, token.Cookie)
+
+		params := &SubdivxSubPayload{
+			Tabla:   "resultados",
+			Filtros: "",
+			Buscar:  query,
+			Token:   token.Token,
+		}
+		buscaVersion := fmt.Sprintf("buscar%s", version)
+
+		ctxFetch, spanFetch := tracer.Start(ctx, "Fetch Subtitles")
+		queryParams := map[string]string{
+			"tabla":      params.Tabla,
+			"filtros":    params.Filtros,
+			buscaVersion: params.Buscar,
+			"token":      params.Token,
+		}
+
+		provider.ctx = ctxFetch
+		data, err = getSubtitles(provider, queryParams, token.Cookie)
+		spanFetch.End()
+		if errors.Is(err, errSubdivxSessionRejected) {
+			span.AddEvent("Session rejected, refreshing")
+			provider.config.subdivx.invalidate(token)
+			if attempt > 0 {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			provider.logger.Err(err).Msg("error while getting subtitles")
+			span.RecordError(err)
+			span.SetStatus(499, "failed to fetch subtitles")
+			return nil, err
+		}
+		break
 	}
 	span.SetAttributes(attribute.Int("subtitle_count", len(data)))
-	return data
+	return data, nil
 }
 
 func getVersion(provider *ProviderParams) (string, error) {
-	res, err := provider.r.R().Get(provider.config.url)
+	res, err := provider.r.R().
+		SetContext(provider.ctx).
+		SetHeaders(map[string]string{"User-Agent": provider.config.userAgent}).
+		SetDebug(provider.config.debug).
+		Get(provider.config.url)
 	if err != nil {
 		provider.logger.Err(err).Msg("error while getting version")
-		return "", errors.New("error while requesting version")
+		return "", fmt.Errorf("error while requesting version: %w", err)
 	}
-	re := regexp.MustCompile(`<div[^>]*id="vs"[^>]*>([^<]+)</div>`)
-	match := re.FindStringSubmatch(string(res.Body()))
+	match := subdivxVersionRe.FindStringSubmatch(string(res.Body()))
 	if len(match) > 1 {
 		version := match[1]
 		return strings.Trim(strings.Replace(strings.TrimPrefix(version, "v"), ".", "", -1), "\n"), nil
@@ -127,20 +186,6 @@ func getToken(provider *ProviderParams) (*Token, error) {
 
 func getSubtitles(provider *ProviderParams, params map[string]string, cookie string) ([]models.Subtitle, error) {
 
-	provider.r.SetRetryCount(5).SetRetryWaitTime(5 * time.Second)
-	provider.r.AddRetryCondition(func(r *resty.Response, _ error) bool {
-		var tempResult SubdivxResponse[SubData]
-		errs := json.Unmarshal(r.Body(), &tempResult)
-		if errs != nil {
-			return false
-		}
-		ok, err := strconv.Atoi(tempResult.Secho)
-		if err != nil {
-			return false
-		}
-		return ok == 0
-	})
-
 	var result SubdivxResponse[SubData]
 	resp, err := provider.r.R().
 		SetContext(provider.ctx).
@@ -156,18 +201,21 @@ func getSubtitles(provider *ProviderParams, params map[string]string, cookie str
 		provider.logger.Err(err).Msgf("error while getting subtitles")
 		return nil, err
 	}
+	if resp.StatusCode() == http.StatusForbidden {
+		return nil, errSubdivxSessionRejected
+	}
 
 	err = json.Unmarshal(resp.Body(), &result)
 	if err != nil {
 		provider.logger.Err(err).Msg("error while unmarshal response")
 		return nil, err
 	}
+	if echo, err := strconv.Atoi(result.Secho); err == nil && echo == 0 {
+		return nil, errSubdivxSessionRejected
+	}
 
-	wg := &sync.WaitGroup{}
-	var subtitles []models.Subtitle
-	subtitlesChan := make(chan models.Subtitle, len(result.Data))
-
-	// TODO: Investigate how to resolve error when using go routines
+	subtitles := make([]models.Subtitle, 0, len(result.Data))
+	var pending []int
 	reg := regexp.MustCompile(`\n|\r\n`)
 	for _, item := range result.Data {
 		var group []string
@@ -188,17 +236,18 @@ func getSubtitles(provider *ProviderParams, params map[string]string, cookie str
 			yy, _ := strconv.Atoi(y[0])
 			year = yy
 		}
-		subtitle := &models.Subtitle{
+		subtitle := models.Subtitle{
 			Provider:    "subdivx",
 			Type:        itemType,
 			Id:          item.Id,
 			Title:       title,
 			Description: desc,
 			Language:    "es",
-			//Cds:         item.Cds,
-			Year:    year,
-			Season:  season,
-			Episode: episode,
+			Cds:         item.Cds,
+			Year:        year,
+			Season:      season,
+			Episode:     episode,
+			Downloads:   item.Downloads,
 		}
 
 		subtitle.Group = group
@@ -206,77 +255,92 @@ func getSubtitles(provider *ProviderParams, params map[string]string, cookie str
 		subtitle.Resolution = resolution
 		subtitle.Duration = duration
 
-		wg.Add(1)
-		go func(provider *ProviderParams, sub *models.Subtitle, subChan chan<- models.Subtitle, wg *sync.WaitGroup) {
-			getComments(provider, subtitle, subChan, wg)
-		}(provider, subtitle, subtitlesChan, wg)
+		if item.Comments > 0 {
+			pending = append(pending, len(subtitles))
+		}
+		subtitles = append(subtitles, subtitle)
 	}
 
-	go func() {
-		wg.Wait()
-		close(subtitlesChan)
-	}()
-
-	for item := range subtitlesChan {
-		subtitles = append(subtitles, item)
+	if enrich := provider.config.enrich; enrich != nil {
+		enrich.run(provider.ctx, pending, func(ctx context.Context, index int) {
+			params := *provider
+			params.ctx = ctx
+			sub := &subtitles[index]
+			comments, err := getComments(&params, sub.Id)
+			if err != nil {
+				provider.logger.Err(err).Msgf("error while getting comments for subtitle: %s", sub.Title)
+				return
+			}
+			sub.Comments = comments
+			applyComments(sub, comments)
+		})
 	}
 	provider.logger.Info().Msgf("returned %d subtitles", len(subtitles))
 	return subtitles, nil
 }
 
-func getComments(provider *ProviderParams, subtitle *models.Subtitle, subChan chan<- models.Subtitle, wg *sync.WaitGroup) {
-	defer wg.Done()
+func getComments(provider *ProviderParams, subtitleId int) ([]models.SubComments, error) {
 	var result SubdivxResponse[SubComments]
 	res, err := provider.r.R().
+		SetContext(provider.ctx).
 		SetHeaders(map[string]string{
 			"Content-Type": "application/x-www-form-urlencoded; charset=UTF-8",
 			"User-Agent":   provider.config.userAgent,
 		}).
 		SetFormData(map[string]string{
-			"getComentarios": strconv.Itoa(subtitle.Id),
+			"getComentarios": strconv.Itoa(subtitleId),
 		}).
 		SetDebug(provider.config.debug).
 		Post(provider.config.url + provider.config.searchUrl)
 	if err != nil {
-		provider.logger.Err(err).Msgf("error while getting comments for subtitle: %s", subtitle.Title)
+		return nil, err
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("unexpected status %d while getting comments", res.StatusCode())
 	}
 
 	err = json.Unmarshal(res.Body(), &result)
 	if err != nil {
-		provider.logger.Err(err).Msgf("error while getting comments for title: %s", subtitle.Title)
+		return nil, err
 	}
 
-	//var comments []models.SubComments
-	var group []string
-	var quality []string
-	var resolution []string
-	var duration []string
+	comments := make([]models.SubComments, 0, len(result.Data))
 	stripTags := bluemonday.StripTagsPolicy()
 	reg := regexp.MustCompile(`\n|\r\n`)
 	for _, comment := range result.Data {
-		if comment.Comment != "" {
-			desc := reg.ReplaceAllString(stripTags.Sanitize(comment.Comment), " ")
-			//nick := reg.ReplaceAllString(stripTags.Sanitize(comment.Nick), " ")
-			group, quality, resolution, duration = parseExtra(desc)
-			/* comments = append(comments, models.SubComments{
-				Id:      comment.Id,
-				Comment: desc,
-				Nick:    nick,
-				Date:    comment.Date,
-			}) */
+		if comment.Comment == "" {
+			continue
 		}
+		comments = append(comments, models.SubComments{
+			Id:      comment.Id,
+			Comment: reg.ReplaceAllString(stripTags.Sanitize(comment.Comment), " "),
+			Nick:    reg.ReplaceAllString(stripTags.Sanitize(comment.Nick), " "),
+			Date:    comment.Date,
+		})
 	}
+	return comments, nil
+}
 
-	subtitle.Group = append(subtitle.Group, group...)
-	subtitle.Quality = append(subtitle.Quality, quality...)
-	subtitle.Resolution = append(subtitle.Resolution, resolution...)
-	subtitle.Duration = append(subtitle.Duration, duration...)
+// applyComments merges release details mentioned in comments into the subtitle.
+func applyComments(subtitle *models.Subtitle, comments []models.SubComments) {
+	for _, comment := range comments {
+		group, quality, resolution, duration := parseExtra(comment.Comment)
+		subtitle.Group = append(subtitle.Group, group...)
+		subtitle.Quality = append(subtitle.Quality, quality...)
+		subtitle.Resolution = append(subtitle.Resolution, resolution...)
+		subtitle.Duration = append(subtitle.Duration, duration...)
+	}
+}
 
-	//subtitle.Comments = comments
-	subChan <- *subtitle
+func detailsDivx(provider *ProviderParams, subtitleId string) ([]models.SubComments, error) {
+	id, err := strconv.Atoi(subtitleId)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid subdivx id %s", ErrSubtitleNotFound, subtitleId)
+	}
+	return getComments(provider, id)
 }
 
-func downloadDivxSubtitle(provider *ProviderParams, subtitleId string) (io.ReadCloser, string, string, error) {
+func downloadDivxSubtitle(provider *ProviderParams, subtitleId string) (*models.SubtitleFile, error) {
 	res, err := provider.r.R().
 		SetContext(provider.ctx).
 		SetHeaders(map[string]string{
@@ -289,14 +353,15 @@ func downloadDivxSubtitle(provider *ProviderParams, subtitleId string) (io.ReadC
 		SetQueryParam("id", subtitleId).
 		Get(provider.config.url + "descargar.php")
 	if err != nil {
-		return nil, "", "", err
+		return nil, err
 	}
 
-	contentType := res.Header().Get("Content-Type")
-	ext := strings.Split(contentType, "/")[1]
-	filename := fmt.Sprintf("%s.%s", subtitleId, ext)
-	provider.logger.Info().Msgf("downloading file: %s", filename)
-	return res.RawBody(), filename, contentType, nil
+	file, err := httpDownload("subdivx", res, subtitleId)
+	if err != nil {
+		return nil, err
+	}
+	provider.logger.Info().Msgf("downloading file: %s", file.Filename)
+	return file, nil
 }
 
 func parseTitle(text string) (itemType string, season int, episode int) {
@@ -0,0 +1,247 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+// subdivxSite stands in for subdivx: a home page with the site version, a
+// token endpoint handing out t1, t2... and the search and comments ajax
+// endpoint. reject answers the nth search with the given status or sEcho.
+type subdivxSite struct {
+	reject func(search int) (status int, echo string)
+
+	mu       sync.Mutex
+	versions int
+	tokens   int
+	searches []string
+	comments []string
+}
+
+func (s *subdivxSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Path {
+	case "/":
+		s.versions++
+		fmt.Fprint(w, `<html><body><div class="footer" id="vs">v3.12</div></body></html>`)
+	case "/inc/gt.php":
+		s.tokens++
+		json.NewEncoder(w).Encode(Token{Token: fmt.Sprintf("t%d", s.tokens), Cookie: fmt.Sprintf("sdx=%d", s.tokens)})
+	case "/inc/ajax.php":
+		r.ParseForm()
+		if id := r.PostForm.Get("getComentarios"); id != "" {
+			s.comments = append(s.comments, id)
+			json.NewEncoder(w).Encode(SubdivxResponse[SubComments]{Data: []SubComments{
+				{Id: 1, Comment: "<b>Funciona</b> con la version BluRay 1080p", Nick: "ana"},
+				{Id: 2, Comment: ""},
+			}})
+			return
+		}
+		s.searches = append(s.searches, r.PostForm.Get("buscar312")+" "+r.PostForm.Get("token")+" "+r.Header.Get("Cookie"))
+		status, echo := http.StatusOK, "1"
+		if s.reject != nil {
+			status, echo = s.reject(len(s.searches))
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(SubdivxResponse[SubData]{Secho: echo, Data: []SubData{
+			{Id: 10, Title: "The Matrix (1999)", Description: "DVDRip XviD", Comments: 2},
+			{Id: 11, Title: "The Matrix Reloaded (2003)", Description: "720p"},
+		}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func subdivxConfig() *ProviderConfig {
+	return &ProviderConfig{searchUrl: "inc/ajax.php", userAgent: "subtitlerApi test", subdivx: newSubdivxSession(time.Hour)}
+}
+
+func TestSearchDivxSession(t *testing.T) {
+	tests := []struct {
+		name         string
+		reject       func(search int) (int, string)
+		searches     int
+		wantErr      bool
+		wantTokens   int
+		wantSearches []string
+	}{
+		{
+			name:         "the session is reused",
+			searches:     3,
+			wantTokens:   1,
+			wantSearches: []string{"the matrix t1 sdx=1", "the matrix t1 sdx=1", "the matrix t1 sdx=1"},
+		},
+		{
+			name: "a zero sEcho refreshes the session",
+			reject: func(search int) (int, string) {
+				if search == 2 {
+					return http.StatusOK, "0"
+				}
+				return http.StatusOK, "1"
+			},
+			searches:     2,
+			wantTokens:   2,
+			wantSearches: []string{"the matrix t1 sdx=1", "the matrix t1 sdx=1", "the matrix t2 sdx=2"},
+		},
+		{
+			name: "a 403 refreshes the session",
+			reject: func(search int) (int, string) {
+				if search == 1 {
+					return http.StatusForbidden, "1"
+				}
+				return http.StatusOK, "1"
+			},
+			searches:     2,
+			wantTokens:   2,
+			wantSearches: []string{"the matrix t1 sdx=1", "the matrix t2 sdx=2", "the matrix t2 sdx=2"},
+		},
+		{
+			name:         "a session rejected twice fails the search",
+			reject:       func(search int) (int, string) { return http.StatusForbidden, "1" },
+			searches:     1,
+			wantErr:      true,
+			wantTokens:   2,
+			wantSearches: []string{"the matrix t1 sdx=1", "the matrix t2 sdx=2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := &subdivxSite{reject: tt.reject}
+			provider := testProvider(t, site, subdivxConfig())
+			for range tt.searches {
+				provider.ctx = context.Background()
+				subtitles, err := searchDivx(provider, &models.SearchRequest{Term: "the matrix"})
+				if tt.wantErr {
+					if err == nil {
+						t.Fatal("searchDivx() succeeded, want an error")
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(subtitles) != 2 || subtitles[0].Title != "The Matrix (1999)" || subtitles[0].Year != 1999 {
+					t.Fatalf("subtitles = %+v", subtitles)
+				}
+			}
+			if site.tokens != tt.wantTokens || site.versions != tt.wantTokens {
+				t.Errorf("fetched %d tokens and %d versions, want %d of each", site.tokens, site.versions, tt.wantTokens)
+			}
+			if !slices.Equal(site.searches, tt.wantSearches) {
+				t.Errorf("searches = %q, want %q", site.searches, tt.wantSearches)
+			}
+		})
+	}
+}
+
+func TestSearchDivxConcurrentSession(t *testing.T) {
+	site := &subdivxSite{}
+	base := testProvider(t, site, subdivxConfig())
+	wg := &sync.WaitGroup{}
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			provider := *base
+			if _, err := searchDivx(&provider, &models.SearchRequest{Term: "the matrix"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if site.tokens != 1 {
+		t.Errorf("fetched %d tokens for concurrent searches, want 1", site.tokens)
+	}
+}
+
+func TestSearchDivxEnrichment(t *testing.T) {
+	site := &subdivxSite{}
+	provider := testProvider(t, site, subdivxConfig())
+	provider.config.enrich = newEnrichment(2, 10, time.Second)
+	subtitles, err := searchDivx(provider, &models.SearchRequest{Term: "the matrix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only results with comments are looked up
+	if !slices.Equal(site.comments, []string{"10"}) {
+		t.Errorf("comments fetched for %v, want 10", site.comments)
+	}
+	matrix := subtitles[0]
+	if len(matrix.Comments) != 1 || matrix.Comments[0].Comment != "Funciona con la version BluRay 1080p" {
+		t.Errorf("comments = %+v", matrix.Comments)
+	}
+	if !slices.Contains(matrix.Quality, "bluray") || !slices.Contains(matrix.Resolution, "1080p") {
+		t.Errorf("subtitle = %+v, want the release details of its comments", matrix)
+	}
+
+	comments, err := detailsDivx(provider, "11")
+	if err != nil || len(comments) != 1 {
+		t.Errorf("detailsDivx() = %+v, %v", comments, err)
+	}
+	if _, err := detailsDivx(provider, "abc"); err == nil {
+		t.Error("detailsDivx() accepted an invalid id")
+	}
+}
+
+func TestEnrichmentBounds(t *testing.T) {
+	items := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	tests := []struct {
+		name      string
+		workers   int
+		budget    int
+		wantCalls int
+	}{
+		{"the budget caps the lookups", 2, 5, 5},
+		{"a negative budget has no cap", 3, -1, 8},
+		{"a zero budget turns it off", 2, 0, 0},
+		{"at least one worker runs", 0, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls, running, peak atomic.Int32
+			newEnrichment(tt.workers, tt.budget, time.Second).run(context.Background(), items, func(ctx context.Context, index int) {
+				calls.Add(1)
+				now := running.Add(1)
+				for {
+					seen := peak.Load()
+					if now <= seen || peak.CompareAndSwap(seen, now) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+			})
+			if int(calls.Load()) != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls.Load(), tt.wantCalls)
+			}
+			if workers := max(tt.workers, 1); int(peak.Load()) > workers {
+				t.Errorf("%d lookups ran at once, want at most %d", peak.Load(), workers)
+			}
+		})
+	}
+}
+
+func TestEnrichmentTimeout(t *testing.T) {
+	var calls atomic.Int32
+	start := time.Now()
+	newEnrichment(1, -1, 20*time.Millisecond).run(context.Background(), []int{0, 1, 2, 3}, func(ctx context.Context, index int) {
+		calls.Add(1)
+		<-ctx.Done()
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("run() took %s, want it to give up after the timeout", elapsed)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want the queued items skipped", calls.Load())
+	}
+}
@@ -2,12 +2,9 @@ package providers
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"regexp"
 	"strconv"
-	"strings"
 
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/xochilpili/subtitler-api/internal/models"
@@ -15,7 +12,8 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 )
 
-func searchSubX(provider *ProviderParams, query string) []models.Subtitle {
+func searchSubX(provider *ProviderParams, req *models.SearchRequest) ([]models.Subtitle, error) {
+	query := req.Term
 	tracer := otel.Tracer("subx")
 	ctx, span := tracer.Start(provider.ctx, "SubdX.Search")
 	defer span.End()
@@ -40,23 +38,24 @@ func searchSubX(provider *ProviderParams, query string) []models.Subtitle {
 
 	if err != nil {
 		provider.logger.Err(err).Msgf("error while getting subtitles")
-		return nil
+		return nil, err
 	}
 
 	if res.StatusCode() != 200 {
-		provider.logger.Err(errors.New("opensubtitles non ok response")).Msgf("status response %d", res.StatusCode())
-		return nil
+		err := fmt.Errorf("subx non ok response, status %d", res.StatusCode())
+		provider.logger.Err(err).Msgf("status response %d", res.StatusCode())
+		return nil, err
 	}
 
 	err = json.Unmarshal(res.Body(), &result)
 	if err != nil {
 		provider.logger.Err(err).Msgf("error while unmarshal subx json response: %v", err)
-		return nil
+		return nil, err
 	}
 
 	subtitles := translate2ModelSubx(result.Items)
 	provider.logger.Info().Msgf("returned %d subtitles", len(subtitles))
-	return subtitles
+	return subtitles, nil
 }
 
 func translate2ModelSubx(items []SubXResponseItem) []models.Subtitle {
@@ -94,6 +93,9 @@ func translate2ModelSubx(items []SubXResponseItem) []models.Subtitle {
 			Year:        year,
 			Season:      season,
 			Episode:     episode,
+			Downloads:   item.Downloads,
+			Uploader:    item.UploaderName,
+			UploadedAt:  models.ParseTimestamp(item.PostedAt),
 		}
 
 		subtitle.Group = group
@@ -105,7 +107,7 @@ func translate2ModelSubx(items []SubXResponseItem) []models.Subtitle {
 	return subtitles
 }
 
-func downloadSubX(provider *ProviderParams, subtitleId string) (io.ReadCloser, string, string, error) {
+func downloadSubX(provider *ProviderParams, subtitleId string) (*models.SubtitleFile, error) {
 	tracer := otel.Tracer(provider.config.url + provider.config.searchUrl)
 	ctx, rootSpan := tracer.Start(provider.ctx, "Download Subtitle Flow")
 	defer rootSpan.End()
@@ -127,18 +129,18 @@ func downloadSubX(provider *ProviderParams, subtitleId string) (io.ReadCloser, s
 	if err != nil {
 		spanDownloaded.RecordError(err)
 		spanDownloaded.SetStatus(404, "file download error")
-		return nil, "", "", err
+		return nil, err
 	}
-
-	contentType := res.Header().Get("Content-Type")
-	ext := strings.Split(contentType, "/")[1]
-	if ext == "text" {
-		ext = "srt"
+	file, err := httpDownload("subx", res, subtitleId)
+	if err != nil {
+		spanDownloaded.RecordError(err)
+		spanDownloaded.End()
+		return nil, err
 	}
-	filename := fmt.Sprintf("%s.%s", subtitleId, ext)
-	provider.logger.Info().Msgf("downloading file: %s", filename)
-	rootSpan.AddEvent(fmt.Sprintf("downloaded file: %s, format: %s", filename, ext))
+
+	provider.logger.Info().Msgf("downloading file: %s", file.Filename)
+	rootSpan.AddEvent(fmt.Sprintf("downloaded file: %s, format: %s", file.Filename, file.ContentType))
 	spanDownloaded.End()
 
-	return res.RawBody(), filename, contentType, nil
+	return file, nil
 }
@@ -0,0 +1,101 @@
+package providers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+// throttle spaces out requests to a provider and enforces a daily download
+// allowance, for sites that ban clients exceeding either.
+type throttle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	limit    int
+	count    int
+	day      string
+}
+
+func newThrottle(interval time.Duration, dailyLimit int) *throttle {
+	return &throttle{interval: interval, limit: dailyLimit}
+}
+
+// wait blocks until the next request slot is available.
+func (t *throttle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	slot := t.next
+	if slot.Before(now) {
+		slot = now
+	}
+	t.next = slot.Add(t.interval)
+	t.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// takeDownload consumes one download from today's allowance.
+func (t *throttle) takeDownload() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	today := time.Now().UTC().Format(time.DateOnly)
+	if t.day != today {
+		t.day = today
+		t.count = 0
+	}
+	if t.limit > 0 && t.count >= t.limit {
+		return ErrQuotaExceeded
+	}
+	t.count++
+	return nil
+}
+
+// returnDownload gives back a download taken for a request that failed,
+// unless the allowance has been reset since.
+func (t *throttle) returnDownload() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.day == time.Now().UTC().Format(time.DateOnly) && t.count > 0 {
+		t.count--
+	}
+}
+
+func (t *throttle) quota() *models.ProviderQuota {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.limit <= 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	used := t.count
+	if t.day != now.Format(time.DateOnly) {
+		used = 0
+	}
+	resetAt := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	return &models.ProviderQuota{Remaining: t.limit - used, ResetAt: &resetAt}
+}
+
+func (t *throttle) rateLimit() *models.ProviderRateLimit {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	limit := &models.ProviderRateLimit{IntervalMs: t.interval.Milliseconds()}
+	if t.next.After(time.Now()) {
+		next := t.next.UTC()
+		limit.NextSlot = &next
+	}
+	return limit
+}
@@ -0,0 +1,316 @@
+// Package subfile unpacks subtitle archives and converts subtitle files
+// between the SubRip and WebVTT formats.
+package subfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nwaples/rardecode/v2"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	FormatSrt = "srt"
+	FormatVtt = "vtt"
+	FormatAss = "ass"
+	FormatSsa = "ssa"
+	FormatSub = "sub"
+)
+
+const (
+	// maxFileSize bounds every file read out of an archive.
+	maxFileSize = 10 << 20
+	// maxArchiveSize bounds all the files read out of one archive together.
+	maxArchiveSize = 50 << 20
+	// maxArchiveEntries bounds the entries of an archive, subtitles or not.
+	maxArchiveEntries = 1000
+)
+
+var (
+	ErrNoSubtitles       = errors.New("archive holds no subtitle files")
+	ErrUnsupportedFormat = errors.New("unsupported subtitle conversion")
+	ErrInvalidSubtitle   = errors.New("file is not a valid subtitle")
+	ErrArchiveTooLarge   = errors.New("archive is too large")
+
+	zipMagic = []byte("PK\x03\x04")
+	rarMagic = []byte("Rar!\x1a\x07")
+)
+
+// ContentTypes maps the formats Sniff reports to their media types.
+var ContentTypes = map[string]string{
+	FormatSrt: "application/x-subrip",
+	FormatVtt: "text/vtt",
+	FormatAss: "text/x-ssa",
+	FormatSsa: "text/x-ssa",
+	FormatSub: "text/plain",
+	"zip":     "application/zip",
+	"rar":     "application/vnd.rar",
+	"7z":      "application/x-7z-compressed",
+	"gz":      "application/gzip",
+}
+
+// Extensions are the subtitle files kept when extracting an archive.
+var Extensions = map[string]bool{".srt": true, ".ass": true, ".ssa": true, ".vtt": true, ".sub": true}
+
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Extract returns the subtitle files held by a zip or rar archive, or file
+// itself when it is not an archive.
+func Extract(file File) ([]File, error) {
+	switch {
+	case bytes.HasPrefix(file.Data, zipMagic):
+		return extractZip(file.Data)
+	case bytes.HasPrefix(file.Data, rarMagic):
+		return extractRar(file.Data)
+	}
+	return []File{file}, nil
+}
+
+func extractZip(data []byte) ([]File, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	if len(archive.File) > maxArchiveEntries {
+		return nil, fmt.Errorf("%w: more than %d entries", ErrArchiveTooLarge, maxArchiveEntries)
+	}
+	var files []File
+	var total int
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !Extensions[strings.ToLower(path.Ext(entry.Name))] {
+			continue
+		}
+		r, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := readLimited(r, total)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		total += len(content)
+		files = append(files, File{Name: path.Base(entry.Name), Data: content})
+	}
+	if len(files) == 0 {
+		return nil, ErrNoSubtitles
+	}
+	return files, nil
+}
+
+func extractRar(data []byte) ([]File, error) {
+	archive, err := rardecode.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	var total int
+	for entries := 0; ; entries++ {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entries == maxArchiveEntries {
+			return nil, fmt.Errorf("%w: more than %d entries", ErrArchiveTooLarge, maxArchiveEntries)
+		}
+		if header.IsDir || !Extensions[strings.ToLower(path.Ext(header.Name))] {
+			continue
+		}
+		content, err := readLimited(archive, total)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", header.Name, err)
+		}
+		total += len(content)
+		files = append(files, File{Name: path.Base(header.Name), Data: content})
+	}
+	if len(files) == 0 {
+		return nil, ErrNoSubtitles
+	}
+	return files, nil
+}
+
+// readLimited reads a file out of an archive that already gave total bytes.
+func readLimited(r io.Reader, total int) ([]byte, error) {
+	limit := min(maxFileSize, maxArchiveSize-total)
+	content, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > limit {
+		if limit < maxFileSize {
+			return nil, fmt.Errorf("%w: more than %d bytes extracted", ErrArchiveTooLarge, maxArchiveSize)
+		}
+		return nil, errors.New("file is too large")
+	}
+	return content, nil
+}
+
+// Sniff tells the extension of a file from its first bytes: an archive
+// type or a subtitle format. It returns an empty string when unsure.
+func Sniff(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return "zip"
+	case bytes.HasPrefix(head, rarMagic):
+		return "rar"
+	case bytes.HasPrefix(head, []byte("7z\xbc\xaf\x27\x1c")):
+		return "7z"
+	case bytes.HasPrefix(head, []byte("\x1f\x8b")):
+		return "gz"
+	case bytes.IndexByte(head, 0) >= 0:
+		return ""
+	}
+	return Detect(head)
+}
+
+// Detect tells the subtitle format of data from its content, or returns
+// an empty string when it is not a subtitle this package knows.
+func Detect(data []byte) string {
+	text := normalize(data)
+	head := strings.TrimSpace(text)
+	switch {
+	case strings.HasPrefix(head, "WEBVTT"):
+		return FormatVtt
+	case strings.HasPrefix(head, "[Script Info]"):
+		if strings.Contains(head, "[V4+ Styles]") || strings.Contains(head, "ScriptType: v4.00+") {
+			return FormatAss
+		}
+		return FormatSsa
+	case srtTimingRe.MatchString(head) && strings.Contains(head, "-->"):
+		return FormatSrt
+	case microDvdRe.MatchString(head):
+		return FormatSub
+	}
+	return ""
+}
+
+// Validate checks that file holds subtitles in the format its extension
+// names, so a broken upload or an HTML error page is not handed out.
+func Validate(file File) error {
+	if bytes.IndexByte(file.Data, 0) >= 0 {
+		return fmt.Errorf("%w: %s is binary", ErrInvalidSubtitle, file.Name)
+	}
+	format := strings.TrimPrefix(strings.ToLower(path.Ext(file.Name)), ".")
+	detected := Detect(file.Data)
+	if detected == "" {
+		return fmt.Errorf("%w: %s holds no cues", ErrInvalidSubtitle, file.Name)
+	}
+	if detected != format && !(format == FormatAss && detected == FormatSsa) && !(format == FormatSsa && detected == FormatAss) {
+		return fmt.Errorf("%w: %s holds %s cues", ErrInvalidSubtitle, file.Name, detected)
+	}
+	if (detected == FormatAss || detected == FormatSsa) && !bytes.Contains(file.Data, []byte("Dialogue:")) {
+		return fmt.Errorf("%w: %s holds no cues", ErrInvalidSubtitle, file.Name)
+	}
+	return nil
+}
+
+var microDvdRe = regexp.MustCompile(`(?m)^\{\d+\}\{\d*\}`)
+
+var (
+	srtTimingRe = regexp.MustCompile(`(\d{2}:\d{2}:\d{2}),(\d{3})`)
+	vttTimingRe = regexp.MustCompile(`^((?:\d+:)?\d{2}:\d{2})\.(\d{3})\s+-->\s+((?:\d+:)?\d{2}:\d{2})\.(\d{3})`)
+)
+
+// Convert rewrites an srt or vtt file into format, renaming it to match.
+// Files already in format are only re-encoded as UTF-8.
+func Convert(file File, format string) (File, error) {
+	from := strings.TrimPrefix(strings.ToLower(path.Ext(file.Name)), ".")
+	base := strings.TrimSuffix(file.Name, path.Ext(file.Name))
+	text := normalize(file.Data)
+	switch {
+	case from == format:
+		return File{Name: file.Name, Data: []byte(text)}, nil
+	case from == FormatSrt && format == FormatVtt:
+		return File{Name: base + ".vtt", Data: []byte(srtToVtt(text))}, nil
+	case from == FormatVtt && format == FormatSrt:
+		return File{Name: base + ".srt", Data: []byte(vttToSrt(text))}, nil
+	}
+	return file, fmt.Errorf("%w: %s to %s", ErrUnsupportedFormat, from, format)
+}
+
+// normalize decodes the file as UTF-8, falling back to Windows-1252 which
+// most non UTF-8 subtitles use, and unifies line endings.
+func normalize(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		if decoded, err := charmap.Windows1252.NewDecoder().Bytes(data); err == nil {
+			data = decoded
+		}
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
+
+func srtToVtt(text string) string {
+	var out strings.Builder
+	out.WriteString("WEBVTT\n\n")
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if strings.Contains(line, "-->") {
+			line = srtTimingRe.ReplaceAllString(line, "$1.$2")
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// vttToSrt keeps the cues of a WebVTT file, dropping its header, notes,
+// styles and cue settings, and numbers them as SubRip expects.
+func vttToSrt(text string) string {
+	var out strings.Builder
+	cue := 0
+	for _, block := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		timing := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 {
+			continue
+		}
+		match := vttTimingRe.FindStringSubmatch(strings.TrimSpace(lines[timing]))
+		if match == nil {
+			continue
+		}
+		cue++
+		out.WriteString(strconv.Itoa(cue))
+		out.WriteString("\n")
+		out.WriteString(fmt.Sprintf("%s,%s --> %s,%s\n", withHours(match[1]), match[2], withHours(match[3]), match[4]))
+		for _, line := range lines[timing+1:] {
+			out.WriteString(line)
+			out.WriteString("\n")
+		}
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// withHours pads a WebVTT timestamp, whose hours are optional, to the
+// hh:mm:ss SubRip form.
+func withHours(timestamp string) string {
+	if strings.Count(timestamp, ":") == 1 {
+		return "00:" + timestamp
+	}
+	if len(timestamp) < len("00:00:00") {
+		return "0" + timestamp
+	}
+	return timestamp
+}
@@ -0,0 +1,227 @@
+package subfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+const srtSample = "1\n00:00:01,000 --> 00:00:02,500\nHello\n\n2\n00:01:03,200 --> 00:01:04,000\n<i>World</i>\n"
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     File
+		format   string
+		wantName string
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "srt to vtt",
+			file:     File{Name: "movie.srt", Data: []byte(srtSample)},
+			format:   FormatVtt,
+			wantName: "movie.vtt",
+			want:     "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\nHello\n\n2\n00:01:03.200 --> 00:01:04.000\n<i>World</i>\n",
+		},
+		{
+			name:     "vtt to srt drops header, notes and cue settings",
+			file:     File{Name: "movie.vtt", Data: []byte("WEBVTT\nKind: captions\n\nNOTE made by hand\n\nintro\n01:02.000 --> 01:03.500 align:start\nHello\n\n1:00:00.000 --> 1:00:01.000\nBye\n")},
+			format:   FormatSrt,
+			wantName: "movie.srt",
+			want:     "1\n00:01:02,000 --> 00:01:03,500\nHello\n\n2\n01:00:00,000 --> 01:00:01,000\nBye\n\n",
+		},
+		{
+			name:     "same format is re-encoded as UTF-8 with unix line endings",
+			file:     File{Name: "movie.srt", Data: []byte("1\r\n00:00:01,000 --> 00:00:02,000\r\nCanci\xf3n\r\n")},
+			format:   FormatSrt,
+			wantName: "movie.srt",
+			want:     "1\n00:00:01,000 --> 00:00:02,000\nCanción\n",
+		},
+		{
+			name:     "byte order mark is dropped",
+			file:     File{Name: "movie.srt", Data: []byte("\xef\xbb\xbf" + srtSample)},
+			format:   FormatSrt,
+			wantName: "movie.srt",
+			want:     srtSample,
+		},
+		{
+			name:     "ass cannot be converted",
+			file:     File{Name: "movie.ass", Data: []byte("[Script Info]\n")},
+			format:   FormatSrt,
+			wantName: "movie.ass",
+			wantErr:  ErrUnsupportedFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.file, tt.format)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Convert() error = %v, want %v", err, tt.wantErr)
+			}
+			if got.Name != tt.wantName {
+				t.Errorf("Convert() name = %q, want %q", got.Name, tt.wantName)
+			}
+			if tt.wantErr == nil && string(got.Data) != tt.want {
+				t.Errorf("Convert() data = %q, want %q", got.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	vtt, err := Convert(File{Name: "a.srt", Data: []byte(srtSample)}, FormatVtt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srt, err := Convert(vtt, FormatSrt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(srt.Data) != srtSample+"\n" {
+		t.Errorf("round trip = %q, want %q", srt.Data, srtSample+"\n")
+	}
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"zip", "PK\x03\x04\x14\x00", "zip"},
+		{"rar", "Rar!\x1a\x07\x01\x00", "rar"},
+		{"7z", "7z\xbc\xaf\x27\x1c\x00\x04", "7z"},
+		{"gzip", "\x1f\x8b\x08\x00", "gz"},
+		{"binary", "\x00\x01\x02-->", ""},
+		{"srt", srtSample, FormatSrt},
+		{"vtt", "WEBVTT\n\n00:01.000 --> 00:02.000\nHi\n", FormatVtt},
+		{"ass", "[Script Info]\nScriptType: v4.00+\n", FormatAss},
+		{"ssa", "[Script Info]\nScriptType: v4.00\n", FormatSsa},
+		{"microdvd", "{100}{200}Hello\n", FormatSub},
+		{"html", "<!DOCTYPE html><html><body>Not found</body></html>", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sniff([]byte(tt.head)); got != tt.want {
+				t.Errorf("Sniff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    File
+		wantErr bool
+	}{
+		{"srt", File{"a.srt", []byte(srtSample)}, false},
+		{"vtt", File{"a.vtt", []byte("WEBVTT\n\n00:01.000 --> 00:02.000\nHi\n")}, false},
+		{"ass with dialogue", File{"a.ass", []byte("[Script Info]\n[V4+ Styles]\n[Events]\nDialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,Hi\n")}, false},
+		{"ssa named ass", File{"a.ass", []byte("[Script Info]\n[Events]\nDialogue: Marked=0,0:00:01.00,0:00:02.00,Default,,0,0,0,,Hi\n")}, false},
+		{"ass without dialogue", File{"a.ass", []byte("[Script Info]\n[V4+ Styles]\n")}, true},
+		{"vtt named srt", File{"a.srt", []byte("WEBVTT\n\n00:01.000 --> 00:02.000\nHi\n")}, true},
+		{"html error page", File{"a.srt", []byte("<html><body>Too many requests</body></html>")}, true},
+		{"binary", File{"a.srt", append([]byte(srtSample), 0)}, true},
+		{"empty", File{"a.srt", nil}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSubtitle) {
+				t.Errorf("Validate() error = %v, want ErrInvalidSubtitle", err)
+			}
+		})
+	}
+}
+
+// zipOf builds a zip archive holding files, in order.
+func zipOf(t *testing.T, files ...File) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := archive.Create(f.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(f.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	tooMany := make([]File, maxArchiveEntries+1)
+	for i := range tooMany {
+		tooMany[i] = File{Name: fmt.Sprintf("%d.nfo", i)}
+	}
+	// every file fits on its own, together they are over maxArchiveSize
+	tooLarge := make([]File, maxArchiveSize/maxFileSize+1)
+	for i := range tooLarge {
+		tooLarge[i] = File{Name: fmt.Sprintf("%d.srt", i), Data: make([]byte, maxFileSize)}
+	}
+
+	tests := []struct {
+		name      string
+		file      File
+		wantNames []string
+		wantErr   error
+	}{
+		{
+			name: "zip keeps subtitles only",
+			file: File{Name: "a.zip", Data: zipOf(t,
+				File{Name: "Movie/movie.en.srt", Data: []byte(srtSample)},
+				File{Name: "Movie/readme.nfo", Data: []byte("release notes")},
+				File{Name: "Movie/movie.es.ASS", Data: []byte("[Script Info]\n")},
+			)},
+			wantNames: []string{"movie.en.srt", "movie.es.ASS"},
+		},
+		{
+			name:      "plain file is returned as is",
+			file:      File{Name: "movie.srt", Data: []byte(srtSample)},
+			wantNames: []string{"movie.srt"},
+		},
+		{
+			name:    "zip without subtitles",
+			file:    File{Name: "a.zip", Data: zipOf(t, File{Name: "readme.txt", Data: []byte("hi")})},
+			wantErr: ErrNoSubtitles,
+		},
+		{
+			name:    "too many entries",
+			file:    File{Name: "a.zip", Data: zipOf(t, tooMany...)},
+			wantErr: ErrArchiveTooLarge,
+		},
+		{
+			name:    "too many bytes",
+			file:    File{Name: "a.zip", Data: zipOf(t, tooLarge...)},
+			wantErr: ErrArchiveTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := Extract(tt.file)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Extract() error = %v, want %v", err, tt.wantErr)
+			}
+			var names []string
+			for _, f := range files {
+				names = append(names, f.Name)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.wantNames) {
+				t.Errorf("Extract() files = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package webserver
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xochilpili/subtitler-api/internal/config"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+)
+
+const ApiKeyHeader = "X-API-Key"
+
+// apiKeyFile is the layout of SA_API_KEYS_FILE. Limits left out fall back
+// to the SA_API_KEY_* defaults; keys expand ${ENV} variables.
+type apiKeyFile struct {
+	Keys []struct {
+		Client         string   `yaml:"client"`
+		Key            string   `yaml:"key"`
+		Rate           *float64 `yaml:"rate"`
+		Burst          *int     `yaml:"burst"`
+		DailyDownloads *int     `yaml:"daily_downloads"`
+	} `yaml:"keys"`
+}
+
+// apiClient is a caller identified by its API key or token, with its own
+// request bucket and daily download allowance.
+type apiClient struct {
+	id             string
+	limiter        *rate.Limiter
+	dailyDownloads int
+
+	mu        sync.Mutex
+	day       string
+	downloads int
+}
+
+func newApiClient(id string, perSecond float64, burst int, dailyDownloads int) *apiClient {
+	limit := rate.Inf
+	if perSecond > 0 {
+		limit = rate.Limit(perSecond)
+	}
+	return &apiClient{id: id, limiter: rate.NewLimiter(limit, max(burst, 1)), dailyDownloads: dailyDownloads}
+}
+
+type apiKeys struct {
+	// clients are indexed by the SHA-256 of their key so lookups do not
+	// compare the secret itself.
+	clients map[[sha256.Size]byte]*apiClient
+}
+
+// newApiKeys loads the keys from SA_API_KEYS (client:key pairs) and
+// SA_API_KEYS_FILE. No keys at all leaves the API open.
+func newApiKeys(config *config.Config) (*apiKeys, error) {
+	keys := &apiKeys{clients: map[[sha256.Size]byte]*apiClient{}}
+	add := func(id string, key string, perSecond float64, burst int, dailyDownloads int) error {
+		if id == "" || key == "" {
+			return errors.New("api keys need a client and a key")
+		}
+		hash := sha256.Sum256([]byte(key))
+		if _, exists := keys.clients[hash]; exists {
+			return fmt.Errorf("api key of client %s is already in use", id)
+		}
+		keys.clients[hash] = newApiClient(id, perSecond, burst, dailyDownloads)
+		return nil
+	}
+
+	for id, key := range config.ApiKeys {
+		if err := add(strings.TrimSpace(id), strings.TrimSpace(key), config.ApiKeyRate, config.ApiKeyBurst, config.ApiKeyDailyDownloads); err != nil {
+			return nil, err
+		}
+	}
+	if config.ApiKeysFile != "" {
+		raw, err := os.ReadFile(config.ApiKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		var file apiKeyFile
+		if err := yaml.Unmarshal(raw, &file); err != nil {
+			return nil, fmt.Errorf("%s: %w", config.ApiKeysFile, err)
+		}
+		for _, k := range file.Keys {
+			perSecond, burst, dailyDownloads := config.ApiKeyRate, config.ApiKeyBurst, config.ApiKeyDailyDownloads
+			if k.Rate != nil {
+				perSecond = *k.Rate
+			}
+			if k.Burst != nil {
+				burst = *k.Burst
+			}
+			if k.DailyDownloads != nil {
+				dailyDownloads = *k.DailyDownloads
+			}
+			if err := add(k.Client, os.ExpandEnv(k.Key), perSecond, burst, dailyDownloads); err != nil {
+				return nil, fmt.Errorf("%s: %w", config.ApiKeysFile, err)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (k *apiKeys) enabled() bool {
+	return len(k.clients) > 0
+}
+
+func (k *apiKeys) lookup(key string) *apiClient {
+	return k.clients[sha256.Sum256([]byte(key))]
+}
+
+// allow takes n tokens from the request bucket, or tells how long until
+// they are available without taking any.
+func (a *apiClient) allow(n int) (time.Duration, error) {
+	reservation := a.limiter.ReserveN(time.Now(), n)
+	if !reservation.OK() {
+		return 0, fmt.Errorf("%w of %d", errBurstExceeded, a.limiter.Burst())
+	}
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return delay, errClientRateLimited
+	}
+	return 0, nil
+}
+
+// takeDownload reserves one download for today and reports how many are
+// left, -1 meaning unlimited.
+func (a *apiClient) takeDownload() (int, bool) {
+	if a.dailyDownloads <= 0 {
+		return -1, true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if today := time.Now().UTC().Format(time.DateOnly); a.day != today {
+		a.day = today
+		a.downloads = 0
+	}
+	if a.downloads >= a.dailyDownloads {
+		return 0, false
+	}
+	a.downloads++
+	return a.dailyDownloads - a.downloads, true
+}
+
+func (a *apiClient) returnDownload() {
+	if a.dailyDownloads <= 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.downloads > 0 {
+		a.downloads--
+	}
+}
+
+func nextUtcDay() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
@@ -0,0 +1,182 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"github.com/xochilpili/subtitler-api/internal/providers"
+	"golang.org/x/time/rate"
+)
+
+func TestNewApiKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{
+			name: "keys and limits",
+			file: `keys:
+  - client: web
+    key: ${TEST_WEB_KEY}
+    rate: 2
+    burst: 4
+    daily_downloads: 0
+  - client: mobile
+    key: m0bile
+`,
+		},
+		{"a key in use", "keys:\n  - client: copy\n    key: s3cret\n", "already in use"},
+		{"a key without a client", "keys:\n  - key: other\n", "need a client"},
+		{"a key expanding to nothing", "keys:\n  - client: unset\n    key: ${TEST_UNSET_KEY}\n", "need a client"},
+		{"invalid yaml", "keys: [", "keys.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "keys.yaml")
+			if err := os.WriteFile(file, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("TEST_WEB_KEY", "w3b")
+			keys, err := newApiKeys(testConfig(t, map[string]string{
+				"SA_API_KEYS":                "tests:s3cret",
+				"SA_API_KEYS_FILE":           file,
+				"SA_API_KEY_RATE":            "5",
+				"SA_API_KEY_BURST":           "10",
+				"SA_API_KEY_DAILY_DOWNLOADS": "100",
+			}))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("newApiKeys() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			clients := []struct {
+				key            string
+				id             string
+				limit          rate.Limit
+				burst          int
+				dailyDownloads int
+			}{
+				{"s3cret", "tests", 5, 10, 100},
+				{"w3b", "web", 2, 4, 0},
+				// limits left out fall back to the defaults
+				{"m0bile", "mobile", 5, 10, 100},
+			}
+			for _, want := range clients {
+				client := keys.lookup(want.key)
+				if client == nil {
+					t.Errorf("key of %s not found", want.id)
+					continue
+				}
+				if client.id != want.id || client.limiter.Limit() != want.limit || client.limiter.Burst() != want.burst || client.dailyDownloads != want.dailyDownloads {
+					t.Errorf("client = %s %v/%d %d, want %s %v/%d %d", client.id, client.limiter.Limit(), client.limiter.Burst(), client.dailyDownloads, want.id, want.limit, want.burst, want.dailyDownloads)
+				}
+			}
+			if keys.lookup("${TEST_WEB_KEY}") != nil || keys.lookup("") != nil {
+				t.Error("lookup() found a key that is not configured")
+			}
+		})
+	}
+}
+
+func TestApiKeyAuth(t *testing.T) {
+	srv := newTestServer(t, &fakeManager{}, map[string]string{"SA_API_KEYS": "tests:s3cret"})
+
+	tests := []struct {
+		name string
+		path string
+		auth []string
+		want int
+	}{
+		{"no key", "/v2/providers", nil, http.StatusUnauthorized},
+		{"wrong key", "/v2/providers", []string{ApiKeyHeader, "guess"}, http.StatusUnauthorized},
+		{"valid key", "/v2/providers", []string{ApiKeyHeader, "s3cret"}, http.StatusOK},
+		{"legacy routes", "/search/all/?term=dune", nil, http.StatusUnauthorized},
+		{"ping is public", "/ping", nil, http.StatusOK},
+		{"liveness is public", "/healthz", nil, http.StatusOK},
+		{"readiness is public", "/readyz", nil, http.StatusOK},
+		{"the spec is public", "/openapi.json", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := srv.serve(httptest.NewRequest(http.MethodGet, tt.path, nil), tt.auth...)
+			if res.Code != tt.want {
+				t.Fatalf("GET %s = %d %s, want %d", tt.path, res.Code, res.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestApiKeyRateLimit(t *testing.T) {
+	srv := newTestServer(t, &fakeManager{}, map[string]string{
+		"SA_API_KEYS":      "tests:s3cret,other:0ther",
+		"SA_API_KEY_RATE":  "0.1",
+		"SA_API_KEY_BURST": "2",
+	})
+	get := func(key string) *httptest.ResponseRecorder {
+		return srv.serve(httptest.NewRequest(http.MethodGet, "/v2/providers", nil), ApiKeyHeader, key)
+	}
+
+	for i := range 2 {
+		if res := get("s3cret"); res.Code != http.StatusOK {
+			t.Fatalf("request %d = %d, want 200 within the burst", i+1, res.Code)
+		}
+	}
+	res := get("s3cret")
+	if res.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429 past the burst", res.Code)
+	}
+	if res.Header().Get("Retry-After") != "10" {
+		t.Errorf("Retry-After = %q, want 10", res.Header().Get("Retry-After"))
+	}
+	// every key has its own bucket
+	if res := get("0ther"); res.Code != http.StatusOK {
+		t.Errorf("another key = %d, want 200", res.Code)
+	}
+}
+
+func TestDailyDownloads(t *testing.T) {
+	failing := false
+	manager := &fakeManager{download: func(provider, id string) (*models.SubtitleFile, error) {
+		if failing {
+			return nil, providers.ErrSubtitleNotFound
+		}
+		return textFile(id+".srt", testSrt), nil
+	}}
+	srv := newTestServer(t, manager, map[string]string{"SA_API_KEYS": "tests:s3cret", "SA_API_KEY_DAILY_DOWNLOADS": "2"})
+	download := func() *httptest.ResponseRecorder {
+		return srv.serve(httptest.NewRequest(http.MethodGet, "/v2/download/podnapisi/a", nil), ApiKeyHeader, "s3cret")
+	}
+
+	if res := download(); res.Code != http.StatusOK || res.Header().Get("X-Quota-Remaining") != "1" {
+		t.Fatalf("status = %d, X-Quota-Remaining %q, want 200 with 1 left", res.Code, res.Header().Get("X-Quota-Remaining"))
+	}
+	// failed downloads are given back
+	failing = true
+	if res := download(); res.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", res.Code)
+	}
+	failing = false
+	if res := download(); res.Code != http.StatusOK || res.Header().Get("X-Quota-Remaining") != "0" {
+		t.Fatalf("status = %d, X-Quota-Remaining %q, want 200 with 0 left", res.Code, res.Header().Get("X-Quota-Remaining"))
+	}
+
+	res := download()
+	if res.Code != http.StatusTooManyRequests || res.Header().Get("Retry-After") == "" {
+		t.Fatalf("status = %d, Retry-After %q, want 429 until tomorrow", res.Code, res.Header().Get("Retry-After"))
+	}
+	// searches are not downloads
+	if res := srv.serve(httptest.NewRequest(http.MethodGet, "/v2/search/all/?term=dune", nil), ApiKeyHeader, "s3cret"); res.Code != http.StatusOK {
+		t.Errorf("search = %d, want 200 with the download quota spent", res.Code)
+	}
+}
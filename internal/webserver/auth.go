@@ -0,0 +1,286 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	clientIdKey  = "client_id"
+	apiClientKey = "api_client"
+	scopesKey    = "scopes"
+
+	ScopeSearch   = "search"
+	ScopeDownload = "download"
+)
+
+var (
+	errMissingCredentials   = errors.New("missing api key or bearer token")
+	errInvalidApiKey        = errors.New("invalid api key")
+	errInvalidToken         = errors.New("invalid bearer token")
+	errInsufficientScope    = errors.New("token lacks the required scope")
+	errClientRateLimited    = errors.New("client rate limit reached")
+	errBurstExceeded        = fmt.Errorf("%w: request needs more than the client burst", errClientRateLimited)
+	errDownloadQuotaReached = errors.New("daily download quota reached")
+)
+
+// Auth checks callers against the configured API keys and JWKS. The HTTP
+// routes and the gRPC service share one, so a client has the same request
+// rate and download quota on both.
+type Auth struct {
+	keys       *apiKeys
+	jwt        *jwtAuth
+	rejections otelmetric.Int64Counter
+}
+
+// Caller is an authenticated client.
+type Caller struct {
+	client *apiClient
+	// scopes is nil for API keys, which carry every scope
+	scopes []string
+}
+
+// Enabled tells whether any keys or a JWKS are configured; otherwise the
+// API is open.
+func (a *Auth) Enabled() bool {
+	return a.keys.enabled() || a.jwt != nil
+}
+
+// Authenticate identifies the caller from a bearer token, when a JWKS is
+// configured, or else from an API key.
+func (a *Auth) Authenticate(ctx context.Context, apiKey string, bearer string) (*Caller, error) {
+	if bearer != "" && a.jwt != nil {
+		client, scopes, err := a.jwt.verify(ctx, bearer)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidToken, err)
+		}
+		return &Caller{client: client, scopes: scopes}, nil
+	}
+	if apiKey != "" && a.keys.enabled() {
+		client := a.keys.lookup(apiKey)
+		if client == nil {
+			return nil, errInvalidApiKey
+		}
+		return &Caller{client: client}, nil
+	}
+	return nil, errMissingCredentials
+}
+
+// Reject counts a request refused with err in
+// http.server.client.rejections.
+func (a *Auth) Reject(ctx context.Context, clientId string, err error) {
+	a.rejections.Add(ctx, 1, otelmetric.WithAttributes(
+		attribute.String("reason", rejectReason(err)),
+		attribute.String("client.id", clientId),
+	))
+}
+
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, errInvalidToken):
+		return "invalid_token"
+	case errors.Is(err, errInvalidApiKey):
+		return "invalid_key"
+	case errors.Is(err, errInsufficientScope):
+		return "insufficient_scope"
+	case errors.Is(err, errClientRateLimited):
+		return "rate_limited"
+	case errors.Is(err, errDownloadQuotaReached):
+		return "quota_exceeded"
+	}
+	return "missing_credentials"
+}
+
+func (c *Caller) Id() string {
+	return c.client.id
+}
+
+// RequireScope fails unless the caller was granted scope.
+func (c *Caller) RequireScope(scope string) error {
+	if c.scopes != nil && !slices.Contains(c.scopes, scope) {
+		return fmt.Errorf("%w: %s", errInsufficientScope, scope)
+	}
+	return nil
+}
+
+// Allow takes a token from the caller's request bucket, or tells how long
+// until one is available without taking it.
+func (c *Caller) Allow() (time.Duration, error) {
+	return c.client.allow(1)
+}
+
+// TakeDownload counts a download against the caller's daily quota and
+// returns how many are left, -1 meaning unlimited.
+func (c *Caller) TakeDownload() (int, error) {
+	remaining, ok := c.client.takeDownload()
+	if !ok {
+		return 0, errDownloadQuotaReached
+	}
+	return remaining, nil
+}
+
+// ReturnDownload gives back a download that failed.
+func (c *Caller) ReturnDownload() {
+	c.client.returnDownload()
+}
+
+// Auth returns the authentication the HTTP routes use, to be shared with
+// the other servers.
+func (w *WebServer) Auth() *Auth {
+	return w.auth
+}
+
+// reject answers an authentication, rate limit or quota failure and stops
+// the handler chain.
+func (w *WebServer) reject(c *gin.Context, status int, err error) {
+	w.auth.Reject(c.Request.Context(), c.GetString(clientIdKey), err)
+	w.respondError(c, status, err)
+	c.Abort()
+}
+
+// authenticate identifies the caller from a bearer token or the X-API-Key
+// header, tags the request span with its client id and applies its request
+// rate limit. With neither keys nor a JWKS configured the API is open.
+func (w *WebServer) authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !w.auth.Enabled() {
+			c.Next()
+			return
+		}
+		token, _ := BearerToken(c.GetHeader("Authorization"))
+		caller, err := w.auth.Authenticate(c.Request.Context(), c.GetHeader(ApiKeyHeader), token)
+		if err != nil {
+			switch {
+			case errors.Is(err, errInvalidToken):
+				c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			case errors.Is(err, errMissingCredentials) && w.auth.jwt != nil:
+				c.Header("WWW-Authenticate", "Bearer")
+			}
+			w.reject(c, http.StatusUnauthorized, err)
+			return
+		}
+		c.Set(clientIdKey, caller.Id())
+		c.Set(apiClientKey, caller.client)
+		if caller.scopes != nil {
+			c.Set(scopesKey, caller.scopes)
+		}
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("client.id", caller.Id()))
+
+		if delay, err := caller.Allow(); err != nil {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			w.reject(c, http.StatusTooManyRequests, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// chargeRequests charges a request that does the work of n, such as a
+// batch, against the caller's request bucket; authenticate already took
+// one token for it. It answers and returns false when the rest are not
+// available, or never will be as n exceeds the burst.
+func (w *WebServer) chargeRequests(c *gin.Context, n int) bool {
+	value, ok := c.Get(apiClientKey)
+	if !ok || n <= 1 {
+		return true
+	}
+	client := value.(*apiClient)
+	if burst := client.limiter.Burst(); n > burst {
+		w.reject(c, http.StatusBadRequest, fmt.Errorf("%w of %d", errBurstExceeded, burst))
+		return false
+	}
+	delay, err := client.allow(n - 1)
+	switch {
+	case errors.Is(err, errBurstExceeded):
+		w.reject(c, http.StatusBadRequest, err)
+		return false
+	case err != nil:
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		w.reject(c, http.StatusTooManyRequests, err)
+		return false
+	}
+	return true
+}
+
+// requireScope guards a route group with a token scope. API keys, and the
+// open mode, carry every scope.
+func (w *WebServer) requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get(scopesKey)
+		if ok && !slices.Contains(value.([]string), scope) {
+			c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+			w.reject(c, http.StatusForbidden, fmt.Errorf("%w: %s", errInsufficientScope, scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+// downloadQuota counts downloads against the daily allowance of the
+// caller. Failed downloads are given back.
+func (w *WebServer) downloadQuota() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !w.takeDownload(c) {
+			w.rejectDownloadQuota(c)
+			return
+		}
+		c.Next()
+		// neither a failure nor a copy the caller already holds counts
+		if status := c.Writer.Status(); status >= http.StatusBadRequest || status == http.StatusNotModified {
+			w.returnDownload(c)
+		}
+	}
+}
+
+// takeDownload counts one download against the caller's daily allowance
+// and reports what is left in X-Quota-Remaining. It returns false once the
+// allowance is spent; callers without a quota always get one.
+func (w *WebServer) takeDownload(c *gin.Context) bool {
+	value, ok := c.Get(apiClientKey)
+	if !ok {
+		return true
+	}
+	remaining, ok := value.(*apiClient).takeDownload()
+	if !ok {
+		return false
+	}
+	if remaining >= 0 {
+		c.Header("X-Quota-Remaining", strconv.Itoa(remaining))
+	}
+	return true
+}
+
+// returnDownload gives back a download taken by takeDownload.
+func (w *WebServer) returnDownload(c *gin.Context) {
+	if value, ok := c.Get(apiClientKey); ok {
+		value.(*apiClient).returnDownload()
+	}
+}
+
+// rejectDownloadQuota answers 429 until the allowance resets at midnight
+// UTC.
+func (w *WebServer) rejectDownloadQuota(c *gin.Context) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(nextUtcDay()).Seconds()))))
+	w.reject(c, http.StatusTooManyRequests, errDownloadQuotaReached)
+}
+
+// BearerToken reads the token out of an Authorization header value.
+func BearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
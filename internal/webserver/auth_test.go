@@ -0,0 +1,86 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRouteScopes(t *testing.T) {
+	key := newTestKey(t, "main")
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwks, jwksOf(t, key), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestServer(t, &fakeManager{}, map[string]string{
+		"SA_API_KEYS":     "tests:s3cret",
+		"SA_JWT_JWKS":     jwks,
+		"SA_JWT_ISSUER":   testIssuer,
+		"SA_JWT_AUDIENCE": testAudience,
+	})
+	token := func(scope any) string {
+		extra := map[string]any{"azp": "app"}
+		if scope != nil {
+			extra["scope"] = scope
+		}
+		return "Bearer " + key.sign(t, validClaims(), extra)
+	}
+	searchOnly := token("search")
+	downloadOnly := token("download")
+	noScope := token(nil)
+
+	tests := []struct {
+		name string
+		path string
+		auth []string
+		want int
+	}{
+		{"search scope searches", "/v2/search/all/?term=dune", []string{"Authorization", searchOnly}, http.StatusOK},
+		{"search scope cannot download", "/v2/download/podnapisi/AbC1", []string{"Authorization", searchOnly}, http.StatusForbidden},
+		{"search scope cannot use best", "/v2/best?term=dune", []string{"Authorization", searchOnly}, http.StatusForbidden},
+		{"search scope cannot use legacy download", "/download/podnapisi/AbC1", []string{"Authorization", searchOnly}, http.StatusForbidden},
+		{"download scope downloads", "/v2/download/podnapisi/AbC1", []string{"Authorization", downloadOnly}, http.StatusOK},
+		{"download scope cannot search", "/v2/search/all/?term=dune", []string{"Authorization", downloadOnly}, http.StatusForbidden},
+		{"download scope cannot read details", "/v2/subtitle/podnapisi/AbC1/details", []string{"Authorization", downloadOnly}, http.StatusForbidden},
+		{"no scope cannot search", "/v2/search/all/?term=dune", []string{"Authorization", noScope}, http.StatusForbidden},
+		{"no scope cannot download", "/v1/download/podnapisi/AbC1", []string{"Authorization", noScope}, http.StatusForbidden},
+		{"no scope lists providers", "/v2/providers", []string{"Authorization", noScope}, http.StatusOK},
+		{"api keys carry every scope", "/v2/download/podnapisi/AbC1", []string{ApiKeyHeader, "s3cret"}, http.StatusOK},
+		{"no credentials", "/v2/providers", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := srv.serve(httptest.NewRequest(http.MethodGet, tt.path, nil), tt.auth...)
+			if rec.Code != tt.want {
+				t.Fatalf("GET %s = %d %s, want %d", tt.path, rec.Code, rec.Body, tt.want)
+			}
+		})
+	}
+
+	rec := srv.serve(httptest.NewRequest(http.MethodPost, "/graphql", nil), "Authorization", downloadOnly)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST /graphql with the download scope = %d, want 403", rec.Code)
+	}
+}
+
+func TestTokenWithoutScopeIsNotAnApiKey(t *testing.T) {
+	key := newTestKey(t, "")
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwks, jwksOf(t, key), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestServer(t, &fakeManager{}, map[string]string{
+		"SA_JWT_JWKS":     jwks,
+		"SA_JWT_ISSUER":   testIssuer,
+		"SA_JWT_AUDIENCE": testAudience,
+	})
+	caller, err := srv.Auth().Authenticate(t.Context(), "", key.sign(t, validClaims(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := caller.RequireScope(ScopeDownload); err == nil {
+		t.Fatal("a token without a scope claim was granted the download scope")
+	}
+}
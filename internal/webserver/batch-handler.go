@@ -0,0 +1,181 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// searchBatchResult is the outcome of one item of a batch. Items fail on
+// their own, so a bad search does not sink the rest of the batch.
+type searchBatchResult struct {
+	Index     int
+	Id        string
+	Provider  string
+	Subtitles []models.Subtitle
+	Skipped   []models.SkippedProvider
+	Status    int
+	Err       error
+}
+
+type searchBatchResultV1 struct {
+	Index    int                      `json:"index"`
+	Id       string                   `json:"id,omitempty"`
+	Provider string                   `json:"provider"`
+	Total    int                      `json:"total"`
+	Data     []models.Subtitle        `json:"data"`
+	Skipped  []models.SkippedProvider `json:"skipped,omitempty"`
+	Error    string                   `json:"error,omitempty"`
+}
+
+type searchBatchResultV2 struct {
+	Index    int                      `json:"index"`
+	Id       string                   `json:"id,omitempty"`
+	Provider string                   `json:"provider"`
+	Total    int                      `json:"total"`
+	Data     []SubtitleV2             `json:"data"`
+	Skipped  []models.SkippedProvider `json:"skipped,omitempty"`
+	Error    *errorV2                 `json:"error,omitempty"`
+}
+
+// SearchBatch runs a list of searches through the manager, at most
+// SA_SEARCH_BATCH_CONCURRENCY at a time. Results are returned in request
+// order, or streamed as NDJSON in completion order when the client accepts
+// application/x-ndjson.
+func (w *WebServer) SearchBatch(c *gin.Context) {
+	var request SearchBatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		w.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if len(request.Items) > w.config.SearchBatchMaxItems {
+		w.respondError(c, http.StatusBadRequest, fmt.Errorf("a batch holds at most %d searches", w.config.SearchBatchMaxItems))
+		return
+	}
+	// every search counts against the client rate limit
+	if !w.chargeRequests(c, len(request.Items)) {
+		return
+	}
+
+	ctx, span := otel.Tracer(w.config.ServiceName).Start(c.Request.Context(), "Search batch")
+	defer span.End()
+	span.SetAttributes(attribute.Int("batch_size", len(request.Items)))
+
+	known := map[string]bool{}
+	for _, provider := range w.manager.Providers() {
+		known[provider.Name] = true
+	}
+
+	results := make(chan searchBatchResult)
+	go func() {
+		sem := make(chan struct{}, max(w.config.SearchBatchConcurrency, 1))
+		wg := &sync.WaitGroup{}
+		for index, item := range request.Items {
+			provider := item.Provider
+			if provider == "all" {
+				provider = ""
+			}
+			result := searchBatchResult{Index: index, Id: item.Id, Provider: item.Provider}
+			if result.Provider == "" {
+				result.Provider = "all"
+			}
+			if err := binding.Validator.ValidateStruct(&item); err != nil {
+				result.Status, result.Err = http.StatusBadRequest, err
+				results <- result
+				continue
+			}
+			if provider != "" && !known[provider] {
+				result.Status, result.Err = http.StatusNotFound, fmt.Errorf("unknown provider: %s", provider)
+				results <- result
+				continue
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				result.Status, result.Err = http.StatusServiceUnavailable, ctx.Err()
+				results <- result
+				continue
+			}
+			wg.Add(1)
+			go func(item SearchBatchItem, result searchBatchResult) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				result.Subtitles, result.Skipped = w.manager.Search(item.Context(ctx), provider, item.Request(), item.PostFilters())
+				results <- result
+			}(item, result)
+		}
+		wg.Wait()
+		close(results)
+	}()
+
+	if strings.Contains(c.GetHeader("Accept"), ndjsonContentType) {
+		w.streamSearchBatch(c, results)
+		return
+	}
+	collected := make([]searchBatchResult, len(request.Items))
+	for result := range results {
+		collected[result.Index] = result
+	}
+	data := make([]any, len(collected))
+	for i := range collected {
+		data[i] = batchResultBody(apiVersion(c), &collected[i])
+	}
+	w.respondList(c, len(data), data)
+}
+
+// streamSearchBatch writes one JSON line per item as soon as it completes.
+func (w *WebServer) streamSearchBatch(c *gin.Context, results <-chan searchBatchResult) {
+	c.Header("Content-Type", ndjsonContentType)
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	for result := range results {
+		if err := encoder.Encode(batchResultBody(apiVersion(c), &result)); err != nil {
+			w.logger.Err(err).Msg("error while streaming batch search results")
+			continue
+		}
+		c.Writer.Flush()
+	}
+}
+
+func batchResultBody(version string, result *searchBatchResult) any {
+	if version == apiV2 {
+		body := &searchBatchResultV2{
+			Index:    result.Index,
+			Id:       result.Id,
+			Provider: result.Provider,
+			Total:    len(result.Subtitles),
+			Data:     toSubtitlesV2(result.Subtitles),
+			Skipped:  result.Skipped,
+		}
+		if result.Err != nil {
+			body.Error = &errorV2{Code: errorCode(result.Status), Message: result.Err.Error()}
+		}
+		return body
+	}
+	body := &searchBatchResultV1{
+		Index:    result.Index,
+		Id:       result.Id,
+		Provider: result.Provider,
+		Total:    len(result.Subtitles),
+		Data:     result.Subtitles,
+		Skipped:  result.Skipped,
+	}
+	if body.Data == nil {
+		body.Data = []models.Subtitle{}
+	}
+	if result.Err != nil {
+		body.Error = result.Err.Error()
+	}
+	return body
+}
@@ -0,0 +1,159 @@
+package webserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+func searchBatchRequest(path string, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func batchManager() *fakeManager {
+	return &fakeManager{subtitles: []models.Subtitle{
+		{Provider: "podnapisi", Id: 1, ExternalId: "AbC1", Title: "Dune"},
+		{Provider: "subdivx", Id: 2, ExternalId: "2", Title: "Dune"},
+	}}
+}
+
+var searchBatchItems = `{"items": [
+	{"term": "dune"},
+	{"id": "mine", "provider": "podnapisi", "term": "dune"},
+	{"provider": "nope", "term": "dune"},
+	{"provider": "all", "year": 1800},
+	{"id": "` + strings.Repeat("x", 129) + `", "term": "dune"}
+]}`
+
+func TestSearchBatchValidation(t *testing.T) {
+	srv := newTestServer(t, batchManager(), map[string]string{"SA_SEARCH_BATCH_MAX_ITEMS": "2"})
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid json", `{"items": [`},
+		{"no items", `{}`},
+		{"empty items", `{"items": []}`},
+		{"too many items", `{"items": [{"term": "a"}, {"term": "b"}, {"term": "c"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := srv.serve(searchBatchRequest("/v2/search/batch", tt.body))
+			var body errorResponseV2
+			if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil || res.Code != http.StatusBadRequest || body.Error.Code != "bad_request" {
+				t.Errorf("status = %d %s, want 400", res.Code, res.Body)
+			}
+		})
+	}
+}
+
+func TestSearchBatch(t *testing.T) {
+	srv := newTestServer(t, batchManager(), nil)
+
+	res := srv.serve(searchBatchRequest("/v1/search/batch", searchBatchItems))
+	if res.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", res.Code, res.Body)
+	}
+	var v1 struct {
+		Total int                   `json:"total"`
+		Data  []searchBatchResultV1 `json:"data"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &v1); err != nil {
+		t.Fatal(err)
+	}
+	if v1.Total != 5 || len(v1.Data) != 5 {
+		t.Fatalf("body = %s, want a result per item", res.Body)
+	}
+	// results are in request order and items fail on their own
+	want := []struct {
+		id       string
+		provider string
+		total    int
+		failed   bool
+	}{
+		{"", "all", 2, false},
+		{"mine", "podnapisi", 1, false},
+		{"", "nope", 0, true},
+		{"", "all", 0, true},
+		{strings.Repeat("x", 129), "all", 0, true},
+	}
+	for i, w := range want {
+		got := v1.Data[i]
+		if got.Index != i || got.Id != w.id || got.Provider != w.provider || got.Total != w.total || len(got.Data) != w.total || (got.Error != "") != w.failed {
+			t.Errorf("result %d = %+v, want %+v", i, got, w)
+		}
+	}
+
+	res = srv.serve(searchBatchRequest("/v2/search/batch", searchBatchItems))
+	var v2 struct {
+		Data []searchBatchResultV2 `json:"data"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &v2); err != nil || len(v2.Data) != 5 {
+		t.Fatalf("body = %s, want a result per item", res.Body)
+	}
+	if v2.Data[1].Data[0].Uid != "podnapisi:AbC1" {
+		t.Errorf("result 1 = %+v, want v2 subtitles", v2.Data[1])
+	}
+	if v2.Data[2].Error == nil || v2.Data[2].Error.Code != "not_found" || v2.Data[3].Error == nil || v2.Data[3].Error.Code != "bad_request" {
+		t.Errorf("errors = %+v, %+v, want not_found and bad_request", v2.Data[2].Error, v2.Data[3].Error)
+	}
+}
+
+func TestSearchBatchStream(t *testing.T) {
+	srv := newTestServer(t, batchManager(), nil)
+	req := searchBatchRequest("/v2/search/batch", searchBatchItems)
+	req.Header.Set("Accept", ndjsonContentType)
+
+	res := srv.serve(req)
+	if res.Code != http.StatusOK || res.Header().Get("Content-Type") != ndjsonContentType {
+		t.Fatalf("status = %d, Content-Type %q, want 200 with NDJSON", res.Code, res.Header().Get("Content-Type"))
+	}
+	// lines come in completion order, one per item
+	var indexes []int
+	lines := bufio.NewScanner(res.Body)
+	for lines.Scan() {
+		var result searchBatchResultV2
+		if err := json.Unmarshal(lines.Bytes(), &result); err != nil {
+			t.Fatalf("line %q: %v", lines.Text(), err)
+		}
+		indexes = append(indexes, result.Index)
+	}
+	slices.Sort(indexes)
+	if !slices.Equal(indexes, []int{0, 1, 2, 3, 4}) {
+		t.Errorf("streamed indexes %v, want one line per item", indexes)
+	}
+}
+
+func TestSearchBatchRateLimit(t *testing.T) {
+	// the bucket barely refills during the test
+	srv := newTestServer(t, batchManager(), map[string]string{"SA_API_KEYS": "tests:s3cret", "SA_API_KEY_RATE": "0.001", "SA_API_KEY_BURST": "5"})
+	batch := func(terms ...string) *httptest.ResponseRecorder {
+		var items []string
+		for _, term := range terms {
+			items = append(items, `{"term": "`+term+`"}`)
+		}
+		return srv.serve(searchBatchRequest("/v2/search/batch", `{"items": [`+strings.Join(items, ",")+`]}`), ApiKeyHeader, "s3cret")
+	}
+
+	// a batch larger than the burst can never run
+	if res := batch("a", "b", "c", "d", "e", "f"); res.Code != http.StatusBadRequest {
+		t.Fatalf("oversized batch status = %d, want 400: %s", res.Code, res.Body)
+	}
+	// the refused batch only spent the token of its request
+	if res := batch("a", "b", "c"); res.Code != http.StatusOK {
+		t.Fatalf("first batch status = %d, want 200: %s", res.Code, res.Body)
+	}
+	res := batch("d", "e")
+	if res.Code != http.StatusTooManyRequests || res.Header().Get("Retry-After") == "" {
+		t.Fatalf("second batch status = %d, Retry-After %q, want 429 with Retry-After", res.Code, res.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,162 @@
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"github.com/xochilpili/subtitler-api/internal/providers"
+	"github.com/xochilpili/subtitler-api/internal/subfile"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var errNoSubtitleMatched = errors.New("no subtitle matched the release and languages")
+
+// Best searches every provider, ranks the results against the requested
+// release and languages and answers with the first of the top
+// SA_BEST_MAX_ATTEMPTS candidates that downloads and validates, unpacked
+// and re-encoded as UTF-8. X-Subtitle-* headers describe the one chosen.
+// Every candidate tried takes a download from the caller's daily quota,
+// given back when the provider fails to serve it; the attempts stop once
+// the quota is spent.
+func (w *WebServer) Best(c *gin.Context) {
+	var query BestQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		w.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	target := query.Target()
+	if target.Title == "" {
+		w.respondError(c, http.StatusBadRequest, fmt.Errorf("no title found in release %q, pass a term", query.Release))
+		return
+	}
+	// the first candidate's download is taken before searching, so that
+	// callers out of quota are refused without reaching the providers
+	if !w.takeDownload(c) {
+		w.rejectDownloadQuota(c)
+		return
+	}
+
+	ctx, span := otel.Tracer(w.config.ServiceName).Start(c.Request.Context(), "Best subtitle")
+	defer span.End()
+	ctx = query.Context(ctx)
+	span.SetAttributes(
+		attribute.String("query", target.Title),
+		attribute.String("release", query.Release),
+		attribute.String("language", query.Language),
+	)
+
+	subtitles, skipped := w.manager.Search(ctx, "", query.Request(target), &models.PostFilters{})
+	candidates := providers.Rank(subtitles, target, query.Languages(), query.HearingImpaired)
+	span.SetAttributes(attribute.Int("result_count", len(subtitles)), attribute.Int("candidates", len(candidates)))
+	if len(candidates) == 0 {
+		for _, provider := range skipped {
+			w.logger.Info().Msgf("best subtitle search skipped %s: %s", provider.Provider, provider.Reason)
+		}
+		w.returnDownload(c)
+		w.respondError(c, http.StatusNotFound, errNoSubtitleMatched)
+		return
+	}
+
+	attempts := min(len(candidates), max(w.config.BestMaxAttempts, 1))
+	var lastErr error
+	for i, candidate := range candidates[:attempts] {
+		if i > 0 && !w.takeDownload(c) {
+			span.AddEvent("download quota reached", trace.WithAttributes(attribute.Int("attempts", i)))
+			w.rejectDownloadQuota(c)
+			return
+		}
+		file, err := w.downloadFile(ctx, candidate.Provider, candidate.DownloadId())
+		if err != nil {
+			w.returnDownload(c)
+		} else {
+			file, err = bestFile(file, target, query.Format)
+		}
+		if err != nil {
+			w.logger.Warn().Err(err).Msgf("best subtitle candidate %s failed, trying the next one", candidate.Uid())
+			span.AddEvent("candidate failed", trace.WithAttributes(
+				attribute.String("uid", candidate.Uid()),
+				attribute.String("error", err.Error()),
+			))
+			lastErr = err
+			continue
+		}
+		if query.Release != "" {
+			// players load subtitles named after the video automatically
+			file.Name = sanitizeFilename(query.Release) + "." + strings.ToLower(candidate.Language) + path.Ext(file.Name)
+		} else {
+			file.Name = sanitizeFilename(subtitleFilename(&candidate.Subtitle)) + path.Ext(file.Name)
+		}
+		span.SetAttributes(attribute.String("uid", candidate.Uid()), attribute.Int("attempts", i+1))
+
+		c.Header("Content-Disposition", contentDisposition(file.Name))
+		c.Header("X-Subtitle-Uid", candidate.Uid())
+		c.Header("X-Subtitle-Provider", candidate.Provider)
+		c.Header("X-Subtitle-Id", candidate.DownloadId())
+		c.Header("X-Subtitle-Language", candidate.Language)
+		c.Header("X-Subtitle-Release", mime.QEncoding.Encode("utf-8", candidate.Description))
+		c.Header("X-Subtitle-Score", strconv.Itoa(candidate.Score))
+		c.Header("X-Subtitle-Rank", strconv.Itoa(i+1))
+		c.Header("X-Subtitle-Candidates", strconv.Itoa(len(candidates)))
+		c.Data(http.StatusOK, subfile.ContentTypes[strings.TrimPrefix(path.Ext(file.Name), ".")]+"; charset=utf-8", file.Data)
+		return
+	}
+
+	w.respondError(c, downloadStatus(lastErr), fmt.Errorf("none of the %d best subtitles could be used: %w", attempts, lastErr))
+}
+
+// bestFile turns a downloaded candidate into a single, valid subtitle file
+// in format, or in its own format when format is empty.
+func bestFile(file subfile.File, target *providers.Release, format string) (subfile.File, error) {
+	files, err := subfile.Extract(file)
+	if err != nil {
+		return file, fmt.Errorf("error while extracting %s: %w", file.Name, err)
+	}
+	if file, err = pickEpisode(files, target); err != nil {
+		return file, err
+	}
+	if !subfile.Extensions[strings.ToLower(path.Ext(file.Name))] {
+		if detected := subfile.Detect(file.Data); detected != "" {
+			file.Name = strings.TrimSuffix(file.Name, path.Ext(file.Name)) + "." + detected
+		}
+	}
+	if err := subfile.Validate(file); err != nil {
+		return file, err
+	}
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(path.Ext(file.Name)), ".")
+	}
+	return subfile.Convert(file, format)
+}
+
+// pickEpisode chooses the file of an archive that matches the requested
+// episode, as season packs hold one per episode. Otherwise the first
+// SubRip file wins, as the most widely supported format.
+func pickEpisode(files []subfile.File, target *providers.Release) (subfile.File, error) {
+	if len(files) == 1 {
+		return files[0], nil
+	}
+	if target.Episode > 0 {
+		for _, file := range files {
+			release := providers.ParseRelease(file.Name)
+			if release.Season == target.Season && release.Episode == target.Episode {
+				return file, nil
+			}
+		}
+		return subfile.File{}, fmt.Errorf("%w: no file for S%02dE%02d among %d", subfile.ErrInvalidSubtitle, target.Season, target.Episode, len(files))
+	}
+	for _, file := range files {
+		if strings.EqualFold(path.Ext(file.Name), "."+subfile.FormatSrt) {
+			return file, nil
+		}
+	}
+	return files[0], nil
+}
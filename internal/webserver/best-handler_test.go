@@ -0,0 +1,104 @@
+package webserver
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+// bestCandidates ranks first, second and third, in that order.
+var bestCandidates = []models.Subtitle{
+	{Provider: "podnapisi", ExternalId: "first", Title: "The Matrix", Language: "en", Year: 1999, Downloads: 30},
+	{Provider: "podnapisi", ExternalId: "second", Title: "The Matrix", Language: "en", Year: 1999, Downloads: 20},
+	{Provider: "podnapisi", ExternalId: "third", Title: "The Matrix", Language: "en", Year: 1999, Downloads: 10},
+}
+
+func TestBestQuota(t *testing.T) {
+	invalid := func(provider, id string) (*models.SubtitleFile, error) {
+		return textFile(id+".srt", "not a subtitle"), nil
+	}
+	upstreamError := func(provider, id string) (*models.SubtitleFile, error) {
+		return nil, errors.New("podnapisi download failed with status 500")
+	}
+	tests := []struct {
+		name          string
+		quota         string
+		failing       func(provider, id string) (*models.SubtitleFile, error)
+		wantStatus    int
+		wantDownloads []string
+		wantRemaining string
+	}{
+		{
+			name:          "invalid candidates keep their download",
+			quota:         "5",
+			failing:       invalid,
+			wantStatus:    http.StatusOK,
+			wantDownloads: []string{"podnapisi:first", "podnapisi:second", "podnapisi:third"},
+			wantRemaining: "2",
+		},
+		{
+			name:          "failed downloads are given back",
+			quota:         "5",
+			failing:       upstreamError,
+			wantStatus:    http.StatusOK,
+			wantDownloads: []string{"podnapisi:first", "podnapisi:second", "podnapisi:third"},
+			wantRemaining: "4",
+		},
+		{
+			name:          "attempts stop once the quota is spent",
+			quota:         "2",
+			failing:       invalid,
+			wantStatus:    http.StatusTooManyRequests,
+			wantDownloads: []string{"podnapisi:first", "podnapisi:second"},
+			wantRemaining: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := &fakeManager{subtitles: bestCandidates, download: func(provider, id string) (*models.SubtitleFile, error) {
+				if id == "third" {
+					return textFile(id+".srt", testSrt), nil
+				}
+				return tt.failing(provider, id)
+			}}
+			srv := newTestServer(t, manager, map[string]string{"SA_API_KEYS": "tests:s3cret", "SA_API_KEY_DAILY_DOWNLOADS": tt.quota})
+
+			res := srv.serve(httptest.NewRequest(http.MethodGet, "/v2/best?term=the+matrix&language=en", nil), ApiKeyHeader, "s3cret")
+			if res.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", res.Code, tt.wantStatus, res.Body)
+			}
+			if got := manager.downloaded(); !slices.Equal(got, tt.wantDownloads) {
+				t.Errorf("downloads = %v, want %v", got, tt.wantDownloads)
+			}
+			if got := res.Header().Get("X-Quota-Remaining"); got != tt.wantRemaining {
+				t.Errorf("X-Quota-Remaining = %q, want %q", got, tt.wantRemaining)
+			}
+			if tt.wantStatus == http.StatusOK && res.Header().Get("X-Subtitle-Rank") != "3" {
+				t.Errorf("X-Subtitle-Rank = %q, want 3", res.Header().Get("X-Subtitle-Rank"))
+			}
+		})
+	}
+}
+
+func TestBestOutOfQuota(t *testing.T) {
+	srv := newTestServer(t, &fakeManager{subtitles: bestCandidates}, map[string]string{"SA_API_KEYS": "tests:s3cret", "SA_API_KEY_DAILY_DOWNLOADS": "1"})
+	request := func(language string) *httptest.ResponseRecorder {
+		return srv.serve(httptest.NewRequest(http.MethodGet, "/v2/best?term=the+matrix&language="+language, nil), ApiKeyHeader, "s3cret")
+	}
+
+	// a search without candidates gives the download back
+	if res := request("pt"); res.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", res.Code)
+	}
+	if res := request("en"); res.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", res.Code, res.Body)
+	}
+	res := request("en")
+	if res.Code != http.StatusTooManyRequests || res.Header().Get("Retry-After") == "" {
+		t.Fatalf("status = %d, Retry-After %q, want 429 with Retry-After", res.Code, res.Header().Get("Retry-After"))
+	}
+}
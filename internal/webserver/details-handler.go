@@ -0,0 +1,51 @@
+package webserver
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"github.com/xochilpili/subtitler-api/internal/providers"
+)
+
+func (w *WebServer) DetailsHandler(c *gin.Context) {
+	var uri SubtitleUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		w.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	var query RateLimitQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		w.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	comments, err := w.manager.Details(query.Context(c.Request.Context()), uri.Provider, uri.Id)
+	if errors.Is(err, providers.ErrUnknownProvider) ||
+		errors.Is(err, providers.ErrNoDetails) ||
+		errors.Is(err, providers.ErrSubtitleNotFound) {
+		w.respondError(c, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, providers.ErrRateLimited) {
+		w.respondError(c, http.StatusTooManyRequests, err)
+		return
+	}
+	if errors.Is(err, providers.ErrCircuitOpen) {
+		w.respondError(c, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil {
+		w.respondError(c, http.StatusBadGateway, err)
+		return
+	}
+	if comments == nil {
+		comments = []models.SubComments{}
+	}
+
+	if apiVersion(c) == apiV2 {
+		w.respondList(c, len(comments), toCommentsV2(comments))
+		return
+	}
+	w.respondList(c, len(comments), comments)
+}
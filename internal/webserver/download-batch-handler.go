@@ -0,0 +1,278 @@
+package webserver
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"github.com/xochilpili/subtitler-api/internal/subfile"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxBatchDownloadSize bounds each download held in memory by a batch.
+const maxBatchDownloadSize = 20 << 20
+
+const (
+	batchManifestName = "manifest.json"
+	// maxFilenameLength is in bytes, well below the 255 most filesystems take.
+	maxFilenameLength = 150
+)
+
+var unsafeFilenameChars = strings.NewReplacer(
+	"/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_",
+)
+
+// downloadBatchEntry is the outcome of one item, as listed in the manifest.
+type downloadBatchEntry struct {
+	Index    int      `json:"index"`
+	Provider string   `json:"provider"`
+	Id       string   `json:"id"`
+	Files    []string `json:"files,omitempty"`
+	Error    string   `json:"error,omitempty"`
+
+	files []subfile.File
+}
+
+type downloadBatchManifest struct {
+	Total  int                   `json:"total"`
+	Failed int                   `json:"failed"`
+	Items  []*downloadBatchEntry `json:"items"`
+}
+
+// DownloadBatch fetches several subtitles, at most
+// SA_DOWNLOAD_BATCH_CONCURRENCY at a time, and streams them back as one
+// zip archive in completion order. Failed items do not stop the batch;
+// they are listed in the manifest.json written last.
+func (w *WebServer) DownloadBatch(c *gin.Context) {
+	var request DownloadBatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		w.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if len(request.Items) > w.config.DownloadBatchMaxItems {
+		w.respondError(c, http.StatusBadRequest, fmt.Errorf("a batch holds at most %d downloads", w.config.DownloadBatchMaxItems))
+		return
+	}
+	// every download counts against the client rate limit before the
+	// archive starts streaming
+	if !w.chargeRequests(c, len(request.Items)) {
+		return
+	}
+
+	ctx, span := otel.Tracer(w.config.ServiceName).Start(c.Request.Context(), "Download batch")
+	defer span.End()
+	span.SetAttributes(attribute.Int("batch_size", len(request.Items)))
+	ctx = request.Context(ctx)
+
+	var client *apiClient
+	if value, ok := c.Get(apiClientKey); ok {
+		client = value.(*apiClient)
+	}
+
+	entries := make(chan *downloadBatchEntry)
+	go func() {
+		sem := make(chan struct{}, max(w.config.DownloadBatchConcurrency, 1))
+		wg := &sync.WaitGroup{}
+		for index, item := range request.Items {
+			entry := &downloadBatchEntry{Index: index, Provider: item.Provider, Id: item.Id}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				entry.Error = ctx.Err().Error()
+				entries <- entry
+				continue
+			}
+			wg.Add(1)
+			go func(item DownloadBatchItem, entry *downloadBatchEntry) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				if err := w.fetchBatchItem(ctx, client, &request, &item, entry); err != nil {
+					entry.Error = err.Error()
+				}
+				entries <- entry
+			}(item, entry)
+		}
+		wg.Wait()
+		close(entries)
+	}()
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", contentDisposition("subtitles.zip"))
+	c.Status(http.StatusOK)
+	archive := zip.NewWriter(c.Writer)
+	manifest := &downloadBatchManifest{Total: len(request.Items), Items: make([]*downloadBatchEntry, len(request.Items))}
+	names := map[string]bool{batchManifestName: true}
+	for entry := range entries {
+		manifest.Items[entry.Index] = entry
+		for _, file := range entry.files {
+			name := uniqueFilename(names, file.Name)
+			if err := writeZipEntry(archive, name, file.Data); err != nil {
+				w.logger.Err(err).Msg("error while streaming batch download")
+				entry.Error = err.Error()
+				break
+			}
+			entry.Files = append(entry.Files, name)
+		}
+		// the manifest outlives the loop, the file contents need not
+		entry.files = nil
+		if err := archive.Flush(); err != nil {
+			w.logger.Err(err).Msg("error while streaming batch download")
+		}
+		c.Writer.Flush()
+	}
+	for _, entry := range manifest.Items {
+		if entry.Error != "" {
+			manifest.Failed++
+		}
+	}
+	raw, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := writeZipEntry(archive, batchManifestName, raw); err != nil {
+		w.logger.Err(err).Msg("error while writing batch download manifest")
+	}
+	if err := archive.Close(); err != nil {
+		w.logger.Err(err).Msg("error while closing batch download archive")
+	}
+	span.SetAttributes(attribute.Int("failed", manifest.Failed))
+}
+
+// fetchBatchItem downloads one item against the caller's daily quota and
+// prepares the files it adds to the archive.
+func (w *WebServer) fetchBatchItem(ctx context.Context, client *apiClient, request *DownloadBatchRequest, item *DownloadBatchItem, entry *downloadBatchEntry) error {
+	if client != nil {
+		if _, ok := client.takeDownload(); !ok {
+			return errDownloadQuotaReached
+		}
+	}
+	file, err := w.downloadFile(ctx, item.Provider, item.Id)
+	if err != nil {
+		if client != nil {
+			client.returnDownload()
+		}
+		return err
+	}
+
+	base := w.batchFilename(item)
+	files := []subfile.File{file}
+	if request.Extract || request.Format != "" {
+		if files, err = subfile.Extract(file); err != nil {
+			return fmt.Errorf("error while extracting %s: %w", file.Name, err)
+		}
+	}
+	for i := range files {
+		if request.Format != "" {
+			converted, err := subfile.Convert(files[i], request.Format)
+			if err != nil && !errors.Is(err, subfile.ErrUnsupportedFormat) {
+				return err
+			}
+			// formats that cannot be converted are kept as they are
+			files[i] = converted
+		}
+		if base != "" {
+			files[i].Name = base + strings.ToLower(path.Ext(files[i].Name))
+		}
+	}
+	entry.files = files
+	return nil
+}
+
+// downloadFile reads a whole subtitle file from a provider.
+func (w *WebServer) downloadFile(ctx context.Context, provider string, subtitleId string) (subfile.File, error) {
+	file, err := w.manager.Download(ctx, provider, subtitleId)
+	if err != nil {
+		return subfile.File{}, err
+	}
+	defer file.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(file.Body, maxBatchDownloadSize+1))
+	if err != nil {
+		return subfile.File{}, fmt.Errorf("error while reading subtitle: %w", err)
+	}
+	if len(data) > maxBatchDownloadSize {
+		return subfile.File{}, errors.New("subtitle is too large")
+	}
+	name := sanitizeFilename(file.Filename)
+	if name == "" {
+		name = sanitizeFilename(provider + "-" + subtitleId)
+	}
+	return subfile.File{Name: name, Data: data}, nil
+}
+
+// batchFilename names an item after the explicit name in the request or,
+// when the subtitle was seen in a recent search, after its title with the
+// episode or year and language, e.g. "Breaking Bad - S01E02.en". An empty
+// name keeps the provider filename.
+func (w *WebServer) batchFilename(item *DownloadBatchItem) string {
+	if item.Name != "" {
+		return sanitizeFilename(strings.TrimSuffix(item.Name, path.Ext(item.Name)))
+	}
+	subtitle, _, ok := w.manager.Subtitle(item.Provider + ":" + item.Id)
+	if !ok || subtitle.Title == "" {
+		return ""
+	}
+	return sanitizeFilename(subtitleFilename(subtitle))
+}
+
+func subtitleFilename(subtitle *models.Subtitle) string {
+	name := subtitle.Title
+	switch {
+	case subtitle.Season > 0 || subtitle.Episode > 0:
+		name += fmt.Sprintf(" - S%02dE%02d", subtitle.Season, subtitle.Episode)
+	case subtitle.Year > 0:
+		name += " (" + strconv.Itoa(subtitle.Year) + ")"
+	}
+	if subtitle.Language != "" {
+		name += "." + strings.ToLower(subtitle.Language)
+	}
+	return name
+}
+
+func sanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, unsafeFilenameChars.Replace(name))
+	name = strings.Trim(strings.TrimSpace(name), ".")
+	if len(name) > maxFilenameLength {
+		cut := maxFilenameLength
+		for cut > 0 && !utf8.RuneStart(name[cut]) {
+			cut--
+		}
+		name = name[:cut]
+	}
+	return name
+}
+
+// uniqueFilename numbers repeated names: "a.srt", "a.2.srt", "a.3.srt".
+func uniqueFilename(names map[string]bool, name string) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; names[candidate]; i++ {
+		candidate = fmt.Sprintf("%s.%d%s", base, i, ext)
+	}
+	names[candidate] = true
+	return candidate
+}
+
+func writeZipEntry(archive *zip.Writer, name string, data []byte) error {
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = entry.Write(data)
+	return err
+}
@@ -0,0 +1,53 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func downloadBatchRequest(ids ...string) *http.Request {
+	var items []string
+	for _, id := range ids {
+		items = append(items, `{"provider": "podnapisi", "id": "`+id+`"}`)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v2/download/batch", strings.NewReader(`{"items": [`+strings.Join(items, ",")+`]}`))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestDownloadBatchRateLimit(t *testing.T) {
+	manager := &fakeManager{}
+	// the bucket barely refills during the test
+	srv := newTestServer(t, manager, map[string]string{"SA_API_KEYS": "tests:s3cret", "SA_API_KEY_RATE": "0.001", "SA_API_KEY_BURST": "4"})
+
+	if res := srv.serve(downloadBatchRequest("a", "b", "c"), ApiKeyHeader, "s3cret"); res.Code != http.StatusOK {
+		t.Fatalf("first batch status = %d, want 200: %s", res.Code, res.Body)
+	}
+	// one token is left, the second batch needs two
+	res := srv.serve(downloadBatchRequest("d", "e"), ApiKeyHeader, "s3cret")
+	if res.Code != http.StatusTooManyRequests || res.Header().Get("Retry-After") == "" {
+		t.Fatalf("second batch status = %d, Retry-After %q, want 429 with Retry-After", res.Code, res.Header().Get("Retry-After"))
+	}
+	if ct := res.Header().Get("Content-Type"); strings.Contains(ct, "zip") {
+		t.Errorf("refused batch started streaming an archive, Content-Type %q", ct)
+	}
+	if got := manager.downloaded(); len(got) != 3 {
+		t.Errorf("downloads = %v, want only the first batch", got)
+	}
+}
+
+func TestDownloadBatchOverBurst(t *testing.T) {
+	manager := &fakeManager{}
+	srv := newTestServer(t, manager, map[string]string{"SA_API_KEYS": "tests:s3cret", "SA_API_KEY_BURST": "2"})
+
+	// no bucket refill would ever cover the three items past the request
+	res := srv.serve(downloadBatchRequest("a", "b", "c", "d"), ApiKeyHeader, "s3cret")
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", res.Code, res.Body)
+	}
+	if got := manager.downloaded(); len(got) != 0 {
+		t.Errorf("downloads = %v, want none", got)
+	}
+}
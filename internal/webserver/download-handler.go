@@ -0,0 +1,138 @@
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xochilpili/subtitler-api/internal/providers"
+)
+
+// Download streams a subtitle file from a provider. Its size and ETag are
+// passed through from upstream; local library files also serve ranges. An
+// If-None-Match on an ETag already seen for the subtitle is answered with
+// 304 without reaching the provider.
+func (w *WebServer) Download(c *gin.Context) {
+	var uri DownloadUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		w.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	var query RateLimitQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		w.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	// a copy the caller already holds is not fetched again
+	uid := uri.Provider + ":" + uri.SubtitleId
+	if etag, ok := w.etags.get(uid); ok && etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Header("ETag", etag)
+		c.Status(http.StatusNotModified)
+		return
+	}
+	w.logger.Info().Msgf("downloading subtitle: %s", uri.SubtitleId)
+	file, err := w.manager.Download(query.Context(c.Request.Context()), uri.Provider, uri.SubtitleId)
+	if err != nil {
+		w.respondError(c, downloadStatus(err), err)
+		return
+	}
+	defer file.Body.Close()
+
+	name := sanitizeFilename(file.Filename)
+	if name == "" {
+		name = sanitizeFilename(uri.Provider + "-" + uri.SubtitleId)
+	}
+	c.Header("Content-Disposition", contentDisposition(name))
+	if file.ETag != "" {
+		c.Header("ETag", file.ETag)
+		w.etags.store(uid, file.ETag)
+	}
+	if file.ContentType != "" {
+		c.Header("Content-Type", file.ContentType)
+	}
+	if seeker, ok := file.Body.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, name, file.ModTime, seeker)
+		return
+	}
+
+	c.Header("Accept-Ranges", "none")
+	if file.ETag != "" && etagMatches(c.GetHeader("If-None-Match"), file.ETag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if file.ContentType == "" {
+		c.Header("Content-Type", "application/octet-stream")
+	}
+	if file.Size >= 0 {
+		c.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+	}
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, file.Body); err != nil {
+		// the status is already sent, the client sees a truncated body
+		w.logger.Err(err).Msgf("error while streaming subtitle %s from %s", uri.SubtitleId, uri.Provider)
+		c.Error(err)
+	}
+}
+
+// downloadStatus maps a Manager.Download error to the response status.
+func downloadStatus(err error) int {
+	switch {
+	case errors.Is(err, providers.ErrUnknownProvider) || errors.Is(err, providers.ErrSubtitleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, providers.ErrQuotaExceeded) || errors.Is(err, providers.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, providers.ErrCircuitOpen):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
+
+// contentDisposition formats an attachment header as RFC 6266 describes: a
+// quoted ASCII filename, followed by the UTF-8 one in filename* when the
+// name is not plain ASCII.
+func contentDisposition(name string) string {
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+	value := fmt.Sprintf(`attachment; filename="%s"`, fallback)
+	if fallback != name {
+		value += "; filename*=UTF-8''" + encodeExtValue(name)
+	}
+	return value
+}
+
+// encodeExtValue percent-encodes every byte outside the RFC 5987
+// attr-char set.
+func encodeExtValue(value string) string {
+	var out strings.Builder
+	for _, b := range []byte(value) {
+		if 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || strings.IndexByte("!#$&+-.^_`|~", b) >= 0 {
+			out.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&out, "%%%02X", b)
+	}
+	return out.String()
+}
+
+// etagMatches applies the weak comparison If-None-Match calls for.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package webserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"github.com/xochilpili/subtitler-api/internal/providers"
+)
+
+// seekableFile is a download body that can be seeked, as local library
+// files are.
+type seekableFile struct{ *strings.Reader }
+
+func (seekableFile) Close() error { return nil }
+
+func TestDownloadRanges(t *testing.T) {
+	manager := &fakeManager{download: func(provider, id string) (*models.SubtitleFile, error) {
+		if provider == "local" {
+			return &models.SubtitleFile{Body: seekableFile{strings.NewReader(testSrt)}, Filename: id + ".srt", Size: int64(len(testSrt))}, nil
+		}
+		return textFile(id+".srt", testSrt), nil
+	}}
+	srv := newTestServer(t, manager, nil)
+
+	res := srv.serve(httptest.NewRequest(http.MethodGet, "/v2/download/local/a", nil), "Range", "bytes=2-4")
+	if res.Code != http.StatusPartialContent || res.Body.String() != testSrt[2:5] {
+		t.Fatalf("status = %d, body %q, want 206 with %q", res.Code, res.Body, testSrt[2:5])
+	}
+	if got, want := res.Header().Get("Content-Range"), fmt.Sprintf("bytes 2-4/%d", len(testSrt)); got != want {
+		t.Errorf("Content-Range = %q, want %q", got, want)
+	}
+
+	// streamed provider files are sent whole
+	res = srv.serve(httptest.NewRequest(http.MethodGet, "/v2/download/podnapisi/a", nil), "Range", "bytes=2-4")
+	if res.Code != http.StatusOK || res.Body.String() != testSrt {
+		t.Fatalf("status = %d, body %q, want 200 with the whole file", res.Code, res.Body)
+	}
+	if res.Header().Get("Accept-Ranges") != "none" {
+		t.Errorf("Accept-Ranges = %q, want none", res.Header().Get("Accept-Ranges"))
+	}
+	if got, want := res.Header().Get("Content-Length"), fmt.Sprint(len(testSrt)); got != want {
+		t.Errorf("Content-Length = %q, want %q", got, want)
+	}
+}
+
+func TestDownloadNotModified(t *testing.T) {
+	manager := &fakeManager{download: func(provider, id string) (*models.SubtitleFile, error) {
+		file := textFile(id+".srt", testSrt)
+		file.ETag = `"v1"`
+		return file, nil
+	}}
+	srv := newTestServer(t, manager, map[string]string{"SA_API_KEYS": "tests:s3cret", "SA_API_KEY_DAILY_DOWNLOADS": "5"})
+	download := func(id string, ifNoneMatch string) *httptest.ResponseRecorder {
+		return srv.serve(httptest.NewRequest(http.MethodGet, "/v2/download/podnapisi/"+id, nil), ApiKeyHeader, "s3cret", "If-None-Match", ifNoneMatch)
+	}
+
+	tests := []struct {
+		name          string
+		id            string
+		ifNoneMatch   string
+		wantStatus    int
+		wantDownloads []string
+	}{
+		// the provider answers first, as its ETag is not known yet
+		{"unknown etag", "a", `"v1"`, http.StatusNotModified, []string{"podnapisi:a"}},
+		{"known etag", "a", `W/"v0", "v1"`, http.StatusNotModified, []string{"podnapisi:a"}},
+		{"another etag", "a", `"v0"`, http.StatusOK, []string{"podnapisi:a", "podnapisi:a"}},
+		{"etags are kept per subtitle", "b", `"v1"`, http.StatusNotModified, []string{"podnapisi:a", "podnapisi:a", "podnapisi:b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := download(tt.id, tt.ifNoneMatch)
+			if res.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", res.Code, tt.wantStatus, res.Body)
+			}
+			if res.Header().Get("ETag") != `"v1"` {
+				t.Errorf("ETag = %q, want %q", res.Header().Get("ETag"), `"v1"`)
+			}
+			if got := manager.downloaded(); !slices.Equal(got, tt.wantDownloads) {
+				t.Errorf("downloads = %v, want %v", got, tt.wantDownloads)
+			}
+		})
+	}
+
+	// only the 200 counted against the quota
+	if res := download("c", ""); res.Header().Get("X-Quota-Remaining") != "3" {
+		t.Errorf("X-Quota-Remaining = %q, want 3", res.Header().Get("X-Quota-Remaining"))
+	}
+}
+
+func TestDownloadFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"The.Matrix.1999.srt", `attachment; filename="The.Matrix.1999.srt"`},
+		{"Amélie.srt", `attachment; filename="Am_lie.srt"; filename*=UTF-8''Am%C3%A9lie.srt`},
+		{"千と千尋.ass", `attachment; filename="____.ass"; filename*=UTF-8''%E5%8D%83%E3%81%A8%E5%8D%83%E5%B0%8B.ass`},
+		{`../say "hi"; x=1.srt`, `attachment; filename="_say _hi_; x=1.srt"`},
+		{"line\r\nbreak.srt", `attachment; filename="linebreak.srt"`},
+		// without a usable name, the subtitle is named after its id
+		{"..", `attachment; filename="podnapisi-a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			manager := &fakeManager{download: func(provider, id string) (*models.SubtitleFile, error) {
+				return textFile(tt.filename, testSrt), nil
+			}}
+			res := newTestServer(t, manager, nil).serve(httptest.NewRequest(http.MethodGet, "/v2/download/podnapisi/a", nil))
+			if got := res.Header().Get("Content-Disposition"); got != tt.want {
+				t.Errorf("Content-Disposition = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{providers.ErrUnknownProvider, http.StatusNotFound},
+		{fmt.Errorf("podnapisi: %w", providers.ErrSubtitleNotFound), http.StatusNotFound},
+		{providers.ErrQuotaExceeded, http.StatusTooManyRequests},
+		{providers.ErrRateLimited, http.StatusTooManyRequests},
+		{providers.ErrCircuitOpen, http.StatusServiceUnavailable},
+		{errors.New("podnapisi download failed with status 500"), http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			manager := &fakeManager{download: func(provider, id string) (*models.SubtitleFile, error) {
+				return nil, tt.err
+			}}
+			res := newTestServer(t, manager, nil).serve(httptest.NewRequest(http.MethodGet, "/v2/download/podnapisi/a", nil))
+			if res.Code != tt.want {
+				t.Errorf("status = %d, want %d", res.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package webserver
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type knownETag struct {
+	uid     string
+	etag    string
+	expires time.Time
+}
+
+// etagCache remembers the ETag last sent for each uid, so a download that
+// would only be answered with 304 is not fetched from the provider. It
+// holds at most size entries, evicting the least recently used first, and
+// forgets an ETag after ttl in case the provider file changed.
+type etagCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[string]*list.Element
+	// recent orders the entries from most to least recently used
+	recent *list.List
+}
+
+func newETagCache(ttl time.Duration, size int) *etagCache {
+	return &etagCache{ttl: ttl, size: size, entries: map[string]*list.Element{}, recent: list.New()}
+}
+
+func (c *etagCache) store(uid string, etag string) {
+	if c.ttl <= 0 || c.size <= 0 || etag == "" {
+		return
+	}
+	entry := &knownETag{uid: uid, etag: etag, expires: time.Now().Add(c.ttl)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[uid]; ok {
+		element.Value = entry
+		c.recent.MoveToFront(element)
+		return
+	}
+	c.entries[uid] = c.recent.PushFront(entry)
+	for c.recent.Len() > c.size {
+		c.remove(c.recent.Back())
+	}
+}
+
+func (c *etagCache) get(uid string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[uid]
+	if !ok {
+		return "", false
+	}
+	entry := element.Value.(*knownETag)
+	if time.Now().After(entry.expires) {
+		c.remove(element)
+		return "", false
+	}
+	c.recent.MoveToFront(element)
+	return entry.etag, true
+}
+
+func (c *etagCache) remove(element *list.Element) {
+	c.recent.Remove(element)
+	delete(c.entries, element.Value.(*knownETag).uid)
+}
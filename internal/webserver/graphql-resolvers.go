@@ -0,0 +1,320 @@
+package webserver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+type queryResolver struct {
+	manager    Manager
+	maxResults int
+}
+
+type searchRequestInput struct {
+	Term       string
+	Provider   *string
+	Year       *int32
+	Group      *string
+	Quality    *string
+	Resolution *string
+	Season     *int32
+	Episode    *int32
+	Language   *string
+
+	IncludeMachineTranslated *bool
+
+	MinDownloads      *int32
+	MinRating         *float64
+	HearingImpaired   *bool
+	MachineTranslated *bool
+	Trusted           *bool
+	Sort              *string
+	Order             *string
+	First             *int32
+}
+
+func (r *queryResolver) Search(ctx context.Context, args struct{ Request searchRequestInput }) (*searchResultResolver, error) {
+	if err := spendComplexity(ctx, graphqlSearchCost); err != nil {
+		return nil, err
+	}
+	req := args.Request
+	provider := deref(req.Provider)
+	filters := &models.PostFilters{
+		Group:      deref(req.Group),
+		Quality:    deref(req.Quality),
+		Resolution: deref(req.Resolution),
+
+		MinDownloads:      int(derefInt(req.MinDownloads)),
+		HearingImpaired:   req.HearingImpaired,
+		MachineTranslated: req.MachineTranslated,
+		Trusted:           req.Trusted,
+		Sort:              deref(req.Sort),
+		Order:             deref(req.Order),
+	}
+	if req.MinRating != nil {
+		filters.MinRating = *req.MinRating
+	}
+	search := &models.SearchRequest{
+		Term:     req.Term,
+		Year:     int(derefInt(req.Year)),
+		Season:   int(derefInt(req.Season)),
+		Episode:  int(derefInt(req.Episode)),
+		Language: deref(req.Language),
+
+		MachineTranslated: derefBool(req.IncludeMachineTranslated) || derefBool(req.MachineTranslated),
+	}
+	filters.Year = search.Year
+	subtitles, skipped := r.manager.Search(ctx, provider, search, filters)
+
+	limit := r.maxResults
+	if req.First != nil && int(*req.First) < limit {
+		limit = int(*req.First)
+	}
+	return &searchResultResolver{
+		provider:  provider,
+		all:       subtitles,
+		subtitles: head(subtitles, limit),
+		skipped:   skipped,
+		manager:   r.manager,
+	}, nil
+}
+
+func (r *queryResolver) Providers() []*providerResolver {
+	var items []*providerResolver
+	for _, p := range r.manager.Providers() {
+		items = append(items, &providerResolver{info: p})
+	}
+	return items
+}
+
+func (r *queryResolver) Subtitle(ctx context.Context, args struct{ Uid graphql.ID }) (*subtitleResolver, error) {
+	if err := spendComplexity(ctx, 1); err != nil {
+		return nil, err
+	}
+	subtitle, results, ok := r.manager.Subtitle(string(args.Uid))
+	if !ok {
+		return nil, nil
+	}
+	return &subtitleResolver{subtitle: subtitle, siblings: results, manager: r.manager}, nil
+}
+
+type searchResultResolver struct {
+	provider  string
+	all       []models.Subtitle
+	subtitles []models.Subtitle
+	skipped   []models.SkippedProvider
+	manager   Manager
+}
+
+func (r *searchResultResolver) Total() int32 {
+	return int32(len(r.all))
+}
+
+func (r *searchResultResolver) Subtitles(ctx context.Context) ([]*subtitleResolver, error) {
+	if err := spendComplexity(ctx, len(r.subtitles)); err != nil {
+		return nil, err
+	}
+	items := make([]*subtitleResolver, 0, len(r.subtitles))
+	for i := range r.subtitles {
+		items = append(items, &subtitleResolver{subtitle: &r.subtitles[i], siblings: r.all, manager: r.manager})
+	}
+	return items, nil
+}
+
+func (r *searchResultResolver) Providers() []*providerStatusResolver {
+	counts := map[string]int32{}
+	for _, s := range r.all {
+		counts[s.Provider]++
+	}
+	var items []*providerStatusResolver
+	for _, p := range r.manager.Providers() {
+		status := &providerStatusResolver{info: p, results: counts[p.Name]}
+		status.searched = p.Enabled && (r.provider == "" || r.provider == p.Name)
+		for _, s := range r.skipped {
+			if s.Provider == p.Name {
+				status.skipped = &s
+			}
+		}
+		items = append(items, status)
+	}
+	return items
+}
+
+type providerResolver struct {
+	info models.ProviderInfo
+}
+
+func (r *providerResolver) Name() string  { return r.info.Name }
+func (r *providerResolver) Enabled() bool { return r.info.Enabled }
+func (r *providerResolver) Languages() []string {
+	return models.NonNil(r.info.Languages)
+}
+func (r *providerResolver) SearchKeys() []string {
+	return models.NonNil(r.info.SearchKeys)
+}
+func (r *providerResolver) Formats() []string {
+	return models.NonNil(r.info.Formats)
+}
+
+type providerStatusResolver struct {
+	info     models.ProviderInfo
+	searched bool
+	results  int32
+	skipped  *models.SkippedProvider
+}
+
+func (r *providerStatusResolver) Name() string   { return r.info.Name }
+func (r *providerStatusResolver) Enabled() bool  { return r.info.Enabled }
+func (r *providerStatusResolver) Searched() bool { return r.searched }
+func (r *providerStatusResolver) Results() int32 { return r.results }
+func (r *providerStatusResolver) Skipped() *string {
+	if r.skipped == nil {
+		return nil
+	}
+	return &r.skipped.Reason
+}
+func (r *providerStatusResolver) Error() *string {
+	if r.skipped == nil || r.skipped.Error == "" {
+		return nil
+	}
+	return &r.skipped.Error
+}
+
+type subtitleResolver struct {
+	subtitle *models.Subtitle
+	siblings []models.Subtitle
+	manager  Manager
+}
+
+func (r *subtitleResolver) Uid() graphql.ID       { return graphql.ID(r.subtitle.Uid()) }
+func (r *subtitleResolver) Id() string            { return r.subtitle.DownloadId() }
+func (r *subtitleResolver) Kind() string          { return r.subtitle.Kind() }
+func (r *subtitleResolver) Title() string         { return r.subtitle.Title }
+func (r *subtitleResolver) Description() string   { return r.subtitle.Description }
+func (r *subtitleResolver) Language() string      { return r.subtitle.Language }
+func (r *subtitleResolver) Year() *int32          { return optional(r.subtitle.Year) }
+func (r *subtitleResolver) Season() *int32        { return optional(r.subtitle.Season) }
+func (r *subtitleResolver) Episode() *int32       { return optional(r.subtitle.Episode) }
+func (r *subtitleResolver) Groups() []string      { return models.NonNil(r.subtitle.Group) }
+func (r *subtitleResolver) Qualities() []string   { return models.NonNil(r.subtitle.Quality) }
+func (r *subtitleResolver) Resolutions() []string { return models.NonNil(r.subtitle.Resolution) }
+func (r *subtitleResolver) Durations() []string   { return models.NonNil(r.subtitle.Duration) }
+func (r *subtitleResolver) DownloadUrl() string {
+	return "/" + apiV2 + "/download/" + r.subtitle.Provider + "/" + r.subtitle.DownloadId()
+}
+
+func (r *subtitleResolver) Downloads() int32        { return int32(r.subtitle.Downloads) }
+func (r *subtitleResolver) Rating() float64         { return r.subtitle.Rating }
+func (r *subtitleResolver) Uploader() *string       { return optionalString(r.subtitle.Uploader) }
+func (r *subtitleResolver) Cds() *int32             { return optional(r.subtitle.Cds) }
+func (r *subtitleResolver) HearingImpaired() bool   { return r.subtitle.HearingImpaired }
+func (r *subtitleResolver) MachineTranslated() bool { return r.subtitle.MachineTranslated }
+func (r *subtitleResolver) Trusted() bool           { return r.subtitle.Trusted }
+func (r *subtitleResolver) UploadedAt() *graphql.Time {
+	if r.subtitle.UploadedAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.subtitle.UploadedAt}
+}
+func (r *subtitleResolver) Fps() *float64 {
+	if r.subtitle.Fps == 0 {
+		return nil
+	}
+	return &r.subtitle.Fps
+}
+func (r *subtitleResolver) Comments() []*commentResolver {
+	items := make([]*commentResolver, 0, len(r.subtitle.Comments))
+	for _, c := range r.subtitle.Comments {
+		items = append(items, &commentResolver{comment: c})
+	}
+	return items
+}
+
+func (r *subtitleResolver) Provider() *providerResolver {
+	for _, p := range r.manager.Providers() {
+		if p.Name == r.subtitle.Provider {
+			return &providerResolver{info: p}
+		}
+	}
+	return &providerResolver{info: models.ProviderInfo{Name: r.subtitle.Provider}}
+}
+
+func (r *subtitleResolver) Alternatives(ctx context.Context, args struct{ First *int32 }) ([]*subtitleResolver, error) {
+	limit := 5
+	if args.First != nil {
+		limit = min(max(int(*args.First), 0), graphqlMaxAlternatives)
+	}
+	var items []*subtitleResolver
+	for i := range r.siblings {
+		if len(items) >= limit {
+			break
+		}
+		s := &r.siblings[i]
+		if s.Uid() == r.subtitle.Uid() ||
+			!strings.EqualFold(s.Title, r.subtitle.Title) ||
+			s.Season != r.subtitle.Season ||
+			s.Episode != r.subtitle.Episode {
+			continue
+		}
+		items = append(items, &subtitleResolver{subtitle: s, siblings: r.siblings, manager: r.manager})
+	}
+	if err := spendComplexity(ctx, len(items)); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+type commentResolver struct {
+	comment models.SubComments
+}
+
+func (r *commentResolver) Id() int32         { return int32(r.comment.Id) }
+func (r *commentResolver) Text() string      { return r.comment.Comment }
+func (r *commentResolver) Author() string    { return r.comment.Nick }
+func (r *commentResolver) CreatedAt() string { return r.comment.Date }
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func derefInt(i *int32) int32 {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+func derefBool(b *bool) bool {
+	return b != nil && *b
+}
+
+func optional(v int) *int32 {
+	if v == 0 {
+		return nil
+	}
+	i := int32(v)
+	return &i
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func head(items []models.Subtitle, n int) []models.Subtitle {
+	if n < 0 {
+		n = 0
+	}
+	if len(items) > n {
+		return items[:n]
+	}
+	return items
+}
@@ -0,0 +1,85 @@
+package webserver
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graph-gophers/graphql-go"
+	gqlotel "github.com/graph-gophers/graphql-go/trace/otel"
+	"go.opentelemetry.io/otel"
+)
+
+//go:embed schema.graphql
+var graphqlSchema string
+
+const (
+	// graphqlSearchCost is what a search spends of the query complexity, as
+	// it reaches every provider. Each subtitle resolved spends 1.
+	graphqlSearchCost = 10
+	// graphqlMaxAlternatives caps alternatives(first:).
+	graphqlMaxAlternatives = 20
+)
+
+var errQueryTooComplex = errors.New("query exceeds the complexity limit")
+
+type graphqlBudgetKey struct{}
+
+// graphqlBudget is the complexity a query has left. Fields spend it as they
+// resolve, before any work, so a search repeated under several aliases
+// pays for every copy.
+type graphqlBudget struct {
+	mu   sync.Mutex
+	max  int
+	left int
+}
+
+// spendComplexity charges cost to the query running in ctx.
+func spendComplexity(ctx context.Context, cost int) error {
+	budget, ok := ctx.Value(graphqlBudgetKey{}).(*graphqlBudget)
+	if !ok {
+		return nil
+	}
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	if cost > budget.left {
+		budget.left = 0
+		return fmt.Errorf("%w of %d", errQueryTooComplex, budget.max)
+	}
+	budget.left -= cost
+	return nil
+}
+
+type graphqlRequest struct {
+	Query         string         `json:"query" binding:"required"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+func (w *WebServer) loadGraphql() (*graphql.Schema, error) {
+	return graphql.ParseSchema(graphqlSchema,
+		&queryResolver{manager: w.manager, maxResults: w.config.GraphqlMaxResults},
+		graphql.MaxDepth(w.config.GraphqlMaxDepth),
+		graphql.MaxQueryLength(w.config.GraphqlMaxQueryLength),
+		graphql.MaxParallelism(10),
+		graphql.Tracer(&gqlotel.Tracer{Tracer: otel.Tracer(w.config.ServiceName)}),
+	)
+}
+
+func (w *WebServer) GraphqlHandler(c *gin.Context) {
+	var req graphqlRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, &gin.H{"errors": []gin.H{{"message": err.Error()}}})
+		return
+	}
+	ctx := c.Request.Context()
+	if limit := w.config.GraphqlMaxComplexity; limit > 0 {
+		ctx = context.WithValue(ctx, graphqlBudgetKey{}, &graphqlBudget{max: limit, left: limit})
+	}
+	res := w.graphql.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	c.JSON(http.StatusOK, res)
+}
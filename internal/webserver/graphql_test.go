@@ -0,0 +1,94 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+type graphqlResponse struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (w *WebServer) graphqlQuery(t *testing.T, query string) graphqlResponse {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	res := w.serve(req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", res.Code, res.Body)
+	}
+	var out graphqlResponse
+	if err := json.Unmarshal(res.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func (r graphqlResponse) failedWith(message string) bool {
+	for _, err := range r.Errors {
+		if strings.Contains(err.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGraphqlLimits(t *testing.T) {
+	manager := &fakeManager{subtitles: []models.Subtitle{
+		{Provider: "podnapisi", ExternalId: "a", Title: "The Matrix", Language: "en"},
+		{Provider: "podnapisi", ExternalId: "b", Title: "The Matrix", Language: "es"},
+	}}
+	srv := newTestServer(t, manager, map[string]string{"SA_GRAPHQL_MAX_COMPLEXITY": "25", "SA_GRAPHQL_MAX_DEPTH": "4"})
+
+	tests := []struct {
+		name     string
+		query    string
+		wantData string
+		wantErr  string
+	}{
+		{
+			name:     "within the limits",
+			query:    `{ search(request: {term: "the matrix"}) { total subtitles { uid language } } }`,
+			wantData: "search",
+		},
+		{
+			name:     "a subtitle by uid",
+			query:    `{ subtitle(uid: "podnapisi:b") { uid language } }`,
+			wantData: "subtitle",
+		},
+		{
+			// 10 per search and 2 per subtitle list, the third copy is over
+			name:    "aliased searches pay for every copy",
+			query:   `{ a: search(request: {term: "x"}) { subtitles { uid } } b: search(request: {term: "y"}) { subtitles { uid } } c: search(request: {term: "z"}) { total } }`,
+			wantErr: errQueryTooComplex.Error(),
+		},
+		{
+			name:    "nesting past the depth",
+			query:   `{ search(request: {term: "x"}) { subtitles { alternatives { alternatives { uid } } } } }`,
+			wantErr: "exceeds max depth",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := srv.graphqlQuery(t, tt.query)
+			if tt.wantErr == "" {
+				if len(res.Errors) > 0 || res.Data[tt.wantData] == nil {
+					t.Fatalf("query = %+v, errors %+v, want %s", res.Data, res.Errors, tt.wantData)
+				}
+				return
+			}
+			if !res.failedWith(tt.wantErr) {
+				t.Fatalf("errors = %+v, want %q", res.Errors, tt.wantErr)
+			}
+		})
+	}
+}
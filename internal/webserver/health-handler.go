@@ -0,0 +1,23 @@
+package webserver
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthzHandler answers the liveness probe; it only reports that the
+// process is serving requests.
+func (w *WebServer) HealthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, &gin.H{"status": "ok"})
+}
+
+// ReadyzHandler answers the readiness probe from the cached provider probes.
+func (w *WebServer) ReadyzHandler(c *gin.Context) {
+	readiness := w.manager.Readiness()
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, readiness)
+}
@@ -0,0 +1,51 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+// unreadyManager reports too few healthy providers.
+type unreadyManager struct {
+	fakeManager
+}
+
+func (m *unreadyManager) Readiness() models.Readiness {
+	return models.Readiness{Ready: false, Healthy: 0, Required: 1, Providers: []models.ProviderStatus{{Name: "podnapisi", Error: "unexpected status 502"}}}
+}
+
+func TestHealthProbes(t *testing.T) {
+	tests := []struct {
+		name       string
+		manager    Manager
+		path       string
+		wantStatus int
+	}{
+		{"liveness", &unreadyManager{}, "/healthz", http.StatusOK},
+		{"ready", &fakeManager{}, "/readyz", http.StatusOK},
+		{"not ready", &unreadyManager{}, "/readyz", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the probes answer without an api key
+			srv := newTestServer(t, tt.manager, map[string]string{"SA_API_KEYS": "tests:s3cret"})
+			res := srv.serve(httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if res.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", res.Code, tt.wantStatus, res.Body)
+			}
+		})
+	}
+
+	res := newTestServer(t, &unreadyManager{}, nil).serve(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var readiness models.Readiness
+	if err := json.Unmarshal(res.Body.Bytes(), &readiness); err != nil {
+		t.Fatal(err)
+	}
+	if len(readiness.Providers) != 1 || readiness.Providers[0].Error != "unexpected status 502" {
+		t.Errorf("readiness = %s, want the provider errors", res.Body)
+	}
+}
@@ -0,0 +1,252 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/xochilpili/subtitler-api/internal/config"
+)
+
+// jwtLeeway is the clock skew tolerated on exp, nbf and iat.
+const jwtLeeway = 30 * time.Second
+
+var jwtAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+var (
+	errUnknownSigningKey  = errors.New("token signed by an unknown key")
+	errTokenWithoutExpiry = errors.New("token has no expiry")
+	errTokenWithoutClient = errors.New("token names no client")
+)
+
+// jwtAuth validates bearer tokens issued by an OIDC provider and turns
+// their claims into a client id and a set of scopes.
+type jwtAuth struct {
+	keys        *jwks
+	issuer      string
+	audience    string
+	scopeClaim  string
+	clientClaim string
+	scopeMap    map[string]string
+
+	// clients are created on first sight with the SA_API_KEY_* limits.
+	mu             sync.Mutex
+	clients        map[string]*apiClient
+	perSecond      float64
+	burst          int
+	dailyDownloads int
+}
+
+// newJwtAuth returns nil when SA_JWT_JWKS is not set. Otherwise the issuer
+// and audience must be set too, or any token the keys signed, for any
+// service, would be accepted.
+func newJwtAuth(ctx context.Context, config *config.Config) (*jwtAuth, error) {
+	if config.JwtJwks == "" {
+		return nil, nil
+	}
+	if config.JwtIssuer == "" || config.JwtAudience == "" {
+		return nil, errors.New("SA_JWT_ISSUER and SA_JWT_AUDIENCE are required with SA_JWT_JWKS")
+	}
+	keys := &jwks{
+		source:  config.JwtJwks,
+		refresh: config.JwtJwksRefresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+	if err := keys.load(ctx); err != nil {
+		return nil, fmt.Errorf("error while loading jwks from %s: %w", config.JwtJwks, err)
+	}
+	return &jwtAuth{
+		keys:           keys,
+		issuer:         config.JwtIssuer,
+		audience:       config.JwtAudience,
+		scopeClaim:     config.JwtScopeClaim,
+		clientClaim:    config.JwtClientClaim,
+		scopeMap:       config.JwtScopeMap,
+		clients:        map[string]*apiClient{},
+		perSecond:      config.ApiKeyRate,
+		burst:          config.ApiKeyBurst,
+		dailyDownloads: config.ApiKeyDailyDownloads,
+	}, nil
+}
+
+// verify checks the signature, issuer, audience and lifetime of a token
+// and returns the client it was issued to along with its scopes.
+func (a *jwtAuth) verify(ctx context.Context, raw string) (*apiClient, []string, error) {
+	token, err := jwt.ParseSigned(raw, jwtAlgorithms)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := a.keys.key(ctx, token.Headers[0].KeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var claims jwt.Claims
+	var extra map[string]any
+	public := key.Public()
+	if err := token.Claims(&public, &claims, &extra); err != nil {
+		return nil, nil, err
+	}
+	expected := jwt.Expected{Issuer: a.issuer, AnyAudience: jwt.Audience{a.audience}}
+	if err := claims.ValidateWithLeeway(expected, jwtLeeway); err != nil {
+		return nil, nil, err
+	}
+	if claims.Expiry == nil {
+		return nil, nil, errTokenWithoutExpiry
+	}
+
+	id, _ := extra[a.clientClaim].(string)
+	if id == "" {
+		id = claims.Subject
+	}
+	if id == "" {
+		return nil, nil, errTokenWithoutClient
+	}
+	return a.client(id), a.scopes(extra), nil
+}
+
+// scopes reads the scope claim, either a space separated string or a list,
+// and adds the API scopes the configured mapping derives from it. It never
+// returns nil, which would grant a token every scope like an API key.
+func (a *jwtAuth) scopes(claims map[string]any) []string {
+	scopes := []string{}
+	switch v := claims[a.scopeClaim].(type) {
+	case string:
+		scopes = strings.Fields(v)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	for _, s := range scopes {
+		if mapped, ok := a.scopeMap[s]; ok {
+			scopes = append(scopes, mapped)
+		}
+	}
+	return scopes
+}
+
+func (a *jwtAuth) client(id string) *apiClient {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	client, ok := a.clients[id]
+	if !ok {
+		client = newApiClient(id, a.perSecond, a.burst, a.dailyDownloads)
+		a.clients[id] = client
+	}
+	return client
+}
+
+// jwks is a key set read from a file or URL. URLs are fetched again once
+// refresh has passed, or when a token names a key that is not known yet
+// (at most once a minute), so rotated keys are picked up.
+type jwks struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+
+	mu          sync.Mutex
+	set         jose.JSONWebKeySet
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func (j *jwks) remote() bool {
+	return strings.HasPrefix(j.source, "http://") || strings.HasPrefix(j.source, "https://")
+}
+
+func (j *jwks) load(ctx context.Context) error {
+	var raw []byte
+	var err error
+	if j.remote() {
+		raw, err = j.fetch(ctx)
+	} else {
+		raw, err = os.ReadFile(j.source)
+	}
+	if err != nil {
+		return err
+	}
+	var set jose.JSONWebKeySet
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return err
+	}
+	if len(set.Keys) == 0 {
+		return errors.New("key set is empty")
+	}
+	j.mu.Lock()
+	j.set = set
+	j.fetchedAt = time.Now()
+	j.mu.Unlock()
+	return nil
+}
+
+func (j *jwks) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := j.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+}
+
+// key finds the public key a token was signed with. Tokens without a kid
+// are accepted when the set holds a single key.
+func (j *jwks) key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	j.mu.Lock()
+	key, found := j.lookup(kid)
+	stale := !found || (j.refresh > 0 && time.Since(j.fetchedAt) > j.refresh)
+	reload := j.remote() && stale && time.Since(j.attemptedAt) > time.Minute
+	if reload {
+		j.attemptedAt = time.Now()
+	}
+	j.mu.Unlock()
+
+	if reload {
+		// a failed refresh keeps serving the keys already known
+		if err := j.load(ctx); err == nil {
+			j.mu.Lock()
+			key, found = j.lookup(kid)
+			j.mu.Unlock()
+		}
+	}
+	if !found {
+		return nil, errUnknownSigningKey
+	}
+	return key, nil
+}
+
+func (j *jwks) lookup(kid string) (*jose.JSONWebKey, bool) {
+	if kid == "" {
+		if len(j.set.Keys) == 1 {
+			return &j.set.Keys[0], true
+		}
+		return nil, false
+	}
+	keys := j.set.Key(kid)
+	if len(keys) == 0 {
+		return nil, false
+	}
+	return &keys[0], true
+}
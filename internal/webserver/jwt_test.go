@@ -0,0 +1,273 @@
+package webserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/xochilpili/subtitler-api/internal/config"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com/"
+	testAudience = "subtitler-api"
+)
+
+// testKey is a locally generated signing key along with its public JWK.
+type testKey struct {
+	signer jose.Signer
+	public jose.JSONWebKey
+}
+
+func newTestKey(t *testing.T, kid string) *testKey {
+	t.Helper()
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := (&jose.SignerOptions{}).WithType("JWT")
+	if kid != "" {
+		options = options.WithHeader(jose.HeaderKey("kid"), kid)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: private}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKey{signer: signer, public: jose.JSONWebKey{Key: &private.PublicKey, KeyID: kid, Algorithm: string(jose.ES256), Use: "sig"}}
+}
+
+func (k *testKey) sign(t *testing.T, claims jwt.Claims, extra map[string]any) string {
+	t.Helper()
+	raw, err := jwt.Signed(k.signer).Claims(claims).Claims(extra).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func jwksOf(t *testing.T, keys ...*testKey) []byte {
+	t.Helper()
+	var set jose.JSONWebKeySet
+	for _, k := range keys {
+		set.Keys = append(set.Keys, k.public)
+	}
+	raw, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func testJwtConfig(jwks string) *config.Config {
+	return &config.Config{
+		JwtJwks:        jwks,
+		JwtJwksRefresh: time.Hour,
+		JwtIssuer:      testIssuer,
+		JwtAudience:    testAudience,
+		JwtScopeClaim:  "scope",
+		JwtClientClaim: "azp",
+		JwtScopeMap:    map[string]string{"subtitles.read": ScopeSearch},
+	}
+}
+
+func validClaims() jwt.Claims {
+	now := time.Now()
+	return jwt.Claims{
+		Issuer:   testIssuer,
+		Audience: jwt.Audience{testAudience},
+		Subject:  "user-1",
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+}
+
+func TestJwtVerify(t *testing.T) {
+	key := newTestKey(t, "main")
+	stranger := newTestKey(t, "stranger")
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksOf(t, key), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := newJwtAuth(context.Background(), testJwtConfig(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		key        *testKey
+		claims     func(*jwt.Claims)
+		extra      map[string]any
+		wantClient string
+		wantScopes []string
+		wantErr    error
+	}{
+		{
+			name:       "valid token with a client claim",
+			extra:      map[string]any{"azp": "kodi", "scope": "subtitles.read download"},
+			wantClient: "kodi",
+			wantScopes: []string{"subtitles.read", "download", ScopeSearch},
+		},
+		{
+			name:       "subject is the client without azp",
+			extra:      map[string]any{"scope": []any{"download"}},
+			wantClient: "user-1",
+			wantScopes: []string{"download"},
+		},
+		{
+			name:       "no scope claim grants no scope",
+			extra:      map[string]any{"azp": "kodi"},
+			wantClient: "kodi",
+			wantScopes: []string{},
+		},
+		{
+			name:       "malformed scope claim grants no scope",
+			extra:      map[string]any{"azp": "kodi", "scope": 42},
+			wantClient: "kodi",
+			wantScopes: []string{},
+		},
+		{
+			name:    "wrong issuer",
+			claims:  func(c *jwt.Claims) { c.Issuer = "https://elsewhere.example.com/" },
+			wantErr: jwt.ErrInvalidIssuer,
+		},
+		{
+			name:    "wrong audience",
+			claims:  func(c *jwt.Claims) { c.Audience = jwt.Audience{"another-api"} },
+			wantErr: jwt.ErrInvalidAudience,
+		},
+		{
+			name:    "expired",
+			claims:  func(c *jwt.Claims) { c.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Hour)) },
+			wantErr: jwt.ErrExpired,
+		},
+		{
+			name:    "not valid yet",
+			claims:  func(c *jwt.Claims) { c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour)) },
+			wantErr: jwt.ErrNotValidYet,
+		},
+		{
+			name:    "no expiry",
+			claims:  func(c *jwt.Claims) { c.Expiry = nil },
+			wantErr: errTokenWithoutExpiry,
+		},
+		{
+			name:    "no client",
+			claims:  func(c *jwt.Claims) { c.Subject = "" },
+			wantErr: errTokenWithoutClient,
+		},
+		{
+			name:    "signed by an unknown key",
+			key:     stranger,
+			wantErr: errUnknownSigningKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			if tt.claims != nil {
+				tt.claims(&claims)
+			}
+			signer := key
+			if tt.key != nil {
+				signer = tt.key
+			}
+			client, scopes, err := auth.verify(context.Background(), signer.sign(t, claims, tt.extra))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if client.id != tt.wantClient {
+				t.Errorf("verify() client = %q, want %q", client.id, tt.wantClient)
+			}
+			// a nil slice would grant every scope, as for API keys
+			if scopes == nil || !slices.Equal(scopes, tt.wantScopes) {
+				t.Errorf("verify() scopes = %v, want %v", scopes, tt.wantScopes)
+			}
+		})
+	}
+}
+
+func TestJwtVerifyForgedSignature(t *testing.T) {
+	key := newTestKey(t, "main")
+	// same kid as the published key, signed with another private key
+	forger := newTestKey(t, "main")
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksOf(t, key), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := newJwtAuth(context.Background(), testJwtConfig(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := auth.verify(context.Background(), forger.sign(t, validClaims(), nil)); err == nil {
+		t.Fatal("verify() accepted a token with a forged signature")
+	}
+}
+
+func TestJwtRotatedKeys(t *testing.T) {
+	old, rotated := newTestKey(t, "old"), newTestKey(t, "rotated")
+	published := jwksOf(t, old)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(published)
+	}))
+	defer server.Close()
+
+	auth, err := newJwtAuth(context.Background(), testJwtConfig(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := auth.verify(context.Background(), old.sign(t, validClaims(), nil)); err != nil {
+		t.Fatalf("verify() with the published key: %v", err)
+	}
+	published = jwksOf(t, old, rotated)
+	if _, _, err := auth.verify(context.Background(), rotated.sign(t, validClaims(), nil)); err != nil {
+		t.Fatalf("verify() after the key was rotated: %v", err)
+	}
+}
+
+func TestNewJwtAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksOf(t, newTestKey(t, "")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		config  func(*config.Config)
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "disabled without a jwks", config: func(c *config.Config) { c.JwtJwks = "" }, wantNil: true},
+		{name: "issuer is required", config: func(c *config.Config) { c.JwtIssuer = "" }, wantErr: true},
+		{name: "audience is required", config: func(c *config.Config) { c.JwtAudience = "" }, wantErr: true},
+		{name: "missing jwks file", config: func(c *config.Config) { c.JwtJwks = path + ".missing" }, wantErr: true},
+		{name: "configured", config: func(c *config.Config) {}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testJwtConfig(path)
+			tt.config(cfg)
+			auth, err := newJwtAuth(context.Background(), cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newJwtAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (auth == nil) != tt.wantNil {
+				t.Fatalf("newJwtAuth() = %v, want nil %v", auth, tt.wantNil)
+			}
+		})
+	}
+}
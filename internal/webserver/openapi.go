@@ -0,0 +1,103 @@
+package webserver
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xochilpili/subtitler-api/internal/version"
+)
+
+//go:embed openapi.json
+var openApiSpec []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+// docsAssets is the vendored Swagger UI, so /docs works without a CDN.
+//
+//go:embed swagger-ui/swagger-ui-bundle.js swagger-ui/swagger-ui.css
+var docsAssets embed.FS
+
+var ginParamRe = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+type openApiDocument struct {
+	body  []byte
+	paths map[string][]string
+}
+
+// loadOpenApi parses the embedded spec and stamps it with the running version.
+func loadOpenApi() (*openApiDocument, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(openApiSpec, &raw); err != nil {
+		return nil, err
+	}
+	if info, ok := raw["info"].(map[string]any); ok {
+		info["version"] = strings.TrimPrefix(version.VERSION, "v")
+	}
+	body, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	paths := map[string][]string{}
+	if p, ok := raw["paths"].(map[string]any); ok {
+		for path, item := range p {
+			ops, _ := item.(map[string]any)
+			for method := range ops {
+				paths[path] = append(paths[path], strings.ToUpper(method))
+			}
+		}
+	}
+	return &openApiDocument{body: body, paths: paths}, nil
+}
+
+// diffRoutes returns the gin routes missing from the spec and the spec
+// operations without a matching gin route, both as "METHOD /path". The
+// tests keep loadRoutes and openapi.json in step with it.
+func (d *openApiDocument) diffRoutes(routes gin.RoutesInfo) (undocumented []string, unrouted []string) {
+	registered := map[string]bool{}
+	for _, r := range routes {
+		path := ginParamRe.ReplaceAllString(r.Path, "{$1}")
+		key := r.Method + " " + path
+		registered[key] = true
+		found := false
+		for _, m := range d.paths[path] {
+			if m == r.Method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			undocumented = append(undocumented, key)
+		}
+	}
+	for path, methods := range d.paths {
+		for _, m := range methods {
+			if !registered[m+" "+path] {
+				unrouted = append(unrouted, m+" "+path)
+			}
+		}
+	}
+	sort.Strings(undocumented)
+	sort.Strings(unrouted)
+	return
+}
+
+func (w *WebServer) OpenApiHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", w.openapi.body)
+}
+
+func (w *WebServer) DocsHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
+
+func (w *WebServer) DocsAssetHandler(c *gin.Context) {
+	assets, _ := fs.Sub(docsAssets, "swagger-ui")
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.FileFromFS(c.Param("asset"), http.FS(assets))
+}
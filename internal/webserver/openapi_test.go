@@ -0,0 +1,46 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenApiMatchesRoutes(t *testing.T) {
+	srv := newTestServer(t, &fakeManager{}, nil)
+	undocumented, unrouted := srv.openapi.diffRoutes(srv.ginger.Routes())
+	if len(undocumented) > 0 {
+		t.Errorf("routes missing from openapi.json: %v", undocumented)
+	}
+	if len(unrouted) > 0 {
+		t.Errorf("openapi.json operations without a route: %v", unrouted)
+	}
+
+	// the check does see drift either way
+	routes := srv.ginger.Routes()
+	dropped := routes[0]
+	routes = append(routes[1:], gin.RouteInfo{Method: http.MethodDelete, Path: "/v2/download/:provider/:subtitleId"})
+	undocumented, unrouted = srv.openapi.diffRoutes(routes)
+	if !slices.Equal(undocumented, []string{"DELETE /v2/download/{provider}/{subtitleId}"}) {
+		t.Errorf("undocumented = %v, want the added route", undocumented)
+	}
+	if len(unrouted) != 1 || unrouted[0] != dropped.Method+" "+ginParamRe.ReplaceAllString(dropped.Path, "{$1}") {
+		t.Errorf("unrouted = %v, want %s %s", unrouted, dropped.Method, dropped.Path)
+	}
+}
+
+func TestOpenApiHandler(t *testing.T) {
+	srv := newTestServer(t, &fakeManager{}, nil)
+	res := srv.serve(httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if res.Code != http.StatusOK || res.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("status = %d, Content-Type %q", res.Code, res.Header().Get("Content-Type"))
+	}
+	for _, asset := range []string{"/docs", "/docs/swagger-ui-bundle.js"} {
+		if res := srv.serve(httptest.NewRequest(http.MethodGet, asset, nil)); res.Code != http.StatusOK {
+			t.Errorf("GET %s status = %d, want 200", asset, res.Code)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package webserver
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+func (w *WebServer) ProvidersHandler(c *gin.Context) {
+	providers := w.manager.Providers()
+	w.respondList(c, len(providers), providers)
+}
@@ -0,0 +1,178 @@
+package webserver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"github.com/xochilpili/subtitler-api/internal/providers"
+)
+
+type SearchQuery struct {
+	Term       string `form:"term" json:"term,omitempty" binding:"required"`
+	Year       int    `form:"year" json:"year,omitempty" binding:"omitempty,min=1900,max=2100"`
+	Group      string `form:"group" json:"group,omitempty" binding:"omitempty,max=64"`
+	Quality    string `form:"quality" json:"quality,omitempty" binding:"omitempty,max=64"`
+	Resolution string `form:"resolution" json:"resolution,omitempty" binding:"omitempty,max=16"`
+	Season     int    `form:"season" json:"season,omitempty" binding:"omitempty,min=0,max=100"`
+	Episode    int    `form:"episode" json:"episode,omitempty" binding:"omitempty,min=0,max=10000"`
+	Language   string `form:"language" json:"language,omitempty" binding:"omitempty,max=32"`
+
+	IncludeMachineTranslated bool `form:"include_machine_translated" json:"include_machine_translated,omitempty"`
+
+	MinDownloads      int     `form:"min_downloads" json:"min_downloads,omitempty" binding:"omitempty,min=0"`
+	MinRating         float64 `form:"min_rating" json:"min_rating,omitempty" binding:"omitempty,min=0,max=10"`
+	HearingImpaired   *bool   `form:"hearing_impaired" json:"hearing_impaired,omitempty"`
+	MachineTranslated *bool   `form:"machine_translated" json:"machine_translated,omitempty"`
+	Trusted           *bool   `form:"trusted" json:"trusted,omitempty"`
+	Sort              string  `form:"sort" json:"sort,omitempty" binding:"omitempty,oneof=downloads rating uploaded_at fps cds"`
+	Order             string  `form:"order" json:"order,omitempty" binding:"omitempty,oneof=asc desc"`
+
+	RateLimitQuery
+}
+
+// RateLimitQuery chooses what happens when a provider is over its rate
+// limit: wait for a slot (default) or fail straight away.
+type RateLimitQuery struct {
+	RateLimit string `form:"rate_limit" json:"rate_limit,omitempty" binding:"omitempty,oneof=wait fail"`
+}
+
+// SearchBatchItem is one search of a batch; Id is an optional reference
+// echoed back in its result.
+type SearchBatchItem struct {
+	Id       string `json:"id,omitempty" binding:"max=128"`
+	Provider string `json:"provider,omitempty"`
+	SearchQuery
+}
+
+type SearchBatchRequest struct {
+	Items []SearchBatchItem `json:"items" binding:"required,min=1"`
+}
+
+// DownloadBatchItem is one download of a batch; Name overrides the
+// filename it gets in the archive.
+type DownloadBatchItem struct {
+	Provider string `json:"provider" binding:"required"`
+	Id       string `json:"id" binding:"required"`
+	Name     string `json:"name,omitempty" binding:"max=200"`
+}
+
+// DownloadBatchRequest asks for archives to be unpacked when Extract is set
+// and for subtitles to be converted to Format, which implies Extract.
+type DownloadBatchRequest struct {
+	Items   []DownloadBatchItem `json:"items" binding:"required,min=1,dive"`
+	Extract bool                `json:"extract,omitempty"`
+	Format  string              `json:"format,omitempty" binding:"omitempty,oneof=srt vtt"`
+
+	RateLimitQuery
+}
+
+// BestQuery describes the video to find a subtitle for, as a release name,
+// a search term or both, and the languages accepted in order of preference.
+type BestQuery struct {
+	Release  string `form:"release" binding:"required_without=Term,max=256"`
+	Term     string `form:"term" binding:"max=256"`
+	Year     int    `form:"year" binding:"omitempty,min=1900,max=2100"`
+	Season   int    `form:"season" binding:"omitempty,min=0,max=100"`
+	Episode  int    `form:"episode" binding:"omitempty,min=0,max=10000"`
+	Language string `form:"language" binding:"omitempty,max=64"`
+	Format   string `form:"format" binding:"omitempty,oneof=srt vtt"`
+
+	HearingImpaired          *bool `form:"hearing_impaired"`
+	IncludeMachineTranslated bool  `form:"include_machine_translated"`
+
+	RateLimitQuery
+}
+
+type ProviderUri struct {
+	Provider string `uri:"provider" binding:"required"`
+}
+
+type DownloadUri struct {
+	Provider   string `uri:"provider" binding:"required"`
+	SubtitleId string `uri:"subtitleId" binding:"required"`
+}
+
+type SubtitleUri struct {
+	Provider string `uri:"provider" binding:"required"`
+	Id       string `uri:"id" binding:"required"`
+}
+
+func (q *RateLimitQuery) Context(ctx context.Context) context.Context {
+	if q.RateLimit == "fail" {
+		return providers.FailFast(ctx)
+	}
+	return ctx
+}
+
+func (q *SearchQuery) Request() *models.SearchRequest {
+	return &models.SearchRequest{
+		Term:     q.Term,
+		Year:     q.Year,
+		Season:   q.Season,
+		Episode:  q.Episode,
+		Language: q.Language,
+
+		MachineTranslated: q.IncludeMachineTranslated || (q.MachineTranslated != nil && *q.MachineTranslated),
+	}
+}
+
+func (q *SearchQuery) PostFilters() *models.PostFilters {
+	return &models.PostFilters{
+		Year:       q.Year,
+		Group:      q.Group,
+		Quality:    q.Quality,
+		Resolution: q.Resolution,
+
+		MinDownloads:      q.MinDownloads,
+		MinRating:         q.MinRating,
+		HearingImpaired:   q.HearingImpaired,
+		MachineTranslated: q.MachineTranslated,
+		Trusted:           q.Trusted,
+		Sort:              q.Sort,
+		Order:             q.Order,
+	}
+}
+
+// Target merges the release name with the fields given explicitly, which
+// take precedence.
+func (q *BestQuery) Target() *providers.Release {
+	release := providers.ParseRelease(q.Release)
+	if q.Term != "" {
+		release.Title = q.Term
+	}
+	if q.Year > 0 {
+		release.Year = q.Year
+	}
+	if q.Season > 0 || q.Episode > 0 {
+		release.Season, release.Episode = q.Season, q.Episode
+	}
+	return release
+}
+
+// Languages splits the comma separated language preference.
+func (q *BestQuery) Languages() []string {
+	var languages []string
+	for _, language := range strings.Split(q.Language, ",") {
+		if language = strings.TrimSpace(language); language != "" {
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
+
+// Request searches every language unless a single one is preferred, since
+// providers only filter on one.
+func (q *BestQuery) Request(target *providers.Release) *models.SearchRequest {
+	req := &models.SearchRequest{
+		Term:              target.Title,
+		Year:              target.Year,
+		Season:            target.Season,
+		Episode:           target.Episode,
+		MachineTranslated: q.IncludeMachineTranslated,
+	}
+	if languages := q.Languages(); len(languages) == 1 {
+		req.Language = languages[0]
+	}
+	return req
+}
@@ -1,14 +1,9 @@
 package webserver
 
 import (
-	"errors"
-	"fmt"
-	"io"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/xochilpili/subtitler-api/internal/models"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 )
@@ -19,90 +14,40 @@ func (w *WebServer) SearchByProvider(c *gin.Context) {
 	ctx, span := tracer.Start(ctx, "Search by provider")
 	defer span.End()
 
-	provider := c.Param("provider")
-	if provider == "" {
-		span.RecordError(errors.New("missing provider"))
+	var uri ProviderUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		span.RecordError(err)
 		span.SetStatus(http.StatusBadRequest, "missing provider")
-		c.JSON(http.StatusBadRequest, &gin.H{"message": "error", "error": "bad request"})
+		w.respondError(c, http.StatusBadRequest, err)
 		return
 	}
-	query := c.Query("term")
-	if query == "" {
-		span.RecordError(errors.New("missing query term"))
-		span.SetStatus(http.StatusBadRequest, "missing query term")
-		c.JSON(http.StatusBadRequest, &gin.H{"message": "error", "error": "bad request"})
+	var query SearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		span.RecordError(err)
+		span.SetStatus(http.StatusBadRequest, "invalid query")
+		w.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	ctxSearch, searchSpan := tracer.Start(ctx, "Searching")
-	subtitles := w.manager.Search(ctxSearch, provider, query, getPostFilters(c))
+	subtitles, skipped := w.manager.Search(query.Context(ctxSearch), uri.Provider, query.Request(), query.PostFilters())
 	searchSpan.End()
 
 	span.SetAttributes(
-		attribute.String("provider", provider),
-		attribute.String("query", query),
+		attribute.String("provider", uri.Provider),
+		attribute.String("query", query.Term),
 		attribute.Int("total_result", len(subtitles)),
 	)
 
-	c.JSON(http.StatusOK, &gin.H{"message": "ok", "total": len(subtitles), "data": subtitles})
+	w.respondSearch(c, subtitles, skipped)
 }
 
 func (w *WebServer) SearchAll(c *gin.Context) {
-	query := c.Query("term")
-	if query == "" {
-		c.JSON(http.StatusBadRequest, &gin.H{"mesasge": "error", "error": "bad request"})
+	var query SearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		w.respondError(c, http.StatusBadRequest, err)
 		return
 	}
-	subtitles := w.manager.Search(c.Request.Context(), "", query, getPostFilters(c))
-	c.JSON(http.StatusOK, &gin.H{"message": "ok", "total": len(subtitles), "data": subtitles})
-}
-
-func (w *WebServer) Download(c *gin.Context) {
-	provider := c.Param("provider")
-	if provider == "" {
-		c.JSON(http.StatusBadRequest, &gin.H{"message": "error", "error": "bad request"})
-		return
-	}
-	subtitleId := c.Param("subtitleId")
-	if subtitleId == "" {
-		c.JSON(http.StatusBadRequest, &gin.H{"message": "error", "error": "bad request"})
-		return
-	}
-	w.logger.Info().Msgf("downloading subtitle: %s", subtitleId)
-	body, filename, contentType, err := w.manager.Download(c.Request.Context(), provider, subtitleId)
-	if err != nil {
-		c.JSON(http.StatusBadGateway, &gin.H{"message": "error", "error": err.Error()})
-		return
-	}
-
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.Header("Content-Type", contentType)
-	_, err = io.Copy(c.Writer, body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, &gin.H{"message": "error", "error": err.Error()})
-		return
-	}
-	//c.JSON(http.StatusOK, &gin.H{"message": "ok"})
-}
-
-func getPostFilters(c *gin.Context) *models.PostFilters {
-	postFilter := &models.PostFilters{}
-	year := c.Query("year")
-	if year != "" {
-		y, _ := strconv.Atoi(year)
-		postFilter.Year = y
-	}
-	group := c.Query("group")
-	if group != "" {
-		postFilter.Group = group
-	}
-	quality := c.Query("quality")
-	if quality != "" {
-		postFilter.Quality = quality
-	}
-	res := c.Query("resolution")
-	if res != "" {
-		postFilter.Resolution = res
-	}
-	return postFilter
+	subtitles, skipped := w.manager.Search(query.Context(c.Request.Context()), "", query.Request(), query.PostFilters())
+	w.respondSearch(c, subtitles, skipped)
 }
@@ -2,16 +2,15 @@ package webserver
 
 import (
 	"context"
-	"io"
 	"net/http"
 	"time"
 
 	ginlogger "github.com/gin-contrib/logger"
 	"github.com/gin-gonic/gin"
+	"github.com/graph-gophers/graphql-go"
 	"github.com/rs/zerolog"
 	"github.com/xochilpili/subtitler-api/internal/config"
 	"github.com/xochilpili/subtitler-api/internal/models"
-	"github.com/xochilpili/subtitler-api/internal/providers"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -19,8 +18,12 @@ import (
 )
 
 type Manager interface {
-	Search(ctx context.Context, provider string, query string, filters *models.PostFilters) []models.Subtitle
-	Download(ctx context.Context, provider string, subtitleId string) (io.ReadCloser, string, string, error)
+	Search(ctx context.Context, provider string, req *models.SearchRequest, filters *models.PostFilters) ([]models.Subtitle, []models.SkippedProvider)
+	Download(ctx context.Context, provider string, subtitleId string) (*models.SubtitleFile, error)
+	Providers() []models.ProviderInfo
+	Subtitle(uid string) (*models.Subtitle, []models.Subtitle, bool)
+	Details(ctx context.Context, provider string, subtitleId string) ([]models.SubComments, error)
+	Readiness() models.Readiness
 }
 
 type WebServer struct {
@@ -29,13 +32,18 @@ type WebServer struct {
 	Web     *http.Server
 	ginger  *gin.Engine
 	manager Manager
+	openapi *openApiDocument
+	legacy  *legacyPolicy
+	auth    *Auth
+	graphql *graphql.Schema
+	etags   *etagCache
 
 	// metrics instruments
 	reqCounter   otelmetric.Int64Counter
 	durHistogram otelmetric.Float64Histogram
 }
 
-func New(config *config.Config, logger *zerolog.Logger) *WebServer {
+func New(config *config.Config, logger *zerolog.Logger, manager Manager) *WebServer {
 	ginger := gin.New()
 	ginger.Use(gin.Recovery())
 
@@ -49,7 +57,12 @@ func New(config *config.Config, logger *zerolog.Logger) *WebServer {
 	ginger.Use(ginlogger.SetLogger(
 		ginlogger.WithSkipPath([]string{"/ping"}),
 		ginlogger.WithLogger(func(ctx *gin.Context, l zerolog.Logger) zerolog.Logger {
-			return logger.Output(gin.DefaultWriter).With().Logger()
+			// the client id is only known once authentication has run
+			return logger.Output(gin.DefaultWriter).With().Logger().Hook(zerolog.HookFunc(func(e *zerolog.Event, _ zerolog.Level, _ string) {
+				if id := ctx.GetString(clientIdKey); id != "" {
+					e.Str("client_id", id)
+				}
+			}))
 		}),
 	))
 
@@ -79,6 +92,9 @@ func New(config *config.Config, logger *zerolog.Logger) *WebServer {
 			attribute.String("http.method", c.Request.Method),
 			attribute.Int("http.status_code", c.Writer.Status()),
 		}
+		if id := c.GetString(clientIdKey); id != "" {
+			attrs = append(attrs, attribute.String("client.id", id))
+		}
 		ctx := c.Request.Context()
 		reqCounter.Add(ctx, 1, otelmetric.WithAttributes(attrs...))
 		durHistogram.Record(ctx, dur, otelmetric.WithAttributes(attrs...))
@@ -89,16 +105,52 @@ func New(config *config.Config, logger *zerolog.Logger) *WebServer {
 		Handler: ginger,
 	}
 
-	manager := providers.New(config, logger)
+	openapi, err := loadOpenApi()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error while loading openapi specification")
+	}
+
+	legacy, err := newLegacyPolicy(config.LegacyDeprecatedAt, config.LegacySunsetAt)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error while loading legacy routes policy")
+	}
+
+	keys, err := newApiKeys(config)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error while loading api keys")
+	}
+	jwt, err := newJwtAuth(context.Background(), config)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error while loading jwt authentication")
+	}
+	if !keys.enabled() && jwt == nil {
+		if config.AuthRequired {
+			logger.Fatal().Msg("authentication is required but no api keys or jwks are configured")
+		}
+		logger.Warn().Msg("no api keys or jwks configured, the api is open to anyone")
+	}
+	authRejections, _ := meter.Int64Counter(
+		"http.server.client.rejections",
+		otelmetric.WithDescription("Number of requests refused by authentication, client rate limits or quotas"),
+	)
+
 	srv := &WebServer{
 		config:       config,
 		logger:       logger,
 		Web:          httpSrv,
 		ginger:       ginger,
 		manager:      manager,
+		openapi:      openapi,
+		legacy:       legacy,
+		auth:         &Auth{keys: keys, jwt: jwt, rejections: authRejections},
+		etags:        newETagCache(config.SearchCacheTtl, config.SearchCacheSize),
 		reqCounter:   reqCounter,
 		durHistogram: durHistogram,
 	}
+	srv.graphql, err = srv.loadGraphql()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("error while loading graphql schema")
+	}
 	srv.loadRoutes()
 	return srv
 }
@@ -106,14 +158,45 @@ func New(config *config.Config, logger *zerolog.Logger) *WebServer {
 func (w *WebServer) loadRoutes() {
 	api := w.ginger.Group("/")
 	api.GET("/ping", w.PingHandler)
-	search := w.ginger.Group("/search")
+	api.GET("/healthz", w.HealthzHandler)
+	api.GET("/readyz", w.ReadyzHandler)
+	api.GET("/openapi.json", w.OpenApiHandler)
+	api.GET("/docs", w.DocsHandler)
+	api.GET("/docs/:asset", w.DocsAssetHandler)
+	api.POST("/graphql", w.authenticate(), w.requireScope(ScopeSearch), w.GraphqlHandler)
+
+	w.loadApiRoutes(w.ginger.Group("/"+apiV1, withApiVersion(apiV1), w.authenticate()))
+	w.loadApiRoutes(w.ginger.Group("/"+apiV2, withApiVersion(apiV2), w.authenticate()))
+
+	// Unversioned aliases of /v1, kept until the sunset date.
+	legacy := w.ginger.Group("/", withApiVersion(apiV1), w.legacy.middleware(), w.authenticate())
+	legacySearch := legacy.Group("/search", w.requireScope(ScopeSearch))
+	{
+		legacySearch.GET("/all/", w.SearchAll)
+		legacySearch.GET("/:provider/", w.SearchByProvider)
+	}
+	legacyDownload := legacy.Group("/download", w.requireScope(ScopeDownload), w.downloadQuota())
+	{
+		legacyDownload.GET("/:provider/:subtitleId", w.Download)
+	}
+}
+
+func (w *WebServer) loadApiRoutes(api *gin.RouterGroup) {
+	search := api.Group("/search", w.requireScope(ScopeSearch))
 	{
 		// TODO: Add WhisperPath
 		search.GET("/all/", w.SearchAll)
 		search.GET("/:provider/", w.SearchByProvider)
+		search.POST("/batch", w.SearchBatch)
 	}
-	download := w.ginger.Group("/download")
+	download := api.Group("/download", w.requireScope(ScopeDownload), w.downloadQuota())
 	{
 		download.GET("/:provider/:subtitleId", w.Download)
 	}
+	// best charges the download quota per candidate it tries
+	api.GET("/best", w.requireScope(ScopeDownload), w.Best)
+	// the batch takes the download quota per item rather than per request
+	api.POST("/download/batch", w.requireScope(ScopeDownload), w.DownloadBatch)
+	api.GET("/subtitle/:provider/:id/details", w.requireScope(ScopeSearch), w.DetailsHandler)
+	api.GET("/providers", w.ProvidersHandler)
 }
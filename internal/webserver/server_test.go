@@ -0,0 +1,128 @@
+package webserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/xochilpili/subtitler-api/internal/config"
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"github.com/xochilpili/subtitler-api/internal/providers"
+)
+
+const testSrt = "1\n00:00:01,000 --> 00:00:02,000\nHola\n"
+
+// fakeManager stands in for the providers behind the handlers. Downloads
+// serve testSrt unless download is set.
+type fakeManager struct {
+	subtitles []models.Subtitle
+	download  func(provider string, id string) (*models.SubtitleFile, error)
+
+	mu        sync.Mutex
+	downloads []string
+}
+
+func (m *fakeManager) Search(ctx context.Context, provider string, req *models.SearchRequest, filters *models.PostFilters) ([]models.Subtitle, []models.SkippedProvider) {
+	var subtitles []models.Subtitle
+	for _, s := range m.subtitles {
+		if provider == "" || s.Provider == provider {
+			subtitles = append(subtitles, s)
+		}
+	}
+	return subtitles, nil
+}
+
+func (m *fakeManager) Download(ctx context.Context, provider string, subtitleId string) (*models.SubtitleFile, error) {
+	m.mu.Lock()
+	m.downloads = append(m.downloads, provider+":"+subtitleId)
+	m.mu.Unlock()
+	if m.download != nil {
+		return m.download(provider, subtitleId)
+	}
+	return textFile(subtitleId+".srt", testSrt), nil
+}
+
+func (m *fakeManager) Providers() []models.ProviderInfo {
+	return []models.ProviderInfo{{Name: "podnapisi", Enabled: true}}
+}
+
+func (m *fakeManager) Subtitle(uid string) (*models.Subtitle, []models.Subtitle, bool) {
+	for i := range m.subtitles {
+		if m.subtitles[i].Uid() == uid {
+			return &m.subtitles[i], nil, true
+		}
+	}
+	return nil, nil, false
+}
+
+func (m *fakeManager) Details(ctx context.Context, provider string, subtitleId string) ([]models.SubComments, error) {
+	return nil, providers.ErrNoDetails
+}
+
+func (m *fakeManager) Readiness() models.Readiness {
+	return models.Readiness{Ready: true}
+}
+
+// downloaded returns the provider:id of every download asked for.
+func (m *fakeManager) downloaded() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.downloads...)
+}
+
+func textFile(name string, content string) *models.SubtitleFile {
+	return &models.SubtitleFile{
+		Body:        io.NopCloser(strings.NewReader(content)),
+		Filename:    name,
+		ContentType: "application/x-subrip",
+		Size:        int64(len(content)),
+	}
+}
+
+// testConfig loads the configuration defaults along with env. The provider
+// credentials it requires are never used by the fake manager.
+func testConfig(t *testing.T, env map[string]string) *config.Config {
+	t.Helper()
+	defaults := map[string]string{
+		"SA_OPEN_SUBTITLES_API_KEY":      "unused",
+		"SA_OPEN_SUBTITLES_API_USERNAME": "unused",
+		"SA_OPEN_SUBTITLES_API_PASSWORD": "unused",
+		"SA_SUBX_API_KEY":                "unused",
+		"SA_OTEL_ENABLED":                "false",
+	}
+	for key, value := range defaults {
+		t.Setenv(key, value)
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func newTestServer(t *testing.T, manager Manager, env map[string]string) *WebServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger := zerolog.Nop()
+	return New(testConfig(t, env), &logger, manager)
+}
+
+// serve runs req through the routes, setting headers given as name, value
+// pairs.
+func (w *WebServer) serve(req *http.Request, headers ...string) *httptest.ResponseRecorder {
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	w.ginger.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,112 @@
+package webserver
+
+import (
+	"time"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+/* v2 response schema, decoupled from models.Subtitle */
+type SubtitleV2 struct {
+	Uid         string   `json:"uid"`
+	Provider    string   `json:"provider"`
+	Id          string   `json:"id"`
+	Kind        string   `json:"kind"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Language    string   `json:"language"`
+	Year        int      `json:"year,omitempty"`
+	Season      int      `json:"season,omitempty"`
+	Episode     int      `json:"episode,omitempty"`
+	Groups      []string `json:"groups"`
+	Qualities   []string `json:"qualities"`
+	Resolutions []string `json:"resolutions"`
+	Durations   []string `json:"durations"`
+	DownloadUrl string   `json:"download_url"`
+
+	Downloads         int         `json:"downloads"`
+	Rating            float64     `json:"rating"`
+	Uploader          string      `json:"uploader,omitempty"`
+	UploadedAt        *time.Time  `json:"uploaded_at,omitempty"`
+	Cds               int         `json:"cds,omitempty"`
+	Fps               float64     `json:"fps,omitempty"`
+	HearingImpaired   bool        `json:"hearing_impaired"`
+	MachineTranslated bool        `json:"machine_translated"`
+	Trusted           bool        `json:"trusted"`
+	Comments          []CommentV2 `json:"comments,omitempty"`
+}
+
+type CommentV2 struct {
+	Id        int    `json:"id"`
+	Text      string `json:"text"`
+	Author    string `json:"author"`
+	CreatedAt string `json:"created_at"`
+}
+
+type listResponseV2 struct {
+	Total   int                      `json:"total"`
+	Data    any                      `json:"data"`
+	Skipped []models.SkippedProvider `json:"skipped,omitempty"`
+}
+
+type errorV2 struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorResponseV2 struct {
+	Error errorV2 `json:"error"`
+}
+
+func toSubtitleV2(s *models.Subtitle) SubtitleV2 {
+	id := s.DownloadId()
+	return SubtitleV2{
+		Uid:         s.Uid(),
+		Provider:    s.Provider,
+		Id:          id,
+		Kind:        s.Kind(),
+		Title:       s.Title,
+		Description: s.Description,
+		Language:    s.Language,
+		Year:        s.Year,
+		Season:      s.Season,
+		Episode:     s.Episode,
+		Groups:      models.NonNil(s.Group),
+		Qualities:   models.NonNil(s.Quality),
+		Resolutions: models.NonNil(s.Resolution),
+		Durations:   models.NonNil(s.Duration),
+		DownloadUrl: "/" + apiV2 + "/download/" + s.Provider + "/" + id,
+
+		Downloads:         s.Downloads,
+		Rating:            s.Rating,
+		Uploader:          s.Uploader,
+		UploadedAt:        s.UploadedAt,
+		Cds:               s.Cds,
+		Fps:               s.Fps,
+		HearingImpaired:   s.HearingImpaired,
+		MachineTranslated: s.MachineTranslated,
+		Trusted:           s.Trusted,
+		Comments:          toCommentsV2(s.Comments),
+	}
+}
+
+func toSubtitlesV2(subtitles []models.Subtitle) []SubtitleV2 {
+	items := make([]SubtitleV2, 0, len(subtitles))
+	for i := range subtitles {
+		items = append(items, toSubtitleV2(&subtitles[i]))
+	}
+	return items
+}
+
+func toCommentsV2(comments []models.SubComments) []CommentV2 {
+	items := make([]CommentV2, 0, len(comments))
+	for _, comment := range comments {
+		items = append(items, CommentV2{
+			Id:        comment.Id,
+			Text:      comment.Comment,
+			Author:    comment.Nick,
+			CreatedAt: comment.Date,
+		})
+	}
+	return items
+}
@@ -0,0 +1,111 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xochilpili/subtitler-api/internal/models"
+)
+
+const (
+	apiVersionKey = "api_version"
+	apiV1         = "v1"
+	apiV2         = "v2"
+)
+
+type legacyPolicy struct {
+	deprecation string
+	sunset      string
+}
+
+// newLegacyPolicy builds the Deprecation (RFC 9745) and Sunset (RFC 8594)
+// header values advertised on the unprefixed routes.
+func newLegacyPolicy(deprecatedAt string, sunsetAt string) (*legacyPolicy, error) {
+	deprecated, err := time.Parse(time.DateOnly, deprecatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid legacy deprecation date: %w", err)
+	}
+	sunset, err := time.Parse(time.DateOnly, sunsetAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid legacy sunset date: %w", err)
+	}
+	return &legacyPolicy{
+		deprecation: "@" + strconv.FormatInt(deprecated.Unix(), 10),
+		sunset:      sunset.UTC().Format(http.TimeFormat),
+	}, nil
+}
+
+func withApiVersion(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiVersionKey, version)
+		c.Next()
+	}
+}
+
+func (p *legacyPolicy) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", p.deprecation)
+		c.Header("Sunset", p.sunset)
+		c.Header("Link", fmt.Sprintf("</%s%s>; rel=\"successor-version\"", apiV1, c.Request.URL.Path))
+		c.Next()
+	}
+}
+
+func apiVersion(c *gin.Context) string {
+	if v := c.GetString(apiVersionKey); v != "" {
+		return v
+	}
+	return apiV1
+}
+
+func (w *WebServer) respondSearch(c *gin.Context, subtitles []models.Subtitle, skipped []models.SkippedProvider) {
+	if apiVersion(c) == apiV2 {
+		c.JSON(http.StatusOK, &listResponseV2{Total: len(subtitles), Data: toSubtitlesV2(subtitles), Skipped: skipped})
+		return
+	}
+	response := gin.H{"message": "ok", "total": len(subtitles), "data": subtitles}
+	if len(skipped) > 0 {
+		response["skipped"] = skipped
+	}
+	c.JSON(http.StatusOK, &response)
+}
+
+func (w *WebServer) respondList(c *gin.Context, total int, data any) {
+	if apiVersion(c) == apiV2 {
+		c.JSON(http.StatusOK, &listResponseV2{Total: total, Data: data})
+		return
+	}
+	c.JSON(http.StatusOK, &gin.H{"message": "ok", "total": total, "data": data})
+}
+
+func (w *WebServer) respondError(c *gin.Context, status int, err error) {
+	if apiVersion(c) == apiV2 {
+		c.JSON(status, &errorResponseV2{Error: errorV2{Code: errorCode(status), Message: err.Error()}})
+		return
+	}
+	c.JSON(status, &gin.H{"message": "error", "error": err.Error()})
+}
+
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusTooManyRequests:
+		return "too_many_requests"
+	case http.StatusBadGateway:
+		return "upstream_error"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	default:
+		return "internal_error"
+	}
+}
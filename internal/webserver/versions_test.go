@@ -0,0 +1,126 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"github.com/xochilpili/subtitler-api/internal/providers"
+)
+
+func TestLegacyRoutes(t *testing.T) {
+	srv := newTestServer(t, &fakeManager{}, map[string]string{"SA_LEGACY_DEPRECATED_AT": "2026-10-19", "SA_LEGACY_SUNSET_AT": "2027-04-19"})
+
+	res := srv.serve(httptest.NewRequest(http.MethodGet, "/search/all/?term=the+matrix", nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", res.Code, res.Body)
+	}
+	headers := map[string]string{
+		"Deprecation": "@1792368000",
+		"Sunset":      "Mon, 19 Apr 2027 00:00:00 GMT",
+		"Link":        `</v1/search/all/>; rel="successor-version"`,
+	}
+	for name, want := range headers {
+		if got := res.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// the versioned routes are not deprecated
+	res = srv.serve(httptest.NewRequest(http.MethodGet, "/v1/search/all/?term=the+matrix", nil))
+	if res.Code != http.StatusOK || res.Header().Get("Deprecation") != "" || res.Header().Get("Sunset") != "" {
+		t.Errorf("status = %d, Deprecation %q, Sunset %q, want 200 without either", res.Code, res.Header().Get("Deprecation"), res.Header().Get("Sunset"))
+	}
+}
+
+func TestNewLegacyPolicy(t *testing.T) {
+	if _, err := newLegacyPolicy("2026-19-10", "2027-04-19"); err == nil {
+		t.Error("newLegacyPolicy() accepted an invalid deprecation date")
+	}
+	if _, err := newLegacyPolicy("2026-10-19", "soon"); err == nil {
+		t.Error("newLegacyPolicy() accepted an invalid sunset date")
+	}
+}
+
+func TestErrorModel(t *testing.T) {
+	manager := &fakeManager{download: func(provider, id string) (*models.SubtitleFile, error) {
+		return nil, providers.ErrSubtitleNotFound
+	}}
+	srv := newTestServer(t, manager, nil)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"bad request", "/search/all/", http.StatusBadRequest, "bad_request"},
+		{"not found", "/download/podnapisi/a", http.StatusNotFound, "not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// /v1 and the legacy routes keep the message and error strings
+			for _, prefix := range []string{"", "/v1"} {
+				res := srv.serve(httptest.NewRequest(http.MethodGet, prefix+tt.path, nil))
+				var v1 struct {
+					Message string `json:"message"`
+					Error   string `json:"error"`
+				}
+				if err := json.Unmarshal(res.Body.Bytes(), &v1); err != nil || res.Code != tt.wantStatus || v1.Message != "error" || v1.Error == "" {
+					t.Errorf("GET %s = %d %s, want %d with an error string", prefix+tt.path, res.Code, res.Body, tt.wantStatus)
+				}
+			}
+
+			res := srv.serve(httptest.NewRequest(http.MethodGet, "/v2"+tt.path, nil))
+			var v2 errorResponseV2
+			if err := json.Unmarshal(res.Body.Bytes(), &v2); err != nil || res.Code != tt.wantStatus || v2.Error.Code != tt.wantCode || v2.Error.Message == "" {
+				t.Errorf("GET /v2%s = %d %s, want %d with code %s", tt.path, res.Code, res.Body, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSearchV2(t *testing.T) {
+	manager := &fakeManager{subtitles: []models.Subtitle{{
+		Provider:   "subdivx",
+		Id:         42,
+		ExternalId: "42",
+		Title:      "The Matrix",
+		Language:   "es",
+		Comments:   []models.SubComments{{Id: 1, Comment: "sincronizado", Nick: "ana", Date: "2024-01-02"}},
+	}}}
+	srv := newTestServer(t, manager, nil)
+
+	res := srv.serve(httptest.NewRequest(http.MethodGet, "/v2/search/all/?term=the+matrix", nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", res.Code, res.Body)
+	}
+	var body struct {
+		Total int              `json:"total"`
+		Data  []map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Total != 1 || len(body.Data) != 1 {
+		t.Fatalf("body = %s, want one subtitle", res.Body)
+	}
+	subtitle := body.Data[0]
+	if subtitle["uid"] != "subdivx:42" || subtitle["download_url"] != "/v2/download/subdivx/42" {
+		t.Errorf("subtitle = %v", subtitle)
+	}
+	// lists are never null, and the legacy field names are gone
+	if groups, ok := subtitle["groups"].([]any); !ok || len(groups) != 0 {
+		t.Errorf("groups = %v, want []", subtitle["groups"])
+	}
+	comments, _ := subtitle["comments"].([]any)
+	if len(comments) != 1 {
+		t.Fatalf("comments = %v", subtitle["comments"])
+	}
+	comment := comments[0].(map[string]any)
+	if comment["text"] != "sincronizado" || comment["author"] != "ana" || comment["created_at"] != "2024-01-02" || comment["comentario"] != nil {
+		t.Errorf("comment = %v", comment)
+	}
+}
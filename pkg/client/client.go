@@ -0,0 +1,337 @@
+// Package client is a Go client for the subtitler-api v2 HTTP API.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type Client struct {
+	r *resty.Client
+}
+
+type Option func(*resty.Client)
+
+// WithHTTPClient replaces the underlying http.Client, e.g. to add tracing.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(r *resty.Client) {
+		if hc.Transport != nil {
+			r.SetTransport(hc.Transport)
+		}
+		r.SetTimeout(hc.Timeout)
+		if hc.Jar != nil {
+			r.SetCookieJar(hc.Jar)
+		}
+	}
+}
+
+// WithRetries sets how many times GET calls are retried on network errors,
+// 429 and 5xx responses, with exponential backoff between waitTime and
+// maxWaitTime. Batch calls and canceled calls are never retried.
+func WithRetries(count int, waitTime time.Duration, maxWaitTime time.Duration) Option {
+	return func(r *resty.Client) {
+		r.SetRetryCount(count).
+			SetRetryWaitTime(waitTime).
+			SetRetryMaxWaitTime(maxWaitTime)
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(r *resty.Client) {
+		r.SetTimeout(timeout)
+	}
+}
+
+// WithHeader adds a header sent on every request, e.g. an API key.
+func WithHeader(key string, value string) Option {
+	return func(r *resty.Client) {
+		r.SetHeader(key, value)
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return WithHeader("User-Agent", userAgent)
+}
+
+func New(baseUrl string, opts ...Option) *Client {
+	r := resty.New().
+		SetBaseURL(strings.TrimSuffix(baseUrl, "/")).
+		SetHeader("User-Agent", "subtitler-api-client").
+		SetRetryCount(2).
+		SetRetryWaitTime(200 * time.Millisecond).
+		SetRetryMaxWaitTime(2 * time.Second)
+	r.AddRetryCondition(func(res *resty.Response, err error) bool {
+		// batches are not idempotent, and a canceled call is not retried
+		if res == nil || res.Request == nil || res.Request.Context().Err() != nil {
+			return false
+		}
+		if method := res.Request.Method; method != http.MethodGet && method != http.MethodHead {
+			return false
+		}
+		if err != nil {
+			return true
+		}
+		return res.StatusCode() == http.StatusTooManyRequests || res.StatusCode() >= http.StatusInternalServerError
+	}).AddRetryHook(func(res *resty.Response, _ error) {
+		// an attempt that is retried leaves its unparsed body behind; the
+		// last one is handed to the caller, which closes it
+		if res != nil && res.RawResponse != nil && res.Request.Attempt <= r.RetryCount {
+			res.RawResponse.Body.Close()
+		}
+	})
+	for _, opt := range opts {
+		opt(r)
+	}
+	return &Client{r: r}
+}
+
+// Search queries a single provider.
+func (c *Client) Search(ctx context.Context, provider string, params SearchParams) (*SearchResult, error) {
+	return c.search(ctx, "/v2/search/"+url.PathEscape(provider)+"/", params)
+}
+
+// SearchAll queries every enabled provider.
+func (c *Client) SearchAll(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	return c.search(ctx, "/v2/search/all/", params)
+}
+
+func (c *Client) search(ctx context.Context, path string, params SearchParams) (*SearchResult, error) {
+	var result SearchResult
+	res, err := c.r.R().
+		SetContext(ctx).
+		SetQueryParams(params.query()).
+		SetResult(&result).
+		Get(path)
+	if err != nil {
+		return nil, err
+	}
+	if res.IsError() {
+		return nil, decodeError(res.StatusCode(), res.Body())
+	}
+	return &result, nil
+}
+
+// SearchBatch runs several searches in one request; results come back in
+// the order of items.
+func (c *Client) SearchBatch(ctx context.Context, items []BatchSearch) ([]BatchSearchResult, error) {
+	var result struct {
+		Total int                 `json:"total"`
+		Data  []BatchSearchResult `json:"data"`
+	}
+	res, err := c.r.R().
+		SetContext(ctx).
+		SetBody(map[string]any{"items": items}).
+		SetResult(&result).
+		Post("/v2/search/batch")
+	if err != nil {
+		return nil, err
+	}
+	if res.IsError() {
+		return nil, decodeError(res.StatusCode(), res.Body())
+	}
+	return result.Data, nil
+}
+
+// SearchBatchStream runs several searches in one request and calls fn with
+// each result as soon as the server completes it.
+func (c *Client) SearchBatchStream(ctx context.Context, items []BatchSearch, fn func(BatchSearchResult) error) error {
+	res, err := c.r.R().
+		SetContext(ctx).
+		SetHeader("Accept", "application/x-ndjson").
+		SetBody(map[string]any{"items": items}).
+		SetDoNotParseResponse(true).
+		Post("/v2/search/batch")
+	if err != nil {
+		return err
+	}
+	body := res.RawBody()
+	defer body.Close()
+
+	if res.IsError() {
+		payload, _ := io.ReadAll(io.LimitReader(body, 1<<20))
+		return decodeError(res.StatusCode(), payload)
+	}
+	decoder := json.NewDecoder(body)
+	for {
+		var result BatchSearchResult
+		if err := decoder.Decode(&result); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error while reading batch results: %w", err)
+		}
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+}
+
+// Download streams the subtitle file into dst.
+func (c *Client) Download(ctx context.Context, provider string, subtitleId string, dst io.Writer) (*DownloadInfo, error) {
+	res, err := c.r.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true).
+		Get("/v2/download/" + url.PathEscape(provider) + "/" + url.PathEscape(subtitleId))
+	if err != nil {
+		return nil, err
+	}
+	body := res.RawBody()
+	defer body.Close()
+
+	if res.IsError() {
+		payload, _ := io.ReadAll(io.LimitReader(body, 1<<20))
+		return nil, decodeError(res.StatusCode(), payload)
+	}
+
+	info := &DownloadInfo{ContentType: res.Header().Get("Content-Type"), ETag: res.Header().Get("ETag")}
+	if _, params, err := mime.ParseMediaType(res.Header().Get("Content-Disposition")); err == nil {
+		info.Filename = params["filename"]
+	}
+	info.Size, err = io.Copy(dst, body)
+	if err != nil {
+		return info, fmt.Errorf("error while streaming subtitle: %w", err)
+	}
+	return info, nil
+}
+
+// DownloadBatch streams a zip holding every subtitle of items into dst.
+// Items that fail are listed in the manifest.json entry of the archive
+// rather than failing the call.
+func (c *Client) DownloadBatch(ctx context.Context, items []BatchDownload, opts BatchDownloadOptions, dst io.Writer) (*DownloadInfo, error) {
+	request := map[string]any{"items": items, "extract": opts.Extract}
+	if opts.Format != "" {
+		request["format"] = opts.Format
+	}
+	if opts.FailFast {
+		request["rate_limit"] = "fail"
+	}
+	res, err := c.r.R().
+		SetContext(ctx).
+		SetBody(request).
+		SetDoNotParseResponse(true).
+		Post("/v2/download/batch")
+	if err != nil {
+		return nil, err
+	}
+	body := res.RawBody()
+	defer body.Close()
+
+	if res.IsError() {
+		payload, _ := io.ReadAll(io.LimitReader(body, 1<<20))
+		return nil, decodeError(res.StatusCode(), payload)
+	}
+
+	info := &DownloadInfo{ContentType: res.Header().Get("Content-Type")}
+	if _, params, err := mime.ParseMediaType(res.Header().Get("Content-Disposition")); err == nil {
+		info.Filename = params["filename"]
+	}
+	info.Size, err = io.Copy(dst, body)
+	if err != nil {
+		return info, fmt.Errorf("error while streaming batch download: %w", err)
+	}
+	return info, nil
+}
+
+// Best streams the best ranked subtitle for a release into dst, falling
+// back through the next candidates on the server when one fails.
+func (c *Client) Best(ctx context.Context, params BestParams, dst io.Writer) (*BestInfo, error) {
+	res, err := c.r.R().
+		SetContext(ctx).
+		SetQueryParams(params.query()).
+		SetDoNotParseResponse(true).
+		Get("/v2/best")
+	if err != nil {
+		return nil, err
+	}
+	body := res.RawBody()
+	defer body.Close()
+
+	if res.IsError() {
+		payload, _ := io.ReadAll(io.LimitReader(body, 1<<20))
+		return nil, decodeError(res.StatusCode(), payload)
+	}
+
+	header := res.Header()
+	info := &BestInfo{
+		DownloadInfo: DownloadInfo{ContentType: header.Get("Content-Type")},
+		Uid:          header.Get("X-Subtitle-Uid"),
+		Provider:     header.Get("X-Subtitle-Provider"),
+		Id:           header.Get("X-Subtitle-Id"),
+		Language:     header.Get("X-Subtitle-Language"),
+	}
+	info.Release, _ = new(mime.WordDecoder).DecodeHeader(header.Get("X-Subtitle-Release"))
+	info.Score, _ = strconv.Atoi(header.Get("X-Subtitle-Score"))
+	info.Rank, _ = strconv.Atoi(header.Get("X-Subtitle-Rank"))
+	info.Candidates, _ = strconv.Atoi(header.Get("X-Subtitle-Candidates"))
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		info.Filename = params["filename"]
+	}
+	info.Size, err = io.Copy(dst, body)
+	if err != nil {
+		return info, fmt.Errorf("error while streaming subtitle: %w", err)
+	}
+	return info, nil
+}
+
+// Providers lists the providers registered on the server.
+func (c *Client) Providers(ctx context.Context) ([]Provider, error) {
+	var result struct {
+		Total int        `json:"total"`
+		Data  []Provider `json:"data"`
+	}
+	res, err := c.r.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get("/v2/providers")
+	if err != nil {
+		return nil, err
+	}
+	if res.IsError() {
+		return nil, decodeError(res.StatusCode(), res.Body())
+	}
+	return result.Data, nil
+}
+
+// Health returns nil when the server answers its liveness probe.
+func (c *Client) Health(ctx context.Context) error {
+	res, err := c.r.R().
+		SetContext(ctx).
+		Get("/healthz")
+	if err != nil {
+		return err
+	}
+	if res.IsError() {
+		return decodeError(res.StatusCode(), res.Body())
+	}
+	return nil
+}
+
+// Ready reports the server readiness; the result is returned along with an
+// error when the server is not ready to take traffic.
+func (c *Client) Ready(ctx context.Context) (*Readiness, error) {
+	var result Readiness
+	res, err := c.r.R().
+		SetContext(ctx).
+		SetResult(&result).
+		SetError(&result).
+		Get("/readyz")
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode() == http.StatusServiceUnavailable {
+		return &result, &Error{StatusCode: res.StatusCode(), Code: CodeUnavailable, Message: "not ready"}
+	}
+	if res.IsError() {
+		return nil, decodeError(res.StatusCode(), res.Body())
+	}
+	return &result, nil
+}
@@ -0,0 +1,320 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/xochilpili/subtitler-api/internal/config"
+	"github.com/xochilpili/subtitler-api/internal/models"
+	"github.com/xochilpili/subtitler-api/internal/providers"
+	"github.com/xochilpili/subtitler-api/internal/webserver"
+	"github.com/xochilpili/subtitler-api/pkg/client"
+)
+
+const testApiKey = "s3cret"
+
+const srtFile = "1\n00:00:01,000 --> 00:00:02,000\nHola\n"
+
+// fakeManager stands in for the providers behind the real handlers. The
+// download of "flaky" fails until failures runs out.
+type fakeManager struct {
+	mu        sync.Mutex
+	failures  int
+	downloads int
+}
+
+func (m *fakeManager) Search(ctx context.Context, provider string, req *models.SearchRequest, filters *models.PostFilters) ([]models.Subtitle, []models.SkippedProvider) {
+	subtitles := []models.Subtitle{
+		{Provider: "podnapisi", ExternalId: "AbC1", Title: "The Matrix", Language: "en", Year: 1999, Downloads: 10},
+		{Provider: "podnapisi", ExternalId: "Xy2", Title: "The Matrix", Language: "es", Year: 1999, HearingImpaired: true},
+	}
+	if provider != "" && provider != "podnapisi" {
+		return nil, []models.SkippedProvider{{Provider: provider, Reason: "error", Error: "unknown provider"}}
+	}
+	return subtitles, nil
+}
+
+func (m *fakeManager) Download(ctx context.Context, provider string, subtitleId string) (*models.SubtitleFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.downloads++
+	switch {
+	case subtitleId == "flaky" && m.failures > 0:
+		m.failures--
+		return nil, errors.New("podnapisi download failed with status 500")
+	case subtitleId == "missing":
+		return nil, providers.ErrSubtitleNotFound
+	}
+	return &models.SubtitleFile{
+		Body:        io.NopCloser(strings.NewReader(srtFile)),
+		Filename:    subtitleId + ".srt",
+		ContentType: "application/x-subrip",
+		Size:        int64(len(srtFile)),
+		ETag:        `"v1"`,
+	}, nil
+}
+
+func (m *fakeManager) Providers() []models.ProviderInfo {
+	return []models.ProviderInfo{{Name: "podnapisi", Enabled: true, Formats: []string{"zip"}}}
+}
+
+func (m *fakeManager) Subtitle(uid string) (*models.Subtitle, []models.Subtitle, bool) {
+	return nil, nil, false
+}
+
+func (m *fakeManager) Details(ctx context.Context, provider string, subtitleId string) ([]models.SubComments, error) {
+	return nil, providers.ErrNoDetails
+}
+
+func (m *fakeManager) Readiness() models.Readiness {
+	return models.Readiness{Ready: true, Healthy: 1, Required: 1}
+}
+
+func (m *fakeManager) downloadCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.downloads
+}
+
+// testServer runs the real webserver handlers over manager, behind a
+// counter of the requests that reach it. The first unavailable requests
+// are answered 503 before reaching the handlers.
+type testServer struct {
+	*httptest.Server
+	mu          sync.Mutex
+	requests    map[string]int
+	unavailable int
+}
+
+func newTestServer(t *testing.T, manager webserver.Manager) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	// the manager is faked, so the provider credentials are never used
+	for key, value := range map[string]string{
+		"SA_OPEN_SUBTITLES_API_KEY":      "unused",
+		"SA_OPEN_SUBTITLES_API_USERNAME": "unused",
+		"SA_OPEN_SUBTITLES_API_PASSWORD": "unused",
+		"SA_SUBX_API_KEY":                "unused",
+		"SA_OTEL_ENABLED":                "false",
+		"SA_API_KEYS":                    "tests:" + testApiKey,
+	} {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := zerolog.Nop()
+	handler := webserver.New(cfg, &logger, manager).Web.Handler
+
+	server := &testServer{requests: map[string]int{}}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		server.requests[r.Method+" "+r.URL.Path]++
+		unavailable := server.unavailable > 0
+		if unavailable {
+			server.unavailable--
+		}
+		server.mu.Unlock()
+		if unavailable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *testServer) count(request string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[request]
+}
+
+func (s *testServer) failNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unavailable = n
+}
+
+func newTestClient(server *testServer, opts ...client.Option) *client.Client {
+	opts = append([]client.Option{
+		client.WithHeader("X-API-Key", testApiKey),
+		client.WithRetries(3, time.Millisecond, 5*time.Millisecond),
+	}, opts...)
+	return client.New(server.URL, opts...)
+}
+
+func TestSearch(t *testing.T) {
+	server := newTestServer(t, &fakeManager{})
+	c := newTestClient(server)
+
+	result, err := c.Search(context.Background(), "podnapisi", client.SearchParams{Term: "the matrix", Year: 1999})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 2 || len(result.Data) != 2 {
+		t.Fatalf("result = %+v, want 2 subtitles", result)
+	}
+	first := result.Data[0]
+	if first.Uid != "podnapisi:AbC1" || first.Language != "en" || first.Year != 1999 || first.Downloads != 10 {
+		t.Errorf("subtitle = %+v", first)
+	}
+	if !result.Data[1].HearingImpaired {
+		t.Errorf("subtitle %s should be hearing impaired", result.Data[1].Uid)
+	}
+
+	result, err = c.SearchAll(context.Background(), client.SearchParams{Term: "the matrix"})
+	if err != nil || result.Total != 2 {
+		t.Fatalf("SearchAll() = %+v, %v", result, err)
+	}
+}
+
+func TestUnauthenticated(t *testing.T) {
+	server := newTestServer(t, &fakeManager{})
+	tests := []struct {
+		name string
+		opts []client.Option
+	}{
+		{"no key", nil},
+		{"wrong key", []client.Option{client.WithHeader("X-API-Key", "guess")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := client.New(server.URL, tt.opts...)
+			_, err := c.SearchAll(context.Background(), client.SearchParams{Term: "the matrix"})
+			var apiErr *client.Error
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || !client.IsCode(err, client.CodeUnauthorized) {
+				t.Fatalf("SearchAll() error = %v, want 401 unauthorized", err)
+			}
+		})
+	}
+}
+
+func TestDownload(t *testing.T) {
+	manager := &fakeManager{}
+	server := newTestServer(t, manager)
+	c := newTestClient(server)
+
+	var buf bytes.Buffer
+	info, err := c.Download(context.Background(), "podnapisi", "AbC1", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != srtFile || info.Size != int64(len(srtFile)) {
+		t.Errorf("downloaded %q (%d bytes), want %q", buf.String(), info.Size, srtFile)
+	}
+	if info.Filename != "AbC1.srt" || info.ETag != `"v1"` {
+		t.Errorf("info = %+v", info)
+	}
+
+	_, err = c.Download(context.Background(), "podnapisi", "missing", io.Discard)
+	if !client.IsCode(err, client.CodeNotFound) {
+		t.Errorf("missing subtitle error = %v, want not_found", err)
+	}
+	// a 404 is final
+	if n := server.count("GET /v2/download/podnapisi/missing"); n != 1 {
+		t.Errorf("missing subtitle was requested %d times, want 1", n)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	t.Run("GET is retried until it succeeds", func(t *testing.T) {
+		manager := &fakeManager{failures: 2}
+		server := newTestServer(t, manager)
+		var buf bytes.Buffer
+		if _, err := newTestClient(server).Download(context.Background(), "podnapisi", "flaky", &buf); err != nil {
+			t.Fatal(err)
+		}
+		if n := manager.downloadCount(); n != 3 {
+			t.Errorf("download attempts = %d, want 3", n)
+		}
+		if buf.String() != srtFile {
+			t.Errorf("downloaded %q, want %q", buf.String(), srtFile)
+		}
+	})
+
+	t.Run("the last error is returned once retries run out", func(t *testing.T) {
+		manager := &fakeManager{failures: 10}
+		server := newTestServer(t, manager)
+		_, err := newTestClient(server).Download(context.Background(), "podnapisi", "flaky", io.Discard)
+		if !client.IsCode(err, client.CodeUpstreamError) {
+			t.Fatalf("Download() error = %v, want upstream_error", err)
+		}
+		if n := manager.downloadCount(); n != 4 {
+			t.Errorf("download attempts = %d, want 4", n)
+		}
+	})
+
+	t.Run("POST is never retried", func(t *testing.T) {
+		server := newTestServer(t, &fakeManager{})
+		server.failNext(1)
+		_, err := newTestClient(server).SearchBatch(context.Background(), []client.BatchSearch{{Id: "a", Params: client.SearchParams{Term: "the matrix"}}})
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("SearchBatch() error = %v, want 503", err)
+		}
+		if n := server.count("POST /v2/search/batch"); n != 1 {
+			t.Errorf("batch requests = %d, want 1", n)
+		}
+	})
+
+	t.Run("a canceled call is not retried", func(t *testing.T) {
+		server := newTestServer(t, &fakeManager{})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := newTestClient(server).SearchAll(ctx, client.SearchParams{Term: "the matrix"}); !errors.Is(err, context.Canceled) {
+			t.Fatalf("SearchAll() error = %v, want context.Canceled", err)
+		}
+		if n := server.count("GET /v2/search/all/"); n != 0 {
+			t.Errorf("search requests = %d, want 0", n)
+		}
+	})
+}
+
+func TestSearchBatch(t *testing.T) {
+	server := newTestServer(t, &fakeManager{})
+	results, err := newTestClient(server).SearchBatch(context.Background(), []client.BatchSearch{
+		{Id: "one", Provider: "podnapisi", Params: client.SearchParams{Term: "the matrix"}},
+		{Id: "two", Provider: "elsewhere", Params: client.SearchParams{Term: "the matrix"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Id != "one" || results[1].Id != "two" {
+		t.Fatalf("results = %+v, want one per item in order", results)
+	}
+	if results[0].Total != 2 {
+		t.Errorf("first item = %+v, want 2 subtitles", results[0])
+	}
+}
+
+func TestProvidersAndProbes(t *testing.T) {
+	server := newTestServer(t, &fakeManager{})
+	c := newTestClient(server)
+
+	list, err := c.Providers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "podnapisi" || !list[0].Enabled {
+		t.Errorf("Providers() = %+v", list)
+	}
+	if err := c.Health(context.Background()); err != nil {
+		t.Errorf("Health() = %v", err)
+	}
+	if ready, err := c.Ready(context.Background()); err != nil || !ready.Ready {
+		t.Errorf("Ready() = %+v, %v", ready, err)
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error codes returned by the server in the v2 error model.
+const (
+	CodeBadRequest      = "bad_request"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeTooManyRequests = "too_many_requests"
+	CodeUpstreamError   = "upstream_error"
+	CodeUnavailable     = "unavailable"
+	CodeInternalError   = "internal_error"
+)
+
+// Error is a non 2xx response from subtitler-api.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("subtitler-api: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsCode reports whether err is an *Error carrying the given code.
+func IsCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+func decodeError(status int, body []byte) *Error {
+	var payload struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	apiErr := &Error{StatusCode: status}
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Error.Code != "" {
+		apiErr.Code = payload.Error.Code
+		apiErr.Message = payload.Error.Message
+		return apiErr
+	}
+	apiErr.Code = CodeInternalError
+	if status == http.StatusNotFound {
+		apiErr.Code = CodeNotFound
+	}
+	apiErr.Message = http.StatusText(status)
+	return apiErr
+}
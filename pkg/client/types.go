@@ -0,0 +1,313 @@
+package client
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+type SearchParams struct {
+	Term       string `json:"term"`
+	Year       int    `json:"year,omitempty"`
+	Group      string `json:"group,omitempty"`
+	Quality    string `json:"quality,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+	Season     int    `json:"season,omitempty"`
+	Episode    int    `json:"episode,omitempty"`
+	Language   string `json:"language,omitempty"`
+
+	IncludeMachineTranslated bool    `json:"include_machine_translated,omitempty"`
+	MinDownloads             int     `json:"min_downloads,omitempty"`
+	MinRating                float64 `json:"min_rating,omitempty"`
+	HearingImpaired          *bool   `json:"hearing_impaired,omitempty"`
+	MachineTranslated        *bool   `json:"machine_translated,omitempty"`
+	Trusted                  *bool   `json:"trusted,omitempty"`
+	// Sort is one of downloads, rating, uploaded_at, fps or cds.
+	Sort string `json:"sort,omitempty"`
+	// Order is asc or desc (default).
+	Order string `json:"order,omitempty"`
+	// FailFast skips providers over their rate limit instead of waiting.
+	FailFast bool `json:"-"`
+}
+
+// BatchSearch is one search of a batch. Provider is empty to search every
+// provider; Id is echoed back in the matching result.
+type BatchSearch struct {
+	Id       string
+	Provider string
+	Params   SearchParams
+}
+
+func (b BatchSearch) MarshalJSON() ([]byte, error) {
+	type params SearchParams
+	item := struct {
+		Id        string `json:"id,omitempty"`
+		Provider  string `json:"provider,omitempty"`
+		RateLimit string `json:"rate_limit,omitempty"`
+		params
+	}{Id: b.Id, Provider: b.Provider, params: params(b.Params)}
+	if b.Params.FailFast {
+		item.RateLimit = "fail"
+	}
+	return json.Marshal(item)
+}
+
+// BatchSearchResult is the outcome of the batch item at Index.
+type BatchSearchResult struct {
+	Index    int               `json:"index"`
+	Id       string            `json:"id,omitempty"`
+	Provider string            `json:"provider"`
+	Total    int               `json:"total"`
+	Data     []Subtitle        `json:"data"`
+	Skipped  []SkippedProvider `json:"skipped,omitempty"`
+	Error    *BatchError       `json:"error,omitempty"`
+}
+
+// BatchError is why a single item of a batch failed.
+type BatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// BatchDownload is one download of a batch; Name optionally sets its
+// filename in the archive.
+type BatchDownload struct {
+	Provider string `json:"provider"`
+	Id       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+}
+
+// BatchDownloadOptions asks the server to unpack archives, and with Format
+// ("srt" or "vtt") to convert the subtitles as well.
+type BatchDownloadOptions struct {
+	Extract  bool
+	Format   string
+	FailFast bool
+}
+
+type Subtitle struct {
+	Uid         string   `json:"uid"`
+	Provider    string   `json:"provider"`
+	Id          string   `json:"id"`
+	Kind        string   `json:"kind"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Language    string   `json:"language"`
+	Year        int      `json:"year,omitempty"`
+	Season      int      `json:"season,omitempty"`
+	Episode     int      `json:"episode,omitempty"`
+	Groups      []string `json:"groups"`
+	Qualities   []string `json:"qualities"`
+	Resolutions []string `json:"resolutions"`
+	Durations   []string `json:"durations"`
+	DownloadUrl string   `json:"download_url"`
+
+	Downloads         int        `json:"downloads"`
+	Rating            float64    `json:"rating"`
+	Uploader          string     `json:"uploader,omitempty"`
+	UploadedAt        *time.Time `json:"uploaded_at,omitempty"`
+	Cds               int        `json:"cds,omitempty"`
+	Fps               float64    `json:"fps,omitempty"`
+	HearingImpaired   bool       `json:"hearing_impaired"`
+	MachineTranslated bool       `json:"machine_translated"`
+	Trusted           bool       `json:"trusted"`
+	Comments          []Comment  `json:"comments,omitempty"`
+}
+
+type Comment struct {
+	Id        int    `json:"id"`
+	Text      string `json:"text"`
+	Author    string `json:"author"`
+	CreatedAt string `json:"created_at"`
+}
+
+type SearchResult struct {
+	Total   int               `json:"total"`
+	Data    []Subtitle        `json:"data"`
+	Skipped []SkippedProvider `json:"skipped,omitempty"`
+}
+
+// SkippedProvider is a provider that did not contribute to a search,
+// either because its circuit is open or because it failed.
+type SkippedProvider struct {
+	Provider string `json:"provider"`
+	Reason   string `json:"reason"`
+	Error    string `json:"error,omitempty"`
+}
+
+type Provider struct {
+	Name       string     `json:"name"`
+	Enabled    bool       `json:"enabled"`
+	Languages  []string   `json:"languages"`
+	SearchKeys []string   `json:"search_keys"`
+	Formats    []string   `json:"formats"`
+	RateLimit  *RateLimit `json:"rate_limit,omitempty"`
+	Quota      *Quota     `json:"quota,omitempty"`
+	Health     *Health    `json:"health,omitempty"`
+	Circuit    *Circuit   `json:"circuit,omitempty"`
+}
+
+type Circuit struct {
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	RetryAt  *time.Time `json:"retry_at,omitempty"`
+}
+
+type RateLimit struct {
+	IntervalMs        int64      `json:"interval_ms"`
+	NextSlot          *time.Time `json:"next_slot,omitempty"`
+	RequestsPerSecond float64    `json:"requests_per_second,omitempty"`
+	Burst             int        `json:"burst,omitempty"`
+	Available         float64    `json:"available,omitempty"`
+}
+
+type Health struct {
+	Healthy   bool      `json:"healthy"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type Quota struct {
+	Remaining int        `json:"remaining"`
+	ResetAt   *time.Time `json:"reset_at,omitempty"`
+}
+
+type DownloadInfo struct {
+	Filename    string
+	ContentType string
+	Size        int64
+	ETag        string
+}
+
+func (p *SearchParams) query() map[string]string {
+	params := map[string]string{"term": p.Term}
+	if p.Year > 0 {
+		params["year"] = strconv.Itoa(p.Year)
+	}
+	if p.Group != "" {
+		params["group"] = p.Group
+	}
+	if p.Quality != "" {
+		params["quality"] = p.Quality
+	}
+	if p.Resolution != "" {
+		params["resolution"] = p.Resolution
+	}
+	if p.Season > 0 {
+		params["season"] = strconv.Itoa(p.Season)
+	}
+	if p.Episode > 0 {
+		params["episode"] = strconv.Itoa(p.Episode)
+	}
+	if p.Language != "" {
+		params["language"] = p.Language
+	}
+	if p.IncludeMachineTranslated {
+		params["include_machine_translated"] = "true"
+	}
+	if p.MinDownloads > 0 {
+		params["min_downloads"] = strconv.Itoa(p.MinDownloads)
+	}
+	if p.MinRating > 0 {
+		params["min_rating"] = strconv.FormatFloat(p.MinRating, 'f', -1, 64)
+	}
+	if p.HearingImpaired != nil {
+		params["hearing_impaired"] = strconv.FormatBool(*p.HearingImpaired)
+	}
+	if p.MachineTranslated != nil {
+		params["machine_translated"] = strconv.FormatBool(*p.MachineTranslated)
+	}
+	if p.Trusted != nil {
+		params["trusted"] = strconv.FormatBool(*p.Trusted)
+	}
+	if p.Sort != "" {
+		params["sort"] = p.Sort
+	}
+	if p.Order != "" {
+		params["order"] = p.Order
+	}
+	if p.FailFast {
+		params["rate_limit"] = "fail"
+	}
+	return params
+}
+
+// BestParams describes the video to find a subtitle for. Release, Term or
+// both must be set; Language lists the accepted languages in order of
+// preference, comma separated.
+type BestParams struct {
+	Release  string
+	Term     string
+	Year     int
+	Season   int
+	Episode  int
+	Language string
+	// Format converts the subtitle to srt or vtt.
+	Format string
+
+	HearingImpaired          *bool
+	IncludeMachineTranslated bool
+	FailFast                 bool
+}
+
+func (p *BestParams) query() map[string]string {
+	params := map[string]string{}
+	if p.Release != "" {
+		params["release"] = p.Release
+	}
+	if p.Term != "" {
+		params["term"] = p.Term
+	}
+	if p.Year > 0 {
+		params["year"] = strconv.Itoa(p.Year)
+	}
+	if p.Season > 0 {
+		params["season"] = strconv.Itoa(p.Season)
+	}
+	if p.Episode > 0 {
+		params["episode"] = strconv.Itoa(p.Episode)
+	}
+	if p.Language != "" {
+		params["language"] = p.Language
+	}
+	if p.Format != "" {
+		params["format"] = p.Format
+	}
+	if p.HearingImpaired != nil {
+		params["hearing_impaired"] = strconv.FormatBool(*p.HearingImpaired)
+	}
+	if p.IncludeMachineTranslated {
+		params["include_machine_translated"] = "true"
+	}
+	if p.FailFast {
+		params["rate_limit"] = "fail"
+	}
+	return params
+}
+
+// BestInfo describes the subtitle the server chose.
+type BestInfo struct {
+	DownloadInfo
+	Uid        string
+	Provider   string
+	Id         string
+	Language   string
+	Release    string
+	Score      int
+	Rank       int
+	Candidates int
+}
+
+type Readiness struct {
+	Ready     bool             `json:"ready"`
+	Healthy   int              `json:"healthy"`
+	Required  int              `json:"required"`
+	Providers []ProviderStatus `json:"providers"`
+}
+
+type ProviderStatus struct {
+	Name      string     `json:"name"`
+	Healthy   bool       `json:"healthy"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
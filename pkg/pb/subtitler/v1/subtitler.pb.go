@@ -0,0 +1,1026 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v5.29.3
+// source: subtitler/v1/subtitler.proto
+
+package subtitlerv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SearchRequest struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Provider          string                 `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	Term              string                 `protobuf:"bytes,2,opt,name=term,proto3" json:"term,omitempty"`
+	Year              int32                  `protobuf:"varint,3,opt,name=year,proto3" json:"year,omitempty"`
+	Group             string                 `protobuf:"bytes,4,opt,name=group,proto3" json:"group,omitempty"`
+	Quality           string                 `protobuf:"bytes,5,opt,name=quality,proto3" json:"quality,omitempty"`
+	Resolution        string                 `protobuf:"bytes,6,opt,name=resolution,proto3" json:"resolution,omitempty"`
+	Season            int32                  `protobuf:"varint,7,opt,name=season,proto3" json:"season,omitempty"`
+	Episode           int32                  `protobuf:"varint,8,opt,name=episode,proto3" json:"episode,omitempty"`
+	Language          string                 `protobuf:"bytes,9,opt,name=language,proto3" json:"language,omitempty"`
+	MinDownloads      int32                  `protobuf:"varint,10,opt,name=min_downloads,json=minDownloads,proto3" json:"min_downloads,omitempty"`
+	MinRating         float64                `protobuf:"fixed64,11,opt,name=min_rating,json=minRating,proto3" json:"min_rating,omitempty"`
+	HearingImpaired   *bool                  `protobuf:"varint,12,opt,name=hearing_impaired,json=hearingImpaired,proto3,oneof" json:"hearing_impaired,omitempty"`
+	MachineTranslated *bool                  `protobuf:"varint,13,opt,name=machine_translated,json=machineTranslated,proto3,oneof" json:"machine_translated,omitempty"`
+	Trusted           *bool                  `protobuf:"varint,14,opt,name=trusted,proto3,oneof" json:"trusted,omitempty"`
+	// One of downloads, rating, uploaded_at, fps or cds.
+	Sort string `protobuf:"bytes,15,opt,name=sort,proto3" json:"sort,omitempty"`
+	// asc or desc (default).
+	Order string `protobuf:"bytes,16,opt,name=order,proto3" json:"order,omitempty"`
+	// Ask providers for machine and AI translated subtitles too.
+	IncludeMachineTranslated bool `protobuf:"varint,17,opt,name=include_machine_translated,json=includeMachineTranslated,proto3" json:"include_machine_translated,omitempty"`
+	unknownFields            protoimpl.UnknownFields
+	sizeCache                protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_subtitler_v1_subtitler_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SearchRequest) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetTerm() string {
+	if x != nil {
+		return x.Term
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetYear() int32 {
+	if x != nil {
+		return x.Year
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetQuality() string {
+	if x != nil {
+		return x.Quality
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetResolution() string {
+	if x != nil {
+		return x.Resolution
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetSeason() int32 {
+	if x != nil {
+		return x.Season
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetEpisode() int32 {
+	if x != nil {
+		return x.Episode
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetMinDownloads() int32 {
+	if x != nil {
+		return x.MinDownloads
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetMinRating() float64 {
+	if x != nil {
+		return x.MinRating
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetHearingImpaired() bool {
+	if x != nil && x.HearingImpaired != nil {
+		return *x.HearingImpaired
+	}
+	return false
+}
+
+func (x *SearchRequest) GetMachineTranslated() bool {
+	if x != nil && x.MachineTranslated != nil {
+		return *x.MachineTranslated
+	}
+	return false
+}
+
+func (x *SearchRequest) GetTrusted() bool {
+	if x != nil && x.Trusted != nil {
+		return *x.Trusted
+	}
+	return false
+}
+
+func (x *SearchRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetOrder() string {
+	if x != nil {
+		return x.Order
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetIncludeMachineTranslated() bool {
+	if x != nil {
+		return x.IncludeMachineTranslated
+	}
+	return false
+}
+
+type Subtitle struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Uid         string                 `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Provider    string                 `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	Id          string                 `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	Kind        string                 `protobuf:"bytes,4,opt,name=kind,proto3" json:"kind,omitempty"`
+	Title       string                 `protobuf:"bytes,5,opt,name=title,proto3" json:"title,omitempty"`
+	Description string                 `protobuf:"bytes,6,opt,name=description,proto3" json:"description,omitempty"`
+	Language    string                 `protobuf:"bytes,7,opt,name=language,proto3" json:"language,omitempty"`
+	Year        int32                  `protobuf:"varint,8,opt,name=year,proto3" json:"year,omitempty"`
+	Season      int32                  `protobuf:"varint,9,opt,name=season,proto3" json:"season,omitempty"`
+	Episode     int32                  `protobuf:"varint,10,opt,name=episode,proto3" json:"episode,omitempty"`
+	Groups      []string               `protobuf:"bytes,11,rep,name=groups,proto3" json:"groups,omitempty"`
+	Qualities   []string               `protobuf:"bytes,12,rep,name=qualities,proto3" json:"qualities,omitempty"`
+	Resolutions []string               `protobuf:"bytes,13,rep,name=resolutions,proto3" json:"resolutions,omitempty"`
+	Durations   []string               `protobuf:"bytes,14,rep,name=durations,proto3" json:"durations,omitempty"`
+	Downloads   int32                  `protobuf:"varint,15,opt,name=downloads,proto3" json:"downloads,omitempty"`
+	Rating      float64                `protobuf:"fixed64,16,opt,name=rating,proto3" json:"rating,omitempty"`
+	Uploader    string                 `protobuf:"bytes,17,opt,name=uploader,proto3" json:"uploader,omitempty"`
+	// RFC 3339, empty when the provider does not report it.
+	UploadedAt        string  `protobuf:"bytes,18,opt,name=uploaded_at,json=uploadedAt,proto3" json:"uploaded_at,omitempty"`
+	Cds               int32   `protobuf:"varint,19,opt,name=cds,proto3" json:"cds,omitempty"`
+	Fps               float64 `protobuf:"fixed64,20,opt,name=fps,proto3" json:"fps,omitempty"`
+	HearingImpaired   bool    `protobuf:"varint,21,opt,name=hearing_impaired,json=hearingImpaired,proto3" json:"hearing_impaired,omitempty"`
+	MachineTranslated bool    `protobuf:"varint,22,opt,name=machine_translated,json=machineTranslated,proto3" json:"machine_translated,omitempty"`
+	Trusted           bool    `protobuf:"varint,23,opt,name=trusted,proto3" json:"trusted,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Subtitle) Reset() {
+	*x = Subtitle{}
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subtitle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subtitle) ProtoMessage() {}
+
+func (x *Subtitle) ProtoReflect() protoreflect.Message {
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subtitle.ProtoReflect.Descriptor instead.
+func (*Subtitle) Descriptor() ([]byte, []int) {
+	return file_subtitler_v1_subtitler_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Subtitle) GetUid() string {
+	if x != nil {
+		return x.Uid
+	}
+	return ""
+}
+
+func (x *Subtitle) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *Subtitle) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Subtitle) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Subtitle) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Subtitle) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Subtitle) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *Subtitle) GetYear() int32 {
+	if x != nil {
+		return x.Year
+	}
+	return 0
+}
+
+func (x *Subtitle) GetSeason() int32 {
+	if x != nil {
+		return x.Season
+	}
+	return 0
+}
+
+func (x *Subtitle) GetEpisode() int32 {
+	if x != nil {
+		return x.Episode
+	}
+	return 0
+}
+
+func (x *Subtitle) GetGroups() []string {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *Subtitle) GetQualities() []string {
+	if x != nil {
+		return x.Qualities
+	}
+	return nil
+}
+
+func (x *Subtitle) GetResolutions() []string {
+	if x != nil {
+		return x.Resolutions
+	}
+	return nil
+}
+
+func (x *Subtitle) GetDurations() []string {
+	if x != nil {
+		return x.Durations
+	}
+	return nil
+}
+
+func (x *Subtitle) GetDownloads() int32 {
+	if x != nil {
+		return x.Downloads
+	}
+	return 0
+}
+
+func (x *Subtitle) GetRating() float64 {
+	if x != nil {
+		return x.Rating
+	}
+	return 0
+}
+
+func (x *Subtitle) GetUploader() string {
+	if x != nil {
+		return x.Uploader
+	}
+	return ""
+}
+
+func (x *Subtitle) GetUploadedAt() string {
+	if x != nil {
+		return x.UploadedAt
+	}
+	return ""
+}
+
+func (x *Subtitle) GetCds() int32 {
+	if x != nil {
+		return x.Cds
+	}
+	return 0
+}
+
+func (x *Subtitle) GetFps() float64 {
+	if x != nil {
+		return x.Fps
+	}
+	return 0
+}
+
+func (x *Subtitle) GetHearingImpaired() bool {
+	if x != nil {
+		return x.HearingImpaired
+	}
+	return false
+}
+
+func (x *Subtitle) GetMachineTranslated() bool {
+	if x != nil {
+		return x.MachineTranslated
+	}
+	return false
+}
+
+func (x *Subtitle) GetTrusted() bool {
+	if x != nil {
+		return x.Trusted
+	}
+	return false
+}
+
+type SearchResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Subtitles []*Subtitle            `protobuf:"bytes,1,rep,name=subtitles,proto3" json:"subtitles,omitempty"`
+	// Providers that did not contribute to the results.
+	Skipped       []*SkippedProvider `protobuf:"bytes,2,rep,name=skipped,proto3" json:"skipped,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_subtitler_v1_subtitler_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SearchResponse) GetSubtitles() []*Subtitle {
+	if x != nil {
+		return x.Subtitles
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetSkipped() []*SkippedProvider {
+	if x != nil {
+		return x.Skipped
+	}
+	return nil
+}
+
+type SkippedProvider struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Provider string                 `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	// circuit_open, rate_limited, error, or partial when
+	// only the first pages of results came through.
+	Reason        string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	Error         string `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SkippedProvider) Reset() {
+	*x = SkippedProvider{}
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SkippedProvider) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SkippedProvider) ProtoMessage() {}
+
+func (x *SkippedProvider) ProtoReflect() protoreflect.Message {
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SkippedProvider.ProtoReflect.Descriptor instead.
+func (*SkippedProvider) Descriptor() ([]byte, []int) {
+	return file_subtitler_v1_subtitler_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SkippedProvider) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *SkippedProvider) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *SkippedProvider) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type ProviderResults struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Provider  string                 `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	Subtitles []*Subtitle            `protobuf:"bytes,2,rep,name=subtitles,proto3" json:"subtitles,omitempty"`
+	// Set when the provider did not contribute to the results.
+	Skipped       *SkippedProvider `protobuf:"bytes,3,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProviderResults) Reset() {
+	*x = ProviderResults{}
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProviderResults) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProviderResults) ProtoMessage() {}
+
+func (x *ProviderResults) ProtoReflect() protoreflect.Message {
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProviderResults.ProtoReflect.Descriptor instead.
+func (*ProviderResults) Descriptor() ([]byte, []int) {
+	return file_subtitler_v1_subtitler_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ProviderResults) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *ProviderResults) GetSubtitles() []*Subtitle {
+	if x != nil {
+		return x.Subtitles
+	}
+	return nil
+}
+
+func (x *ProviderResults) GetSkipped() *SkippedProvider {
+	if x != nil {
+		return x.Skipped
+	}
+	return nil
+}
+
+type DownloadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Provider      string                 `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	SubtitleId    string                 `protobuf:"bytes,2,opt,name=subtitle_id,json=subtitleId,proto3" json:"subtitle_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadRequest) Reset() {
+	*x = DownloadRequest{}
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadRequest) ProtoMessage() {}
+
+func (x *DownloadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadRequest.ProtoReflect.Descriptor instead.
+func (*DownloadRequest) Descriptor() ([]byte, []int) {
+	return file_subtitler_v1_subtitler_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DownloadRequest) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *DownloadRequest) GetSubtitleId() string {
+	if x != nil {
+		return x.SubtitleId
+	}
+	return ""
+}
+
+type DownloadChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	ContentType   string                 `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadChunk) Reset() {
+	*x = DownloadChunk{}
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadChunk) ProtoMessage() {}
+
+func (x *DownloadChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadChunk.ProtoReflect.Descriptor instead.
+func (*DownloadChunk) Descriptor() ([]byte, []int) {
+	return file_subtitler_v1_subtitler_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DownloadChunk) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *DownloadChunk) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *DownloadChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type ListProvidersRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProvidersRequest) Reset() {
+	*x = ListProvidersRequest{}
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProvidersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProvidersRequest) ProtoMessage() {}
+
+func (x *ListProvidersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProvidersRequest.ProtoReflect.Descriptor instead.
+func (*ListProvidersRequest) Descriptor() ([]byte, []int) {
+	return file_subtitler_v1_subtitler_proto_rawDescGZIP(), []int{7}
+}
+
+type Provider struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Name    string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Enabled bool                   `protobuf:"varint,2,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	// Empty when the provider accepts any language.
+	Languages     []string `protobuf:"bytes,3,rep,name=languages,proto3" json:"languages,omitempty"`
+	SearchKeys    []string `protobuf:"bytes,4,rep,name=search_keys,json=searchKeys,proto3" json:"search_keys,omitempty"`
+	Formats       []string `protobuf:"bytes,5,rep,name=formats,proto3" json:"formats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Provider) Reset() {
+	*x = Provider{}
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Provider) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Provider) ProtoMessage() {}
+
+func (x *Provider) ProtoReflect() protoreflect.Message {
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Provider.ProtoReflect.Descriptor instead.
+func (*Provider) Descriptor() ([]byte, []int) {
+	return file_subtitler_v1_subtitler_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Provider) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Provider) GetEnabled() bool {
+	if x != nil {
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *Provider) GetLanguages() []string {
+	if x != nil {
+		return x.Languages
+	}
+	return nil
+}
+
+func (x *Provider) GetSearchKeys() []string {
+	if x != nil {
+		return x.SearchKeys
+	}
+	return nil
+}
+
+func (x *Provider) GetFormats() []string {
+	if x != nil {
+		return x.Formats
+	}
+	return nil
+}
+
+type ListProvidersResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Providers     []*Provider            `protobuf:"bytes,1,rep,name=providers,proto3" json:"providers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListProvidersResponse) Reset() {
+	*x = ListProvidersResponse{}
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListProvidersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListProvidersResponse) ProtoMessage() {}
+
+func (x *ListProvidersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_subtitler_v1_subtitler_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListProvidersResponse.ProtoReflect.Descriptor instead.
+func (*ListProvidersResponse) Descriptor() ([]byte, []int) {
+	return file_subtitler_v1_subtitler_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListProvidersResponse) GetProviders() []*Provider {
+	if x != nil {
+		return x.Providers
+	}
+	return nil
+}
+
+var File_subtitler_v1_subtitler_proto protoreflect.FileDescriptor
+
+const file_subtitler_v1_subtitler_proto_rawDesc = "" +
+	"\n" +
+	"\x1csubtitler/v1/subtitler.proto\x12\fsubtitler.v1\"\xd8\x04\n" +
+	"\rSearchRequest\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\x12\x12\n" +
+	"\x04term\x18\x02 \x01(\tR\x04term\x12\x12\n" +
+	"\x04year\x18\x03 \x01(\x05R\x04year\x12\x14\n" +
+	"\x05group\x18\x04 \x01(\tR\x05group\x12\x18\n" +
+	"\aquality\x18\x05 \x01(\tR\aquality\x12\x1e\n" +
+	"\n" +
+	"resolution\x18\x06 \x01(\tR\n" +
+	"resolution\x12\x16\n" +
+	"\x06season\x18\a \x01(\x05R\x06season\x12\x18\n" +
+	"\aepisode\x18\b \x01(\x05R\aepisode\x12\x1a\n" +
+	"\blanguage\x18\t \x01(\tR\blanguage\x12#\n" +
+	"\rmin_downloads\x18\n" +
+	" \x01(\x05R\fminDownloads\x12\x1d\n" +
+	"\n" +
+	"min_rating\x18\v \x01(\x01R\tminRating\x12.\n" +
+	"\x10hearing_impaired\x18\f \x01(\bH\x00R\x0fhearingImpaired\x88\x01\x01\x122\n" +
+	"\x12machine_translated\x18\r \x01(\bH\x01R\x11machineTranslated\x88\x01\x01\x12\x1d\n" +
+	"\atrusted\x18\x0e \x01(\bH\x02R\atrusted\x88\x01\x01\x12\x12\n" +
+	"\x04sort\x18\x0f \x01(\tR\x04sort\x12\x14\n" +
+	"\x05order\x18\x10 \x01(\tR\x05order\x12<\n" +
+	"\x1ainclude_machine_translated\x18\x11 \x01(\bR\x18includeMachineTranslatedB\x13\n" +
+	"\x11_hearing_impairedB\x15\n" +
+	"\x13_machine_translatedB\n" +
+	"\n" +
+	"\b_trusted\"\xf7\x04\n" +
+	"\bSubtitle\x12\x10\n" +
+	"\x03uid\x18\x01 \x01(\tR\x03uid\x12\x1a\n" +
+	"\bprovider\x18\x02 \x01(\tR\bprovider\x12\x0e\n" +
+	"\x02id\x18\x03 \x01(\tR\x02id\x12\x12\n" +
+	"\x04kind\x18\x04 \x01(\tR\x04kind\x12\x14\n" +
+	"\x05title\x18\x05 \x01(\tR\x05title\x12 \n" +
+	"\vdescription\x18\x06 \x01(\tR\vdescription\x12\x1a\n" +
+	"\blanguage\x18\a \x01(\tR\blanguage\x12\x12\n" +
+	"\x04year\x18\b \x01(\x05R\x04year\x12\x16\n" +
+	"\x06season\x18\t \x01(\x05R\x06season\x12\x18\n" +
+	"\aepisode\x18\n" +
+	" \x01(\x05R\aepisode\x12\x16\n" +
+	"\x06groups\x18\v \x03(\tR\x06groups\x12\x1c\n" +
+	"\tqualities\x18\f \x03(\tR\tqualities\x12 \n" +
+	"\vresolutions\x18\r \x03(\tR\vresolutions\x12\x1c\n" +
+	"\tdurations\x18\x0e \x03(\tR\tdurations\x12\x1c\n" +
+	"\tdownloads\x18\x0f \x01(\x05R\tdownloads\x12\x16\n" +
+	"\x06rating\x18\x10 \x01(\x01R\x06rating\x12\x1a\n" +
+	"\buploader\x18\x11 \x01(\tR\buploader\x12\x1f\n" +
+	"\vuploaded_at\x18\x12 \x01(\tR\n" +
+	"uploadedAt\x12\x10\n" +
+	"\x03cds\x18\x13 \x01(\x05R\x03cds\x12\x10\n" +
+	"\x03fps\x18\x14 \x01(\x01R\x03fps\x12)\n" +
+	"\x10hearing_impaired\x18\x15 \x01(\bR\x0fhearingImpaired\x12-\n" +
+	"\x12machine_translated\x18\x16 \x01(\bR\x11machineTranslated\x12\x18\n" +
+	"\atrusted\x18\x17 \x01(\bR\atrusted\"\x7f\n" +
+	"\x0eSearchResponse\x124\n" +
+	"\tsubtitles\x18\x01 \x03(\v2\x16.subtitler.v1.SubtitleR\tsubtitles\x127\n" +
+	"\askipped\x18\x02 \x03(\v2\x1d.subtitler.v1.SkippedProviderR\askipped\"[\n" +
+	"\x0fSkippedProvider\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"\x9c\x01\n" +
+	"\x0fProviderResults\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\x124\n" +
+	"\tsubtitles\x18\x02 \x03(\v2\x16.subtitler.v1.SubtitleR\tsubtitles\x127\n" +
+	"\askipped\x18\x03 \x01(\v2\x1d.subtitler.v1.SkippedProviderR\askipped\"N\n" +
+	"\x0fDownloadRequest\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\x12\x1f\n" +
+	"\vsubtitle_id\x18\x02 \x01(\tR\n" +
+	"subtitleId\"b\n" +
+	"\rDownloadChunk\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12!\n" +
+	"\fcontent_type\x18\x02 \x01(\tR\vcontentType\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\"\x16\n" +
+	"\x14ListProvidersRequest\"\x91\x01\n" +
+	"\bProvider\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\aenabled\x18\x02 \x01(\bR\aenabled\x12\x1c\n" +
+	"\tlanguages\x18\x03 \x03(\tR\tlanguages\x12\x1f\n" +
+	"\vsearch_keys\x18\x04 \x03(\tR\n" +
+	"searchKeys\x12\x18\n" +
+	"\aformats\x18\x05 \x03(\tR\aformats\"M\n" +
+	"\x15ListProvidersResponse\x124\n" +
+	"\tproviders\x18\x01 \x03(\v2\x16.subtitler.v1.ProviderR\tproviders2\xc9\x02\n" +
+	"\x10SubtitlerService\x12C\n" +
+	"\x06Search\x12\x1b.subtitler.v1.SearchRequest\x1a\x1c.subtitler.v1.SearchResponse\x12L\n" +
+	"\fSearchStream\x12\x1b.subtitler.v1.SearchRequest\x1a\x1d.subtitler.v1.ProviderResults0\x01\x12H\n" +
+	"\bDownload\x12\x1d.subtitler.v1.DownloadRequest\x1a\x1b.subtitler.v1.DownloadChunk0\x01\x12X\n" +
+	"\rListProviders\x12\".subtitler.v1.ListProvidersRequest\x1a#.subtitler.v1.ListProvidersResponseBEZCgithub.com/xochilpili/subtitler-api/pkg/pb/subtitler/v1;subtitlerv1b\x06proto3"
+
+var (
+	file_subtitler_v1_subtitler_proto_rawDescOnce sync.Once
+	file_subtitler_v1_subtitler_proto_rawDescData []byte
+)
+
+func file_subtitler_v1_subtitler_proto_rawDescGZIP() []byte {
+	file_subtitler_v1_subtitler_proto_rawDescOnce.Do(func() {
+		file_subtitler_v1_subtitler_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_subtitler_v1_subtitler_proto_rawDesc), len(file_subtitler_v1_subtitler_proto_rawDesc)))
+	})
+	return file_subtitler_v1_subtitler_proto_rawDescData
+}
+
+var file_subtitler_v1_subtitler_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_subtitler_v1_subtitler_proto_goTypes = []any{
+	(*SearchRequest)(nil),         // 0: subtitler.v1.SearchRequest
+	(*Subtitle)(nil),              // 1: subtitler.v1.Subtitle
+	(*SearchResponse)(nil),        // 2: subtitler.v1.SearchResponse
+	(*SkippedProvider)(nil),       // 3: subtitler.v1.SkippedProvider
+	(*ProviderResults)(nil),       // 4: subtitler.v1.ProviderResults
+	(*DownloadRequest)(nil),       // 5: subtitler.v1.DownloadRequest
+	(*DownloadChunk)(nil),         // 6: subtitler.v1.DownloadChunk
+	(*ListProvidersRequest)(nil),  // 7: subtitler.v1.ListProvidersRequest
+	(*Provider)(nil),              // 8: subtitler.v1.Provider
+	(*ListProvidersResponse)(nil), // 9: subtitler.v1.ListProvidersResponse
+}
+var file_subtitler_v1_subtitler_proto_depIdxs = []int32{
+	1, // 0: subtitler.v1.SearchResponse.subtitles:type_name -> subtitler.v1.Subtitle
+	3, // 1: subtitler.v1.SearchResponse.skipped:type_name -> subtitler.v1.SkippedProvider
+	1, // 2: subtitler.v1.ProviderResults.subtitles:type_name -> subtitler.v1.Subtitle
+	3, // 3: subtitler.v1.ProviderResults.skipped:type_name -> subtitler.v1.SkippedProvider
+	8, // 4: subtitler.v1.ListProvidersResponse.providers:type_name -> subtitler.v1.Provider
+	0, // 5: subtitler.v1.SubtitlerService.Search:input_type -> subtitler.v1.SearchRequest
+	0, // 6: subtitler.v1.SubtitlerService.SearchStream:input_type -> subtitler.v1.SearchRequest
+	5, // 7: subtitler.v1.SubtitlerService.Download:input_type -> subtitler.v1.DownloadRequest
+	7, // 8: subtitler.v1.SubtitlerService.ListProviders:input_type -> subtitler.v1.ListProvidersRequest
+	2, // 9: subtitler.v1.SubtitlerService.Search:output_type -> subtitler.v1.SearchResponse
+	4, // 10: subtitler.v1.SubtitlerService.SearchStream:output_type -> subtitler.v1.ProviderResults
+	6, // 11: subtitler.v1.SubtitlerService.Download:output_type -> subtitler.v1.DownloadChunk
+	9, // 12: subtitler.v1.SubtitlerService.ListProviders:output_type -> subtitler.v1.ListProvidersResponse
+	9, // [9:13] is the sub-list for method output_type
+	5, // [5:9] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_subtitler_v1_subtitler_proto_init() }
+func file_subtitler_v1_subtitler_proto_init() {
+	if File_subtitler_v1_subtitler_proto != nil {
+		return
+	}
+	file_subtitler_v1_subtitler_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_subtitler_v1_subtitler_proto_rawDesc), len(file_subtitler_v1_subtitler_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_subtitler_v1_subtitler_proto_goTypes,
+		DependencyIndexes: file_subtitler_v1_subtitler_proto_depIdxs,
+		MessageInfos:      file_subtitler_v1_subtitler_proto_msgTypes,
+	}.Build()
+	File_subtitler_v1_subtitler_proto = out.File
+	file_subtitler_v1_subtitler_proto_goTypes = nil
+	file_subtitler_v1_subtitler_proto_depIdxs = nil
+}
@@ -0,0 +1,248 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: subtitler/v1/subtitler.proto
+
+package subtitlerv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SubtitlerService_Search_FullMethodName        = "/subtitler.v1.SubtitlerService/Search"
+	SubtitlerService_SearchStream_FullMethodName  = "/subtitler.v1.SubtitlerService/SearchStream"
+	SubtitlerService_Download_FullMethodName      = "/subtitler.v1.SubtitlerService/Download"
+	SubtitlerService_ListProviders_FullMethodName = "/subtitler.v1.SubtitlerService/ListProviders"
+)
+
+// SubtitlerServiceClient is the client API for SubtitlerService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SubtitlerServiceClient interface {
+	// Search queries one provider, or every enabled provider when provider is empty.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// SearchStream sends one message per provider as soon as it answers.
+	SearchStream(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProviderResults], error)
+	// Download streams the subtitle file; the first chunk carries its metadata.
+	Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadChunk], error)
+	ListProviders(ctx context.Context, in *ListProvidersRequest, opts ...grpc.CallOption) (*ListProvidersResponse, error)
+}
+
+type subtitlerServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSubtitlerServiceClient(cc grpc.ClientConnInterface) SubtitlerServiceClient {
+	return &subtitlerServiceClient{cc}
+}
+
+func (c *subtitlerServiceClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, SubtitlerService_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *subtitlerServiceClient) SearchStream(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProviderResults], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SubtitlerService_ServiceDesc.Streams[0], SubtitlerService_SearchStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SearchRequest, ProviderResults]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SubtitlerService_SearchStreamClient = grpc.ServerStreamingClient[ProviderResults]
+
+func (c *subtitlerServiceClient) Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SubtitlerService_ServiceDesc.Streams[1], SubtitlerService_Download_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DownloadRequest, DownloadChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SubtitlerService_DownloadClient = grpc.ServerStreamingClient[DownloadChunk]
+
+func (c *subtitlerServiceClient) ListProviders(ctx context.Context, in *ListProvidersRequest, opts ...grpc.CallOption) (*ListProvidersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListProvidersResponse)
+	err := c.cc.Invoke(ctx, SubtitlerService_ListProviders_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SubtitlerServiceServer is the server API for SubtitlerService service.
+// All implementations must embed UnimplementedSubtitlerServiceServer
+// for forward compatibility.
+type SubtitlerServiceServer interface {
+	// Search queries one provider, or every enabled provider when provider is empty.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// SearchStream sends one message per provider as soon as it answers.
+	SearchStream(*SearchRequest, grpc.ServerStreamingServer[ProviderResults]) error
+	// Download streams the subtitle file; the first chunk carries its metadata.
+	Download(*DownloadRequest, grpc.ServerStreamingServer[DownloadChunk]) error
+	ListProviders(context.Context, *ListProvidersRequest) (*ListProvidersResponse, error)
+	mustEmbedUnimplementedSubtitlerServiceServer()
+}
+
+// UnimplementedSubtitlerServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSubtitlerServiceServer struct{}
+
+func (UnimplementedSubtitlerServiceServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedSubtitlerServiceServer) SearchStream(*SearchRequest, grpc.ServerStreamingServer[ProviderResults]) error {
+	return status.Errorf(codes.Unimplemented, "method SearchStream not implemented")
+}
+func (UnimplementedSubtitlerServiceServer) Download(*DownloadRequest, grpc.ServerStreamingServer[DownloadChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method Download not implemented")
+}
+func (UnimplementedSubtitlerServiceServer) ListProviders(context.Context, *ListProvidersRequest) (*ListProvidersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProviders not implemented")
+}
+func (UnimplementedSubtitlerServiceServer) mustEmbedUnimplementedSubtitlerServiceServer() {}
+func (UnimplementedSubtitlerServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeSubtitlerServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SubtitlerServiceServer will
+// result in compilation errors.
+type UnsafeSubtitlerServiceServer interface {
+	mustEmbedUnimplementedSubtitlerServiceServer()
+}
+
+func RegisterSubtitlerServiceServer(s grpc.ServiceRegistrar, srv SubtitlerServiceServer) {
+	// If the following call pancis, it indicates UnimplementedSubtitlerServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SubtitlerService_ServiceDesc, srv)
+}
+
+func _SubtitlerService_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SubtitlerServiceServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SubtitlerService_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SubtitlerServiceServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SubtitlerService_SearchStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SearchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SubtitlerServiceServer).SearchStream(m, &grpc.GenericServerStream[SearchRequest, ProviderResults]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SubtitlerService_SearchStreamServer = grpc.ServerStreamingServer[ProviderResults]
+
+func _SubtitlerService_Download_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SubtitlerServiceServer).Download(m, &grpc.GenericServerStream[DownloadRequest, DownloadChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SubtitlerService_DownloadServer = grpc.ServerStreamingServer[DownloadChunk]
+
+func _SubtitlerService_ListProviders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProvidersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SubtitlerServiceServer).ListProviders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SubtitlerService_ListProviders_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SubtitlerServiceServer).ListProviders(ctx, req.(*ListProvidersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SubtitlerService_ServiceDesc is the grpc.ServiceDesc for SubtitlerService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SubtitlerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "subtitler.v1.SubtitlerService",
+	HandlerType: (*SubtitlerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler:    _SubtitlerService_Search_Handler,
+		},
+		{
+			MethodName: "ListProviders",
+			Handler:    _SubtitlerService_ListProviders_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SearchStream",
+			Handler:       _SubtitlerService_SearchStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Download",
+			Handler:       _SubtitlerService_Download_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "subtitler/v1/subtitler.proto",
+}